@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"os"
 	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/completion"
 	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
@@ -16,14 +19,23 @@ import (
 	storepkg "github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
 	"github.com/codeactual/kubectl-fzf/v4/internal/parse"
+	"github.com/codeactual/kubectl-fzf/v4/internal/preview"
 	"github.com/codeactual/kubectl-fzf/v4/internal/results"
 	"github.com/codeactual/kubectl-fzf/v4/internal/util"
 	configstore "github.com/codeactual/kubectl-fzf/v4/internal/util/config"
+	"github.com/pkg/errors"
 )
 
 const (
 	FallbackExitCode = 6
-	configFileName   = ".kubectl_fzf.json"
+	// StaleDataExitCode is returned when the verb refuses stale data, the
+	// shell plugin shows the reason
+	StaleDataExitCode = 7
+	// ReplaceArgsExitCode is returned when the output replaces the kubectl
+	// arguments instead of the current word, after switching the resource
+	// type from fzf
+	ReplaceArgsExitCode = 8
+	configFileName      = ".kubectl_fzf.json"
 )
 
 var (
@@ -63,8 +75,15 @@ func completeFun(store *configstore.Store, cmdArgs []string) {
 		os.Exit(FallbackExitCode)
 	}
 
-	completionResults, err := completion.ProcessCommandArgs(firstWord, args, f)
-	if e, ok := err.(resources.UnknownResourceError); ok {
+	completionCli := completion.NewCompletionCli(store)
+	if err := completionCli.ConfigureColumns(); err != nil {
+		log.Warnf("Error configuring columns: %s", err)
+	}
+	completionResults, err := completion.ProcessCommandArgs(firstWord, args, f, &completionCli)
+	if e, ok := err.(completion.StaleDataError); ok {
+		fmt.Print(e)
+		os.Exit(StaleDataExitCode)
+	} else if e, ok := err.(resources.UnknownResourceError); ok {
 		log.Warnf("Unknown resource type: %s", e)
 		os.Exit(FallbackExitCode)
 	} else if e, ok := err.(parse.UnmanagedFlagError); ok {
@@ -85,22 +104,103 @@ func completeFun(store *configstore.Store, cmdArgs []string) {
 		log.Warn("No completion found")
 		os.Exit(5)
 	}
+	res, replaceArgs, err := selectCompletion(store, f, &completionCli, completionResults, firstWord, args)
+	if e, ok := err.(fzf.InterruptedCommandError); ok {
+		log.Infof("Fzf was interrupted: %s", e)
+		os.Exit(FallbackExitCode)
+	} else if err != nil {
+		log.Fatalf("Selection error: %s", err)
+	}
+	fmt.Print(res)
+	if replaceArgs {
+		os.Exit(ReplaceArgsExitCode)
+	}
+}
+
+// selectCompletion calls fzf on the completions with a session directory
+// for the preview and the reloads, removed before returning
+// replaceArgs is true when res replaces the arguments after a type switch.
+func selectCompletion(store *configstore.Store, f *fetcher.Fetcher, completionCli *completion.CompletionCli,
+	completionResults *completion.CompletionResult, firstWord string, args []string) (res string, replaceArgs bool, err error) {
 	formattedComps := completionResults.GetFormattedOutput()
 
+	sessionDir, err := os.MkdirTemp("", "kubectl-fzf-session-")
+	if err != nil {
+		return "", false, errors.Wrap(err, "error creating the session directory")
+	}
+	defer os.RemoveAll(sessionDir)
+	var fzfArgs []string
+	if completionResults.Reloadable {
+		state := &completion.ListState{ResourceType: completionResults.ResourceType, Namespace: completionResults.Namespace}
+		if err := state.Save(sessionDir); err != nil {
+			return "", false, err
+		}
+		_, canSwitchType := completion.ReplaceResourceType(firstWord, args, completionResults.ResourceType)
+		fzfArgs = fzf.ReloadArgs(sessionDir, canSwitchType && completionCli.ReplaceArgs)
+	}
+
 	query := completion.ExtractQueryFromArgs(args)
-	fzfResult, err := fzf.CallFzf(formattedComps, query)
+	fzfCli := fzf.NewFzfCli(store, firstWord)
+	searchColumns := completionCli.Columns.Searchable(completionResults.ResourceType)
+	fzfResult, err := fzf.CallFzf(formattedComps, query, completionResults.GetPreviewResourceType(), searchColumns,
+		sessionDir, &fzfCli, fzfArgs)
+	if err != nil {
+		return "", false, err
+	}
+
+	// The selected line can be of another type after a reload: the command
+	// is then rewritten to complete this type
+	state, err := completion.LoadListState(sessionDir)
 	if err != nil {
-		if e, ok := err.(fzf.InterruptedCommandError); ok {
-			log.Infof("Fzf was interrupted: %s", e)
-			os.Exit(FallbackExitCode)
+		return "", false, err
+	}
+	selectedType := completionResults.ResourceType
+	if state != nil {
+		selectedType = state.ResourceType
+	}
+	if f.HistoryEnabled() {
+		recordSelection(f, completionCli, completionResults, state, fzfResult)
+	}
+	fzfResult = completionResults.StripMarker(fzfResult)
+	if selectedType != completionResults.ResourceType {
+		replacedArgs, ok := completion.ReplaceResourceType(firstWord, args, selectedType)
+		if !ok {
+			return "", false, errors.Errorf("can't replace the resource type of %v", args)
+		}
+		res, err := results.ProcessResult(firstWord, replacedArgs, f, fzfResult)
+		if err != nil {
+			return "", false, errors.Wrap(err, "process result error")
 		}
-		log.Fatalf("Call fzf error: %s", err)
+		cmdArgs := append([]string{firstWord}, replacedArgs[:len(replacedArgs)-1]...)
+		return fmt.Sprintf("%s %s", strings.Join(cmdArgs, " "), res), true, nil
 	}
-	res, err := results.ProcessResult(firstWord, args, f, fzfResult)
+	res, err = results.ProcessResult(firstWord, args, f, fzfResult)
 	if err != nil {
-		log.Fatalf("Process result error: %s", err)
+		return "", false, errors.Wrap(err, "process result error")
+	}
+	return res, false, nil
+}
+
+// recordSelection records the selected resource in the history
+// After a reload, fzf shows the rows listed by the list subcommand for state:
+// they are listed again to find the selected one.
+func recordSelection(f *fetcher.Fetcher, completionCli *completion.CompletionCli, completionResults *completion.CompletionResult,
+	state *completion.ListState, fzfResult string) {
+	listed := completionResults
+	if state != nil && !state.IsListedBy(completionResults) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var err error
+		listed, err = completion.List(ctx, f, completionCli, state, completion.ListActionNone)
+		if err != nil {
+			log.Warnf("Error listing %s to record the selection: %s", state.ResourceType, err)
+			return
+		}
+	}
+	listed.RecordSelection(f, fzfResult)
+	if err := f.SaveFetcherState(); err != nil {
+		log.Warnf("Error saving history: %s", err)
 	}
-	fmt.Print(res)
 }
 
 func statsFun(cfg *configstore.Store) {
@@ -111,6 +211,129 @@ func statsFun(cfg *configstore.Store) {
 	util.FatalIf(err)
 	statsOutput := storepkg.GetStatsOutput(stats)
 	fmt.Print(statsOutput)
+	health, err := f.GetHealth(ctx)
+	util.FatalIf(err)
+	fmt.Println()
+	if !health.Ready {
+		fmt.Println("Server is not ready")
+	}
+	fmt.Print(storepkg.GetHealthOutput(health.Resources))
+}
+
+// previewWidth returns the width of the fzf preview window
+func previewWidth() int {
+	for _, env := range []string{"FZF_PREVIEW_COLUMNS", "COLUMNS"} {
+		if width, err := strconv.Atoi(os.Getenv(env)); err == nil && width > 0 {
+			return width
+		}
+	}
+	return preview.DefaultWidth
+}
+
+func previewFun(cfg *configstore.Store, mode preview.Mode, sessionDir string, header string, resourceType string, line string) {
+	fetchConfigCli := fetcher.NewFetcherCli(cfg)
+	f := fetcher.NewFetcher(&fetchConfigCli)
+	if err := f.LoadFetcherState(); err != nil {
+		log.Infof("Error loading fetcher state, previewing the line only: %s", err)
+		f = nil
+	}
+	previewCli := preview.NewPreviewCli(cfg)
+	// The columns of the line are the ones selected for the completion
+	completionCli := completion.NewCompletionCli(cfg)
+	if err := completionCli.ConfigureColumns(); err != nil {
+		log.Debugf("Error configuring columns: %s", err)
+	}
+	// Leave room for the cluster queries of the live modes, each bounded by
+	// the preview timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second+previewCli.Timeout)
+	defer cancel()
+	r := resources.ParseResourceType(resourceType)
+	// The rows can be of another type after a reload
+	if state, err := completion.LoadListState(sessionDir); err == nil && state != nil {
+		r = state.ResourceType
+		header = completion.ResourceHeader(f, completionCli.Columns, r)
+	}
+	header, line = completion.StripMarkerColumn(header, util.StripColors(line))
+	previewer := preview.NewPreviewer(f, completionCli.Columns, previewCli, sessionDir, previewWidth())
+	fmt.Print(previewer.Render(ctx, mode, header, r, line))
+}
+
+func listFun(cfg *configstore.Store, state *completion.ListState, action completion.ListAction, sessionDir string) {
+	fetchConfigCli := fetcher.NewFetcherCli(cfg)
+	f := fetcher.NewFetcher(&fetchConfigCli)
+	err := f.LoadFetcherState()
+	util.FatalIf(err)
+	completionCli := completion.NewCompletionCli(cfg)
+	if err := completionCli.ConfigureColumns(); err != nil {
+		log.Warnf("Error configuring columns: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	completionResults, err := completion.List(ctx, f, &completionCli, state, action)
+	util.FatalIf(err)
+	if sessionDir != "" {
+		err = state.Save(sessionDir)
+		util.FatalIf(err)
+	}
+	err = f.SaveFetcherState()
+	util.FatalIf(err)
+	fmt.Print(completionResults.GetFormattedOutput())
+}
+
+// pinFun adds, removes or lists the pins of the current context
+func pinFun(cfg *configstore.Store, action string, labelQuery bool, args []string) {
+	fetchConfigCli := fetcher.NewFetcherCli(cfg)
+	f := fetcher.NewFetcher(&fetchConfigCli)
+	err := f.LoadFetcherState()
+	util.FatalIf(err)
+	pins := f.GetPins()
+	if action == "ls" {
+		for _, pin := range pins.Resources {
+			fmt.Println(pin)
+		}
+		for _, query := range pins.LabelQueries {
+			fmt.Printf("-l %s\n", query)
+		}
+		return
+	}
+
+	var pin string
+	var changed bool
+	if labelQuery {
+		if len(args) != 1 {
+			log.Fatalf("expected pin %s -l <query>", action)
+		}
+		pin, err = completion.ParseLabelQuery(args[0])
+		util.FatalIf(err)
+		if action == "add" {
+			changed = pins.AddLabelQuery(pin)
+		} else {
+			changed = pins.RemoveLabelQuery(pin)
+		}
+	} else {
+		if len(args) != 2 {
+			log.Fatalf("expected pin %s <type> [<namespace>/]<name>", action)
+		}
+		pin, err = completion.ParsePinnedResource(f, args[0], args[1])
+		util.FatalIf(err)
+		if action == "add" {
+			changed = pins.AddResource(pin)
+		} else {
+			changed = pins.RemoveResource(pin)
+		}
+	}
+	err = f.SaveFetcherState()
+	util.FatalIf(err)
+	switch {
+	case !changed && action == "add":
+		fmt.Printf("%s is already pinned in %s\n", pin, f.GetContext())
+	case !changed:
+		fmt.Printf("%s is not pinned in %s\n", pin, f.GetContext())
+	case action == "add":
+		fmt.Printf("Pinned %s in %s\n", pin, f.GetContext())
+	default:
+		fmt.Printf("Unpinned %s in %s\n", pin, f.GetContext())
+	}
 }
 
 func genFun(cfg *configstore.Store) {
@@ -146,6 +369,87 @@ func runStatsCommand(cfg *configstore.Store, args []string) {
 	statsFun(cfg)
 }
 
+// runPreviewCommand renders the fzf preview of the highlighted line, it's
+// called by fzf and not listed in the usage
+func runPreviewCommand(cfg *configstore.Store, args []string) {
+	previewFlags := flag.NewFlagSet("preview", flag.ContinueOnError)
+	previewFlags.SetOutput(os.Stdout)
+	header := previewFlags.String("header", "", "Header of the completion.")
+	resourceType := previewFlags.String("resource-type", "", "Type of the completed resources.")
+	modeStr := previewFlags.String("mode", string(preview.ModeColumns), "Preview mode: columns, yaml, logs or events.")
+	sessionDir := previewFlags.String("session-dir", "", "Directory caching the live previews of the fzf session.")
+	if err := previewFlags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		util.FatalIf(err)
+	}
+	mode, err := preview.ParseMode(*modeStr)
+	util.FatalIf(err)
+	util.CommonInitialization(cfg)
+	defer pprof.StopCPUProfile()
+	defer util.DoMemoryProfile(cfg)
+	previewFun(cfg, mode, *sessionDir, *header, *resourceType, strings.Join(previewFlags.Args(), " "))
+}
+
+// runListCommand prints the completion rows of a resource type, it's also
+// called by the fzf reload bindings with the state of the completion
+func runListCommand(cfg *configstore.Store, args []string) {
+	listFlags := flag.NewFlagSet("list", flag.ContinueOnError)
+	listFlags.SetOutput(os.Stdout)
+	resourceType := listFlags.String("resource-type", "pods", "Type of the listed resources.")
+	namespace := listFlags.String("namespace", "", "Namespace of the listed resources, all namespaces when empty.")
+	sessionDir := listFlags.String("session-dir", "", "Directory holding the state of the fzf session, overriding resource-type and namespace.")
+	actionStr := listFlags.String("action", "", "Change applied to the state of the session: cycle-namespace or cycle-type.")
+	if err := listFlags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		util.FatalIf(err)
+	}
+	action, err := completion.ParseListAction(*actionStr)
+	util.FatalIf(err)
+	state := &completion.ListState{ResourceType: resources.ParseResourceType(*resourceType)}
+	if *namespace != "" {
+		state.Namespace = namespace
+	}
+	sessionState, err := completion.LoadListState(*sessionDir)
+	util.FatalIf(err)
+	if sessionState != nil {
+		state = sessionState
+	}
+	if state.ResourceType == resources.ResourceTypeUnknown {
+		util.FatalIf(resources.UnknownResourceError{ResourceStr: *resourceType})
+	}
+	util.CommonInitialization(cfg)
+	defer pprof.StopCPUProfile()
+	defer util.DoMemoryProfile(cfg)
+	listFun(cfg, state, action, *sessionDir)
+}
+
+// runPinCommand manages the resources and label queries shown first in the
+// completions of the current context
+func runPinCommand(cfg *configstore.Store, args []string) {
+	usage := "expected pin add|rm <type> [<namespace>/]<name>, pin add|rm -l <query> or pin ls"
+	if len(args) == 0 || !util.IsStringIn(args[0], []string{"add", "rm", "ls"}) {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	pinFlags := flag.NewFlagSet("pin", flag.ContinueOnError)
+	pinFlags.SetOutput(os.Stdout)
+	labelQuery := pinFlags.Bool("l", false, "Pin a label query instead of a resource.")
+	if err := pinFlags.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		util.FatalIf(err)
+	}
+	util.CommonInitialization(cfg)
+	defer pprof.StopCPUProfile()
+	defer util.DoMemoryProfile(cfg)
+	pinFun(cfg, args[0], *labelQuery, pinFlags.Args())
+}
+
 func runGenerateCommand(cfg *configstore.Store, args []string) {
 	genFlags := flag.NewFlagSet("generate", flag.ContinueOnError)
 	genFlags.SetOutput(os.Stdout)
@@ -188,7 +492,7 @@ func main() {
 
 	args := rootFlags.Args()
 	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "expected subcommand: k8s_completion, stats, generate, version")
+		fmt.Fprintln(os.Stderr, "expected subcommand: k8s_completion, list, pin, stats, generate, version")
 		os.Exit(1)
 	}
 
@@ -200,10 +504,16 @@ func main() {
 		versionFun()
 	case "k8s_completion":
 		runCompletionCommand(cfg, args[1:])
+	case "list":
+		runListCommand(cfg, args[1:])
+	case "pin":
+		runPinCommand(cfg, args[1:])
 	case "stats":
 		runStatsCommand(cfg, args[1:])
 	case "generate":
 		runGenerateCommand(cfg, args[1:])
+	case "preview":
+		runPreviewCommand(cfg, args[1:])
 	default:
 		fmt.Fprintf(os.Stderr, "unknown subcommand %s\n", args[0])
 		os.Exit(1)
@@ -52,6 +52,7 @@ func main() {
 	storepkg.SetStoreConfigCli(rootFlags)
 	httpserver.SetHttpServerConfigFlags(rootFlags)
 	resourcewatcher.SetResourceWatcherCli(rootFlags)
+	kubectlfzfserver.SetKubectlFzfServerCli(rootFlags)
 	util.SetCommonCliFlags(rootFlags, "info")
 	if err := cfg.BindFlagSet(rootFlags); err != nil {
 		util.FatalIf(err)
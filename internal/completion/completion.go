@@ -2,13 +2,16 @@ package completion
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
 	"github.com/codeactual/kubectl-fzf/v4/internal/parse"
+	"k8s.io/apimachinery/pkg/labels"
 
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
 	"github.com/pkg/errors"
@@ -27,20 +30,207 @@ func PrepareCmdArgs(cmdArgs []string) []string {
 	return args
 }
 
+// The first column of the completions marks the pinned ones with
+// pinnedMarker and the ones found in the history with historyMarker, the
+// others get noMarker
+const (
+	pinnedMarker  = "*"
+	historyMarker = "+"
+	noMarker      = "-"
+	markerHeader  = "Rank"
+)
+
+// markerEnabled returns true when the completions start with the marker
+// column: with history enabled or pins in the context
+func markerEnabled(f *fetcher.Fetcher) bool {
+	return f != nil && (f.HistoryEnabled() || !f.GetPins().IsEmpty())
+}
+
+// withMarker prepends the marker column to header when enabled
+func withMarker(f *fetcher.Fetcher, header string) string {
+	if markerEnabled(f) {
+		return fmt.Sprintf("%s\t%s", markerHeader, header)
+	}
+	return header
+}
+
+// PinKey returns the key pinning a resource: <type>/<namespace>/<name>, key
+// being the store key of the resource
+func PinKey(r resources.ResourceType, key string) string {
+	return fmt.Sprintf("%s/%s/%s", r, store.KeyNamespace(key), store.KeyName(key))
+}
+
+// ParsePinnedResource returns the pin key of the resource of type
+// resourceStr named by ref, [<namespace>/]<name>
+// Namespaced resources without namespace are in the namespace of the
+// context, default when it has none.
+func ParsePinnedResource(f *fetcher.Fetcher, resourceStr string, ref string) (string, error) {
+	r := resources.ParseResourceType(resourceStr)
+	if r == resources.ResourceTypeUnknown || r == resources.ResourceTypeApiResource {
+		return "", resources.UnknownResourceError{ResourceStr: resourceStr}
+	}
+	namespace, name := "", ref
+	if i := strings.Index(ref, "/"); i >= 0 {
+		namespace, name = ref[:i], ref[i+1:]
+	}
+	if name == "" {
+		return "", fmt.Errorf("no resource name in %q", ref)
+	}
+	if !r.IsNamespaced() {
+		namespace = ""
+	} else if namespace == "" {
+		namespace, _ = f.GetNamespace()
+		if namespace == "" {
+			namespace = "default"
+		}
+	}
+	return PinKey(r, store.Key(namespace, name)), nil
+}
+
+// ParseLabelQuery validates a label query to save, returning it in its
+// canonical form
+func ParseLabelQuery(query string) (string, error) {
+	selector, err := labels.Parse(query)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid label query %q", query)
+	}
+	if selector.Empty() {
+		return "", fmt.Errorf("empty label query %q", query)
+	}
+	return selector.String(), nil
+}
+
+// historyKey returns the key of a resource in the history
+// Pods of a ReplicaSet share the key of the ReplicaSet, named after their
+// pod-template-hash label, so recreated pods inherit the history.
+func historyKey(r resources.ResourceType, key string, resource resources.K8sResource) string {
+	namespace, name := store.KeyNamespace(key), store.KeyName(key)
+	if r == resources.ResourceTypePod {
+		if hash := resource.GetLabels()["pod-template-hash"]; hash != "" {
+			if i := strings.LastIndex(name, "-"+hash+"-"); i > 0 {
+				name = name[:i+len(hash)+1]
+			}
+		}
+	}
+	return fmt.Sprintf("%s/%s/%s", r, namespace, name)
+}
+
+// ResourceHeader returns the header of the completions of r with the
+// selected columns, starting with the marker column when enabled
+func ResourceHeader(f *fetcher.Fetcher, columns *resources.Columns, r resources.ResourceType) string {
+	return withMarker(f, columns.Header(r))
+}
+
+type rankedCompletion struct {
+	line     string
+	color    columnColor
+	pinned   bool
+	frecency float64
+}
+
+// getResourceCompletion returns the completions of the resources of r with
+// their default columns, the pinned ones first, then sorted by frecency then
+// alphabetically
+// With the marker column, the first column marks the pinned completions and
+// the ones found in the history.
 func getResourceCompletion(ctx context.Context, r resources.ResourceType, namespace *string,
 	fetchConfig *fetcher.Fetcher) ([]string, error) {
-	resources, err := fetchConfig.GetResources(ctx, r)
+	resourceMap, err := fetchConfig.GetResources(ctx, r)
 	if err != nil {
 		return nil, err
 	}
-	comps := []string{}
+	comps, _ := getColoredResourceCompletion(r, namespace, resourceMap, fetchConfig, nil)
+	return comps, nil
+}
+
+// getColoredResourceCompletion returns the completions of getResourceCompletion
+// for the resources of resourceMap, with the selected columns and the colour
+// of their status column
+func getColoredResourceCompletion(r resources.ResourceType, namespace *string, resourceMap map[string]resources.K8sResource,
+	fetchConfig *fetcher.Fetcher, selectedColumns *resources.Columns) ([]string, []columnColor) {
+	ranked := []rankedCompletion{}
+	columns := strings.Split(selectedColumns.Header(r), "\t")
+	pins := fetchConfig.GetPins()
+	now := time.Now()
 	log.Debugf("Filterting with namespace %v", namespace)
-	for _, resource := range resources {
-		if namespace == nil || *namespace == resource.GetNamespace() {
-			comps = append(comps, resource.ToStrings()...)
+	for key, resource := range resourceMap {
+		if namespace != nil && *namespace != resource.GetNamespace() {
+			continue
+		}
+		resourceHistoryKey := historyKey(r, key, resource)
+		pinned := pins.IsResourcePinned(PinKey(r, key), resourceHistoryKey)
+		frecency := fetchConfig.GetFrecency(resourceHistoryKey, now)
+		color := getColumnColor(columns, resources.GetStatusColor(resource))
+		for _, line := range selectedColumns.Strings(r, resource) {
+			ranked = append(ranked, rankedCompletion{line: line, color: color, pinned: pinned, frecency: frecency})
 		}
 	}
-	return comps, nil
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].pinned != ranked[j].pinned {
+			return ranked[i].pinned
+		}
+		if ranked[i].frecency != ranked[j].frecency {
+			return ranked[i].frecency > ranked[j].frecency
+		}
+		return ranked[i].line < ranked[j].line
+	})
+	withMarkers := markerEnabled(fetchConfig)
+	comps := make([]string, 0, len(ranked))
+	colors := make([]columnColor, 0, len(ranked))
+	for _, c := range ranked {
+		line := c.line
+		color := c.color
+		if withMarkers {
+			color.column++
+			marker := noMarker
+			switch {
+			case c.pinned:
+				marker = pinnedMarker
+			case c.frecency > 0:
+				marker = historyMarker
+			}
+			line = fmt.Sprintf("%s\t%s", marker, line)
+		}
+		comps = append(comps, line)
+		colors = append(colors, color)
+	}
+	return comps, colors
+}
+
+// recordSelection records the resource of a completion line in the history
+// header and line are tab separated, as the completions are produced: the
+// columns can hold spaces, e.g. annotations. resourceMap holds the completed
+// resources.
+func recordSelection(f *fetcher.Fetcher, r resources.ResourceType, resourceMap map[string]resources.K8sResource,
+	header string, line string) {
+	if !f.HistoryEnabled() {
+		return
+	}
+	columns := strings.Split(header, "\t")
+	fields := strings.Split(line, "\t")
+	namespace := ""
+	name := ""
+	for i, column := range columns {
+		if i >= len(fields) {
+			break
+		}
+		switch strings.ToLower(column) {
+		case "namespace":
+			namespace = fields[i]
+		case "name":
+			name = fields[i]
+		}
+	}
+	if name == "" {
+		return
+	}
+	key := store.Key(namespace, name)
+	resource, ok := resourceMap[key]
+	if !ok {
+		log.Debugf("Selected %s %s not found", r, key)
+		return
+	}
+	f.RecordHistory(historyKey(r, key, resource))
 }
 
 func ExtractQueryFromArgs(cmdArgs []string) string {
@@ -55,7 +245,7 @@ func ExtractQueryFromArgs(cmdArgs []string) string {
 }
 
 func processCommandArgsWithFetchConfig(ctx context.Context, fetchConfig *fetcher.Fetcher,
-	cmdVerb string, args []string) (*CompletionResult, error) {
+	columns *resources.Columns, cmdVerb string, args []string) (*CompletionResult, error) {
 	var err error
 	resourceType, flagCompletion, err := parse.ParseFlagAndResources(cmdVerb, args)
 	if err != nil {
@@ -63,28 +253,57 @@ func processCommandArgsWithFetchConfig(ctx context.Context, fetchConfig *fetcher
 	}
 	log.Debugf("Call Get Fun with %+v, resource type detected %s, flag detected %s", args, resourceType, flagCompletion)
 
-	completionResult := &CompletionResult{Cluster: fetchConfig.GetContext()}
+	completionResult := &CompletionResult{Cluster: fetchConfig.GetContext(), ResourceType: resources.ResourceTypeUnknown}
 	namespace := parse.ParseNamespaceFromArgs(args)
+	completionResult.MarkerColumn = markerEnabled(fetchConfig)
 	if flagCompletion == parse.FlagLabel {
 		completionResult.Header, completionResult.Completions, err = GetTagResourceCompletion(ctx, resourceType, namespace, fetchConfig, TagTypeLabel)
+		completionResult.Freshness = fetchConfig.GetFreshness()
 		return completionResult, err
 	} else if flagCompletion == parse.FlagFieldSelector {
 		completionResult.Header, completionResult.Completions, err = GetTagResourceCompletion(ctx, resourceType, namespace, fetchConfig, TagTypeFieldSelector)
+		completionResult.Freshness = fetchConfig.GetFreshness()
 		return completionResult, err
 	}
 
-	completionResult.Header = resources.ResourceToHeader(resourceType)
-	completionResult.Completions, err = getResourceCompletion(ctx, resourceType, namespace, fetchConfig)
+	completionResult.Reloadable = flagCompletion == parse.FlagNone && resourceType != resources.ResourceTypeApiResource
+	err = setResourceCompletion(ctx, fetchConfig, columns, completionResult, resourceType, namespace)
+	return completionResult, err
+}
+
+// setResourceCompletion fills completionResult with the resources of
+// resourceType in namespace, all namespaces when it's nil, with the selected
+// columns
+func setResourceCompletion(ctx context.Context, fetchConfig *fetcher.Fetcher, columns *resources.Columns,
+	completionResult *CompletionResult, resourceType resources.ResourceType, namespace *string) error {
+	completionResult.Header = ResourceHeader(fetchConfig, columns, resourceType)
+	completionResult.MarkerColumn = markerEnabled(fetchConfig)
+	completionResult.ResourceType = resourceType
+	completionResult.Namespace = namespace
+	resourceMap, err := fetchConfig.GetResources(ctx, resourceType)
 	if err != nil {
-		return completionResult, errors.Wrap(err, "error getting resource completion")
+		return errors.Wrap(err, "error getting resource completion")
 	}
-	sort.Strings(completionResult.Completions)
-	return completionResult, err
+	completionResult.resourceMap = resourceMap
+	completionResult.Completions, completionResult.columnColors = getColoredResourceCompletion(resourceType, namespace, resourceMap, fetchConfig, columns)
+	completionResult.Freshness = fetchConfig.GetFreshness()
+	return nil
 }
 
-func ProcessCommandArgs(cmdVerb string, args []string, f *fetcher.Fetcher) (*CompletionResult, error) {
+// ProcessCommandArgs returns the completion of args
+// A StaleDataError is returned when the data is stale and cmdVerb refuses
+// stale data.
+func ProcessCommandArgs(cmdVerb string, args []string, f *fetcher.Fetcher, completionCli *CompletionCli) (*CompletionResult, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	completionResult, err := processCommandArgsWithFetchConfig(ctx, f, cmdVerb, args)
+	completionResult, err := processCommandArgsWithFetchConfig(ctx, f, completionCli.Columns, cmdVerb, args)
 	cancel()
-	return completionResult, err
+	if err != nil {
+		return completionResult, err
+	}
+	completionResult.StaleThreshold = completionCli.StaleThreshold
+	completionResult.Color = completionCli.Color
+	if completionCli.refusesStale(cmdVerb) && completionResult.Freshness.IsStale(completionCli.StaleThreshold) {
+		return completionResult, StaleDataError{Verb: cmdVerb, Freshness: completionResult.Freshness}
+	}
+	return completionResult, nil
 }
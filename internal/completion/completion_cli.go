@@ -0,0 +1,81 @@
+package completion
+
+import (
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util/config"
+)
+
+// CompletionCli configures the k8s_completion command
+// The command line of k8s_completion is the kubectl command being completed:
+// these settings are only read from the configuration file and environment.
+type CompletionCli struct {
+	// StaleThreshold is the age after which completion data is stale
+	StaleThreshold time.Duration
+	// RefuseStaleVerbs are the verbs, e.g. delete, not completed with stale
+	// data
+	RefuseStaleVerbs []string
+	// ReloadTypes are the resource types cycled by the fzf reload binding
+	ReloadTypes []string
+	// ReplaceArgs is set by the shell plugins able to replace the whole
+	// command line, needed to switch the resource type from fzf
+	ReplaceArgs bool
+	// Color colours the status column of the completions, e.g. failing pods
+	Color bool
+	// ColumnSpecs are the columns configured per resource type under
+	// columns.<type>, see resources.Columns.Select
+	ColumnSpecs map[resources.ResourceType][]string
+	// Columns are the columns selected from ColumnSpecs by ConfigureColumns
+	Columns *resources.Columns
+	// ExcludedLabels are left out of the Labels and Selector columns
+	ExcludedLabels []string
+}
+
+// defaultReloadTypes are the resource types cycled from fzf by default
+var defaultReloadTypes = []string{"pods", "deployments", "statefulsets", "daemonsets", "services",
+	"ingresses", "configmaps", "secrets", "jobs", "cronjobs"}
+
+func NewCompletionCli(store *config.Store) CompletionCli {
+	columns := map[resources.ResourceType][]string{}
+	for r := resources.ResourceTypeApiResource + 1; r < resources.ResourceTypeUnknown; r++ {
+		if specs := store.GetStringSlice("columns."+r.String(), nil); len(specs) > 0 {
+			columns[r] = specs
+		}
+	}
+	return CompletionCli{
+		StaleThreshold:   store.GetDuration("stale-threshold", time.Hour),
+		RefuseStaleVerbs: store.GetStringSlice("refuse-stale-verbs", []string{}),
+		ReloadTypes:      store.GetStringSlice("reload-types", defaultReloadTypes),
+		ReplaceArgs:      store.GetBool("replace-args", false),
+		Color:            store.GetBool("color", false),
+		ColumnSpecs:      columns,
+		ExcludedLabels:   store.GetStringSlice("excluded-labels", resources.DefaultExcludedLabels),
+	}
+}
+
+// ConfigureColumns selects the configured columns of each resource type and
+// the labels left out of the Labels column, the types with invalid columns
+// keep their default ones
+func (c *CompletionCli) ConfigureColumns() error {
+	var err error
+	c.Columns, err = resources.NewColumns(c.ColumnSpecs, c.ExcludedLabels)
+	return err
+}
+
+func (c *CompletionCli) refusesStale(verb string) bool {
+	return util.IsStringIn(verb, c.RefuseStaleVerbs)
+}
+
+func (c *CompletionCli) getReloadTypes() ([]resources.ResourceType, error) {
+	res := make([]resources.ResourceType, 0, len(c.ReloadTypes))
+	for _, s := range c.ReloadTypes {
+		r := resources.ParseResourceType(s)
+		if r == resources.ResourceTypeUnknown || r == resources.ResourceTypeApiResource {
+			return nil, resources.UnknownResourceError{ResourceStr: s}
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
@@ -0,0 +1,200 @@
+package completion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/parse"
+	"github.com/pkg/errors"
+)
+
+// ListAction changes the listed resources when fzf reloads its rows
+type ListAction string
+
+const (
+	ListActionNone ListAction = ""
+	// ListActionCycleNamespace moves to the next namespace scope: all
+	// namespaces, the namespace of the context, then the namespaces holding
+	// resources of the listed type
+	ListActionCycleNamespace ListAction = "cycle-namespace"
+	// ListActionCycleType moves to the next type of CompletionCli.ReloadTypes
+	ListActionCycleType ListAction = "cycle-type"
+)
+
+func ParseListAction(s string) (ListAction, error) {
+	switch ListAction(s) {
+	case ListActionNone, ListActionCycleNamespace, ListActionCycleType:
+		return ListAction(s), nil
+	}
+	return "", fmt.Errorf("unknown list action %q", s)
+}
+
+// ListState is the resource type and namespace listed in an fzf session
+// It's kept in the session directory between reloads, and read once fzf
+// exits to process the selected line with the listed type.
+type ListState struct {
+	ResourceType resources.ResourceType `json:"resourceType"`
+	// Namespace filtering the listed resources, nil for all namespaces
+	Namespace *string `json:"namespace,omitempty"`
+}
+
+// IsListedBy returns true when c lists the rows of state: the state of a
+// session is the one of its completion until fzf reloads them
+func (s *ListState) IsListedBy(c *CompletionResult) bool {
+	if s.ResourceType != c.ResourceType || (s.Namespace == nil) != (c.Namespace == nil) {
+		return false
+	}
+	return s.Namespace == nil || *s.Namespace == *c.Namespace
+}
+
+func getListStatePath(sessionDir string) string {
+	return path.Join(sessionDir, "list-state.json")
+}
+
+// LoadListState returns the state of the session, nil without session or
+// when the completion wasn't reloadable
+func LoadListState(sessionDir string) (*ListState, error) {
+	if sessionDir == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(getListStatePath(sessionDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &ListState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, errors.Wrapf(err, "error decoding %s", getListStatePath(sessionDir))
+	}
+	return state, nil
+}
+
+func (s *ListState) Save(sessionDir string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getListStatePath(sessionDir), b, 0600)
+}
+
+// scope describes the listed resources, e.g. pods in kube-system
+func (s *ListState) scope() string {
+	switch {
+	case !s.ResourceType.IsNamespaced():
+		return s.ResourceType.String()
+	case s.Namespace == nil:
+		return fmt.Sprintf("%s in all namespaces", s.ResourceType)
+	}
+	return fmt.Sprintf("%s in %s", s.ResourceType, *s.Namespace)
+}
+
+// nextResourceType returns the type following current in types, the first
+// one when current isn't part of it
+func nextResourceType(current resources.ResourceType, types []resources.ResourceType) resources.ResourceType {
+	if len(types) == 0 {
+		return current
+	}
+	for i, r := range types {
+		if r == current {
+			return types[(i+1)%len(types)]
+		}
+	}
+	return types[0]
+}
+
+// nextNamespace returns the namespace scope following current: all
+// namespaces, contextNamespace then the other namespaces of resourceMap
+func nextNamespace(current *string, contextNamespace string, resourceMap map[string]resources.K8sResource) *string {
+	namespaceSet := map[string]bool{}
+	for _, resource := range resourceMap {
+		namespaceSet[resource.GetNamespace()] = true
+	}
+	delete(namespaceSet, contextNamespace)
+	namespaces := make([]string, 0, len(namespaceSet))
+	for namespace := range namespaceSet {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	if contextNamespace != "" {
+		namespaces = append([]string{contextNamespace}, namespaces...)
+	}
+	if current == nil {
+		if len(namespaces) == 0 {
+			return nil
+		}
+		return &namespaces[0]
+	}
+	for i, namespace := range namespaces {
+		if namespace == *current && i+1 < len(namespaces) {
+			return &namespaces[i+1]
+		}
+	}
+	return nil
+}
+
+// List applies action to state and returns the completion of the resulting
+// type and namespace
+func List(ctx context.Context, f *fetcher.Fetcher, completionCli *CompletionCli,
+	state *ListState, action ListAction) (*CompletionResult, error) {
+	switch action {
+	case ListActionCycleType:
+		reloadTypes, err := completionCli.getReloadTypes()
+		if err != nil {
+			return nil, err
+		}
+		state.ResourceType = nextResourceType(state.ResourceType, reloadTypes)
+	case ListActionCycleNamespace:
+		if !state.ResourceType.IsNamespaced() {
+			break
+		}
+		resourceMap, err := f.GetResources(ctx, state.ResourceType)
+		if err != nil {
+			return nil, err
+		}
+		// Without namespace in the kubeconfig context, the cycle skips it
+		contextNamespace, _ := f.GetNamespace()
+		state.Namespace = nextNamespace(state.Namespace, contextNamespace, resourceMap)
+	}
+
+	completionResult := &CompletionResult{Cluster: f.GetContext(), Reloadable: true, Scope: state.scope()}
+	var namespace *string
+	if state.ResourceType.IsNamespaced() {
+		namespace = state.Namespace
+	}
+	if err := setResourceCompletion(ctx, f, completionCli.Columns, completionResult, state.ResourceType, namespace); err != nil {
+		return completionResult, err
+	}
+	completionResult.StaleThreshold = completionCli.StaleThreshold
+	completionResult.Color = completionCli.Color
+	return completionResult, nil
+}
+
+// ReplaceResourceType returns args completing to resourceType instead of the
+// type they name, the last argument being the query
+// ok is false when the type can't be replaced, e.g. with exec where it's
+// implicit.
+func ReplaceResourceType(cmdVerb string, args []string, resourceType resources.ResourceType) (res []string, ok bool) {
+	if cmdVerb == "exec" || cmdVerb == "logs" || len(args) < 2 {
+		return nil, false
+	}
+	res = append([]string(nil), args...)
+	for i, arg := range res[:len(res)-1] {
+		// Flag values, e.g. jobs in -n jobs, aren't types
+		if i > 0 && parse.FlagTakesValue(res[i-1]) {
+			continue
+		}
+		if resources.ParseResourceType(arg) != resources.ResourceTypeUnknown {
+			res[i] = resourceType.String()
+			return res, true
+		}
+	}
+	return nil, false
+}
@@ -2,7 +2,12 @@ package completion
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
 	"github.com/codeactual/kubectl-fzf/v4/internal/util"
 )
 
@@ -10,10 +15,143 @@ type CompletionResult struct {
 	Cluster     string
 	Header      string
 	Completions []string
+	// ResourceType is the type of the completed resources, unknown when
+	// completing labels or field selectors
+	ResourceType resources.ResourceType
+	// Namespace filtering the completed resources, nil for all namespaces
+	Namespace *string
+	// Reloadable is true when the completions can be listed again in another
+	// namespace or resource type, see List
+	Reloadable bool
+	// MarkerColumn is true when the first column marks the pinned completions
+	// and the ones found in the history, see StripMarker
+	MarkerColumn bool
+	// Scope describes the resources listed after a reload, shown in the
+	// header
+	Scope string
+	// Freshness of the completions, shown in the header when they are stale
+	// or the server is not running
+	Freshness      fetcher.Freshness
+	StaleThreshold time.Duration
+	// Color colours the status column of the completions in the formatted
+	// output
+	Color bool
+	// columnColors are the colours of the status column of Completions
+	columnColors []columnColor
+	// resourceMap holds the completed resources, used to record the selected
+	// one in the history
+	resourceMap map[string]resources.K8sResource
+}
+
+// columnColor is the colour of the column at index column of a completion
+type columnColor struct {
+	column int
+	color  util.Color
+}
+
+// getColumnColor returns the colour of the column of c in columns, none when
+// it's not selected
+func getColumnColor(columns []string, c resources.ColumnColor) columnColor {
+	for i, column := range columns {
+		if column == c.Column {
+			return columnColor{column: i, color: c.Color}
+		}
+	}
+	return columnColor{}
+}
+
+// StaleDataError is returned when the completion of a verb refusing stale
+// data only has stale data
+type StaleDataError struct {
+	Verb      string
+	Freshness fetcher.Freshness
+}
+
+func (e StaleDataError) Error() string {
+	return fmt.Sprintf("refusing to complete %s with stale data: %s", e.Verb, e.Freshness)
+}
+
+// GetPreviewResourceType returns the resource type given to the preview, empty
+// when the completions are not resources
+func (c *CompletionResult) GetPreviewResourceType() string {
+	if c.ResourceType == resources.ResourceTypeUnknown {
+		return ""
+	}
+	return c.ResourceType.String()
+}
+
+func (c *CompletionResult) getClusterLine() string {
+	line := fmt.Sprintf("Cluster: %s", c.Cluster)
+	if c.Scope != "" {
+		line = fmt.Sprintf("%s, %s", line, c.Scope)
+	}
+	if c.Freshness.UpdatedAt.IsZero() {
+		return line
+	}
+	if !c.Freshness.ServerRunning || c.Freshness.IsStale(c.StaleThreshold) {
+		line = fmt.Sprintf("%s — %s", line, c.Freshness)
+	}
+	return line
 }
 
 func (c *CompletionResult) GetFormattedOutput() string {
-	lines := []string{fmt.Sprintf("Cluster: %s", c.Cluster), c.Header}
+	lines := []string{c.getClusterLine(), c.Header}
 	lines = append(lines, c.Completions...)
-	return util.FormatCompletion(lines)
+	formatted := util.FormatCompletion(lines)
+	if !c.Color || len(c.columnColors) != len(c.Completions) {
+		return formatted
+	}
+	// The completions follow the cluster line and the header
+	formattedLines := strings.Split(formatted, "\n")
+	for i, color := range c.columnColors {
+		formattedLines[i+2] = util.ColorizeField(formattedLines[i+2], color.column, color.color)
+	}
+	return strings.Join(formattedLines, "\n")
+}
+
+// RecordSelection records the resource of the line selected in fzf in the
+// history, from the resources loaded for the completion
+// The columns of a formatted line are aligned with spaces that they can hold,
+// the selected line is matched against the formatted completions to get its
+// tab separated fields.
+func (c *CompletionResult) RecordSelection(f *fetcher.Fetcher, selected string) {
+	if c.resourceMap == nil {
+		return
+	}
+	selected = strings.TrimSpace(util.StripColors(selected))
+	// The completions follow the cluster line and the header
+	formattedLines := strings.Split(util.StripColors(c.GetFormattedOutput()), "\n")
+	for i, completion := range c.Completions {
+		if strings.TrimSpace(formattedLines[i+2]) == selected {
+			recordSelection(f, c.ResourceType, c.resourceMap, c.Header, completion)
+			return
+		}
+	}
+	log.Debugf("Selected line %q not found in the completions", selected)
+}
+
+// StripMarker removes the marker column from a selected line
+func (c *CompletionResult) StripMarker(line string) string {
+	if !c.MarkerColumn {
+		return line
+	}
+	return removeFirstField(line)
+}
+
+// StripMarkerColumn removes the marker column from a completion line when
+// header has it, e.g. before rendering the preview
+func StripMarkerColumn(header string, line string) (string, string) {
+	if fields := strings.Fields(header); len(fields) == 0 || fields[0] != markerHeader {
+		return header, line
+	}
+	return removeFirstField(header), removeFirstField(line)
+}
+
+func removeFirstField(s string) string {
+	s = strings.TrimLeft(s, " \t")
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimLeft(s[i:], " \t")
 }
@@ -3,18 +3,25 @@ package completion
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"reflect"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
 	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher/fetchertest"
 	"github.com/codeactual/kubectl-fzf/v4/internal/httpserver/httpservertest"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
 	"github.com/codeactual/kubectl-fzf/v4/internal/parse"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util/config"
 )
 
 func TestMain(m *testing.M) {
@@ -54,7 +61,7 @@ func TestProcessResourceName(t *testing.T) {
 		{"exec", []string{"-ti", ""}},
 	}
 	for _, cmdArg := range cmdArgs {
-		completionResults, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, cmdArg.verb, cmdArg.args)
+		completionResults, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, nil, cmdArg.verb, cmdArg.args)
 		if err != nil {
 			t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
 		}
@@ -77,7 +84,7 @@ func TestProcessNamespace(t *testing.T) {
 		{"logs", []string{"--namespace="}},
 	}
 	for _, cmdArg := range cmdArgs {
-		completionResults, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, cmdArg.verb, cmdArg.args)
+		completionResults, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, nil, cmdArg.verb, cmdArg.args)
 		if err != nil {
 			t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
 		}
@@ -100,7 +107,7 @@ func TestProcessLabelCompletion(t *testing.T) {
 		{"get", []string{"pods", "--selector="}},
 	}
 	for _, cmdArg := range cmdArgs {
-		completionResults, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, cmdArg.verb, cmdArg.args)
+		completionResults, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, nil, cmdArg.verb, cmdArg.args)
 		if err != nil {
 			t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
 		}
@@ -123,7 +130,7 @@ func TestProcessFieldSelectorCompletion(t *testing.T) {
 		{"get", []string{"pods", "--field-selector="}},
 	}
 	for _, cmdArg := range cmdArgs {
-		completionResults, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, cmdArg.verb, cmdArg.args)
+		completionResults, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, nil, cmdArg.verb, cmdArg.args)
 		if err != nil {
 			t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
 		}
@@ -147,7 +154,7 @@ func TestUnmanagedCompletion(t *testing.T) {
 		{"get", []string{"pods", "aPod", ">", "/tmp"}},
 	}
 	for _, cmdArg := range cmdArgs {
-		_, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, cmdArg.verb, cmdArg.args)
+		_, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, nil, cmdArg.verb, cmdArg.args)
 		if err == nil {
 			t.Fatalf("expected unmanaged error for cmdArgs %v", cmdArg)
 		}
@@ -174,7 +181,7 @@ func TestManagedCompletion(t *testing.T) {
 		{"get", []string{"pods", "--all-namespaces", ""}},
 	}
 	for _, cmdArg := range cmdArgs {
-		completionResults, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, cmdArg.verb, cmdArg.args)
+		completionResults, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, nil, cmdArg.verb, cmdArg.args)
 		if err != nil {
 			t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
 		}
@@ -202,6 +209,46 @@ func TestPodCompletionFile(t *testing.T) {
 	}
 }
 
+func TestColoredOutput(t *testing.T) {
+	fetchConfig := fetchertest.GetTestFetcherWithDefaults(t)
+	completionResult, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, nil, "get", []string{"nodes", " "})
+	if err != nil {
+		t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
+	}
+	plain := completionResult.GetFormattedOutput()
+	completionResult.Color = true
+	colored := completionResult.GetFormattedOutput()
+	if !strings.Contains(colored, "\x1b[32mReady\x1b[0m") {
+		t.Fatalf("expected ready node in green, got %q", colored)
+	}
+	// Colours are added once the columns are aligned
+	if util.StripColors(colored) != plain {
+		t.Fatalf("expected the same columns without colours, got %q, want %q", util.StripColors(colored), plain)
+	}
+}
+
+func TestSelectedColumns(t *testing.T) {
+	store := config.NewStore()
+	store.SetStringSlice("columns.nodes", []string{"Status", "Age"})
+	completionCli := NewCompletionCli(store)
+	if err := completionCli.ConfigureColumns(); err != nil {
+		t.Fatalf("ConfigureColumns() error = %v", err)
+	}
+	fetchConfig := fetchertest.GetTestFetcherWithDefaults(t)
+	completionResult, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, completionCli.Columns, "get", []string{"nodes", " "})
+	if err != nil {
+		t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
+	}
+	if completionResult.Header != "Name\tStatus\tAge" {
+		t.Fatalf("unexpected header %q", completionResult.Header)
+	}
+	completionResult.Color = true
+	lines := strings.Split(completionResult.GetFormattedOutput(), "\n")
+	if fields := strings.Fields(lines[2]); len(fields) != 3 || fields[1] != "\x1b[32mReady\x1b[0m" {
+		t.Fatalf("expected colored status in the second column, got %q", lines[2])
+	}
+}
+
 func TestNamespaceFilterFile(t *testing.T) {
 	fetchConfig := fetchertest.GetTestFetcherWithDefaults(t)
 
@@ -322,8 +369,8 @@ func TestHttpServerCachePod(t *testing.T) {
 	if _, statErr := os.Stat(podCache); statErr != nil {
 		t.Fatalf("expected pod cache file to exist: %v", statErr)
 	}
-	if fzfHttpServer.ResourceHit != 1 {
-		t.Fatalf("expected ResourceHit to be 1, got %d", fzfHttpServer.ResourceHit)
+	if fzfHttpServer.ResourceHits() != 1 {
+		t.Fatalf("expected ResourceHits to be 1, got %d", fzfHttpServer.ResourceHits())
 	}
 	fetcher_state := path.Join(tempDir, "fetcher_state")
 	if _, statErr := os.Stat(fetcher_state); statErr != nil {
@@ -335,7 +382,331 @@ func TestHttpServerCachePod(t *testing.T) {
 		t.Fatalf("getResourceCompletion() error = %v", err)
 	}
 	_ = res
-	if fzfHttpServer.ResourceHit != 1 {
-		t.Fatalf("expected ResourceHit to remain 1, got %d", fzfHttpServer.ResourceHit)
+	if fzfHttpServer.ResourceHits() != 1 {
+		t.Fatalf("expected ResourceHits to remain 1, got %d", fzfHttpServer.ResourceHits())
+	}
+}
+
+func TestStaleCacheCompletion(t *testing.T) {
+	fzfHttpServer := httpservertest.StartTestHttpServer(t)
+	f, tempDir := fetchertest.GetTestFetcher(t, "nothing", fzfHttpServer.Port)
+	completionCli := &CompletionCli{StaleThreshold: time.Hour, RefuseStaleVerbs: []string{"delete"}}
+	completionResult, err := ProcessCommandArgs("get", []string{"pods", ""}, f, completionCli)
+	if err != nil {
+		t.Fatalf("ProcessCommandArgs() error = %v", err)
+	}
+	if !completionResult.Freshness.ServerRunning {
+		t.Fatalf("expected data from a running server, got %+v", completionResult.Freshness)
+	}
+	if line := strings.Split(completionResult.GetFormattedOutput(), "\n")[0]; line != "Cluster: nothing" {
+		t.Fatalf("unexpected cluster line %q", line)
+	}
+
+	// The server is gone, the cache was last updated 3 hours ago
+	podCache := path.Join(tempDir, "nothing", resources.ResourceTypePod.String())
+	lastUpdate := time.Now().Add(-3*time.Hour - time.Minute)
+	if err := os.Chtimes(podCache, lastUpdate, lastUpdate); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+	newStaleFetcher := func() *fetcher.Fetcher {
+		return fetcher.NewFetcher(&fetcher.FetcherCli{
+			FetcherCachePath: tempDir,
+			ClusterConfigCli: &clusterconfig.ClusterConfigCli{ClusterName: "nothing", CacheDir: "testdata"},
+			HttpEndpoint:     "localhost:1",
+		})
+	}
+	completionResult, err = ProcessCommandArgs("get", []string{"pods", ""}, newStaleFetcher(), completionCli)
+	if err != nil {
+		t.Fatalf("ProcessCommandArgs() error = %v", err)
+	}
+	if len(completionResult.Completions) != 7 {
+		t.Fatalf("expected 7 cached pod completions, got %d", len(completionResult.Completions))
+	}
+	expectedLine := "Cluster: nothing — data 3h old, server not running"
+	if line := strings.Split(completionResult.GetFormattedOutput(), "\n")[0]; line != expectedLine {
+		t.Fatalf("expected cluster line %q, got %q", expectedLine, line)
+	}
+
+	_, err = ProcessCommandArgs("delete", []string{"pods", ""}, newStaleFetcher(), completionCli)
+	staleErr := StaleDataError{}
+	if !errors.As(err, &staleErr) || staleErr.Verb != "delete" {
+		t.Fatalf("expected StaleDataError, got %v", err)
+	}
+
+	// A recent cache isn't reused once the local server stopped its heartbeat
+	serverDir := t.TempDir()
+	heartbeatPath := path.Join(serverDir, "nothing", ".heartbeat")
+	if err := os.MkdirAll(path.Dir(heartbeatPath), 0o700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.WriteFile(heartbeatPath, nil, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	lastHeartbeat := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(heartbeatPath, lastHeartbeat, lastHeartbeat); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+	now := time.Now()
+	if err := os.Chtimes(podCache, now, now); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+	completionResult, err = ProcessCommandArgs("get", []string{"pods", ""}, fetcher.NewFetcher(&fetcher.FetcherCli{
+		FetcherCachePath: tempDir,
+		ClusterConfigCli: &clusterconfig.ClusterConfigCli{ClusterName: "nothing", CacheDir: serverDir},
+		HttpEndpoint:     "localhost:1",
+		MinimumCache:     time.Hour,
+	}), completionCli)
+	if err != nil {
+		t.Fatalf("ProcessCommandArgs() error = %v", err)
+	}
+	if completionResult.Freshness.ServerRunning {
+		t.Fatalf("expected data from a stopped server, got %+v", completionResult.Freshness)
+	}
+
+	// Errors of a reachable server aren't hidden behind the cache
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+	_, err = ProcessCommandArgs("get", []string{"pods", ""}, fetcher.NewFetcher(&fetcher.FetcherCli{
+		FetcherCachePath: tempDir,
+		ClusterConfigCli: &clusterconfig.ClusterConfigCli{ClusterName: "nothing", CacheDir: "testdata"},
+		HttpEndpoint:     unauthorized.Listener.Addr().String(),
+	}), completionCli)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected the unauthorized error, got %v", err)
+	}
+}
+
+func TestAutostartServer(t *testing.T) {
+	cacheDir := t.TempDir()
+	argsPath := path.Join(cacheDir, "args")
+	serverBin := path.Join(cacheDir, "fake-server")
+	script := "#!/bin/sh\necho \"$@\" > " + argsPath + ".tmp && mv " + argsPath + ".tmp " + argsPath + "\n"
+	if err := os.WriteFile(serverBin, []byte(script), 0o700); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	f := fetcher.NewFetcher(&fetcher.FetcherCli{
+		FetcherCachePath:     t.TempDir(),
+		ClusterConfigCli:     &clusterconfig.ClusterConfigCli{ClusterName: "nothing", CacheDir: cacheDir},
+		HttpEndpoint:         "localhost:1",
+		AutostartServer:      true,
+		ServerBin:            serverBin,
+		AutostartIdleTimeout: time.Minute,
+	})
+	_, err := getResourceCompletion(context.Background(), resources.ResourceTypePod, nil, f)
+	if err == nil {
+		t.Fatalf("expected error while the server starts")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var args []byte
+	for time.Now().Before(deadline) {
+		if args, err = os.ReadFile(argsPath); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expectedArgs := "--cache-dir " + cacheDir + " --listen-address localhost:1 --idle-timeout 1m0s\n"
+	if string(args) != expectedArgs {
+		t.Fatalf("expected server started with %q, got %q (%v)", expectedArgs, args, err)
+	}
+}
+
+func TestList(t *testing.T) {
+	fetchConfig := fetchertest.GetTestFetcherWithDefaults(t)
+	completionCli := &CompletionCli{ReloadTypes: []string{"pods", "deployments", "nodes"}}
+	completionResult, err := processCommandArgsWithFetchConfig(context.Background(), fetchConfig, nil, "get", []string{"pods", " "})
+	if err != nil {
+		t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
+	}
+	if !completionResult.Reloadable {
+		t.Fatalf("expected pods completion to be reloadable")
+	}
+	state := &ListState{ResourceType: completionResult.ResourceType, Namespace: completionResult.Namespace}
+	sessionDir := t.TempDir()
+	if err := state.Save(sessionDir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	state, err = LoadListState(sessionDir)
+	if err != nil || state == nil || state.ResourceType != resources.ResourceTypePod {
+		t.Fatalf("LoadListState() = %v, %v", state, err)
+	}
+
+	// Without context namespace, the cycle goes through the namespaces of
+	// the pods then back to all namespaces
+	namespaces := []string{}
+	for i := 0; i < 3; i++ {
+		completionResult, err = List(context.Background(), fetchConfig, completionCli, state, ListActionCycleNamespace)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if state.Namespace == nil {
+			break
+		}
+		namespaces = append(namespaces, *state.Namespace)
+		for _, comp := range completionResult.Completions {
+			if !strings.HasPrefix(comp, *state.Namespace+"\t") {
+				t.Fatalf("expected pods of %s, got %s", *state.Namespace, comp)
+			}
+		}
+	}
+	if !reflect.DeepEqual(namespaces, []string{"kube-system"}) || state.Namespace != nil {
+		t.Fatalf("unexpected namespace cycle %v, ending with %v", namespaces, state.Namespace)
+	}
+	if line := strings.Split(completionResult.GetFormattedOutput(), "\n")[0]; !strings.HasPrefix(line, "Cluster: minikube, pods in all namespaces") {
+		t.Fatalf("unexpected cluster line %q", line)
+	}
+
+	completionResult, err = List(context.Background(), fetchConfig, completionCli, state, ListActionCycleType)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if state.ResourceType != resources.ResourceTypeDeployment || completionResult.Header != resources.ResourceToHeader(resources.ResourceTypeDeployment) {
+		t.Fatalf("expected deployments, got %s", state.ResourceType)
+	}
+}
+
+func TestReplaceResourceType(t *testing.T) {
+	res, ok := ReplaceResourceType("get", []string{"po", "-n", "ns1", "web"}, resources.ResourceTypeDeployment)
+	if !ok || !reflect.DeepEqual(res, []string{"deployments", "-n", "ns1", "web"}) {
+		t.Fatalf("ReplaceResourceType() = %v, %t", res, ok)
+	}
+	res, ok = ReplaceResourceType("get", []string{"-n", "jobs", "po", "web"}, resources.ResourceTypeDeployment)
+	if !ok || !reflect.DeepEqual(res, []string{"-n", "jobs", "deployments", "web"}) {
+		t.Fatalf("ReplaceResourceType() = %v, %t", res, ok)
+	}
+	if _, ok := ReplaceResourceType("exec", []string{"-ti", " "}, resources.ResourceTypeDeployment); ok {
+		t.Fatalf("expected exec not to switch type")
+	}
+}
+
+func TestHistoryRanking(t *testing.T) {
+	cachePath := t.TempDir()
+	f := fetchertest.GetTestFetcherWithDefaults(t, fetchertest.WithCachePath(cachePath), fetchertest.WithHistory())
+	completionResult, err := processCommandArgsWithFetchConfig(context.Background(), f, nil, "get", []string{"pods", " "})
+	if err != nil {
+		t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
+	}
+	if !strings.HasPrefix(completionResult.Header, "Rank\tNamespace\tName") {
+		t.Fatalf("unexpected header %s", completionResult.Header)
+	}
+	last := completionResult.Completions[len(completionResult.Completions)-1]
+	if !strings.HasPrefix(last, "-\t") {
+		t.Fatalf("expected no history marker, got %s", last)
+	}
+	// fzf returns the line aligned with spaces
+	formattedLines := strings.Split(completionResult.GetFormattedOutput(), "\n")
+	completionResult.RecordSelection(f, formattedLines[len(completionResult.Completions)+1])
+	if err := f.SaveFetcherState(); err != nil {
+		t.Fatalf("SaveFetcherState() error = %v", err)
+	}
+
+	// The selected pod now comes first, from the history on disk
+	f = fetchertest.GetTestFetcherWithDefaults(t, fetchertest.WithCachePath(cachePath), fetchertest.WithHistory())
+	completionResult, err = processCommandArgsWithFetchConfig(context.Background(), f, nil, "get", []string{"pods", " "})
+	if err != nil {
+		t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
+	}
+	if first := completionResult.Completions[0]; first != "+\t"+strings.TrimPrefix(last, "-\t") {
+		t.Fatalf("expected %s first, got %s", last, first)
+	}
+	if header, line := StripMarkerColumn("Rank Namespace Name", "+  ns1  web"); header != "Namespace Name" || line != "ns1  web" {
+		t.Fatalf("StripMarkerColumn() = %q, %q", header, line)
+	}
+}
+
+func TestPins(t *testing.T) {
+	cachePath := t.TempDir()
+	f := fetchertest.GetTestFetcherWithDefaults(t, fetchertest.WithCachePath(cachePath))
+	completionResult, err := processCommandArgsWithFetchConfig(context.Background(), f, nil, "get", []string{"pods", " "})
+	if err != nil {
+		t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
+	}
+	if completionResult.MarkerColumn || strings.HasPrefix(completionResult.Header, "Rank") {
+		t.Fatalf("expected no marker column without pins, got %s", completionResult.Header)
+	}
+	last := completionResult.Completions[len(completionResult.Completions)-1]
+	fields := strings.Split(last, "\t")
+	pin, err := ParsePinnedResource(f, "po", fields[0]+"/"+fields[1])
+	if err != nil {
+		t.Fatalf("ParsePinnedResource() error = %v", err)
+	}
+	if !f.GetPins().AddResource(pin) {
+		t.Fatalf("expected %s to be added", pin)
+	}
+	query, err := ParseLabelQuery("tier=control-plane, k8s-app!=kube-dns")
+	if err != nil {
+		t.Fatalf("ParseLabelQuery() error = %v", err)
+	}
+	f.GetPins().AddLabelQuery(query)
+	if err := f.SaveFetcherState(); err != nil {
+		t.Fatalf("SaveFetcherState() error = %v", err)
+	}
+
+	// The pins are read from disk and come first, marked with a star
+	f = fetchertest.GetTestFetcherWithDefaults(t, fetchertest.WithCachePath(cachePath))
+	completionResult, err = processCommandArgsWithFetchConfig(context.Background(), f, nil, "get", []string{"pods", " "})
+	if err != nil {
+		t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
+	}
+	if !strings.HasPrefix(completionResult.Header, "Rank\tNamespace\tName") {
+		t.Fatalf("unexpected header %s", completionResult.Header)
+	}
+	if first := completionResult.Completions[0]; first != "*\t"+last {
+		t.Fatalf("expected %s first, got %s", last, first)
+	}
+	if second := completionResult.Completions[1]; !strings.HasPrefix(second, "-\t") {
+		t.Fatalf("expected no marker, got %s", second)
+	}
+
+	completionResult, err = processCommandArgsWithFetchConfig(context.Background(), f, nil, "get", []string{"pods", "-l", " "})
+	if err != nil {
+		t.Fatalf("processCommandArgsWithFetchConfig() error = %v", err)
+	}
+	if completionResult.Header != "Rank\tNamespace\tLabel\tOccurrences" || !completionResult.MarkerColumn {
+		t.Fatalf("unexpected label header %s", completionResult.Header)
+	}
+	if first := completionResult.Completions[0]; first != "*\tkube-system\t"+query+"\t4" {
+		t.Fatalf("expected saved query first, got %s", first)
+	}
+	if second := completionResult.Completions[1]; second != "-\tkube-system\ttier=control-plane\t4" {
+		t.Fatalf("unexpected second label completion %s", second)
+	}
+}
+
+func TestRecordSelectionWithSpaces(t *testing.T) {
+	f := fetchertest.GetTestFetcherWithDefaults(t, fetchertest.WithCachePath(t.TempDir()), fetchertest.WithHistory())
+	completionResult := &CompletionResult{
+		Header:       "Rank\tDescription\tNamespace\tName",
+		Completions:  []string{"-\tfront end\tns1\tweb", "-\tapi\tns2\tapi"},
+		ResourceType: resources.ResourceTypePod,
+		MarkerColumn: true,
+		resourceMap:  map[string]resources.K8sResource{"ns1_web": &resources.Pod{}, "ns2_api": &resources.Pod{}},
+	}
+	formattedLines := strings.Split(completionResult.GetFormattedOutput(), "\n")
+	completionResult.RecordSelection(f, formattedLines[2])
+	now := time.Now()
+	if f.GetFrecency("pods/ns1/web", now) == 0 || f.GetFrecency("pods/ns2/api", now) != 0 {
+		t.Fatalf("expected only pods/ns1/web to be recorded")
+	}
+}
+
+func TestHistoryKey(t *testing.T) {
+	pod := &resources.Pod{ResourceMeta: resources.ResourceMeta{Labels: map[string]string{"pod-template-hash": "6d4b75cb6d"}}}
+	key := historyKey(resources.ResourceTypePod, "kube-system_coredns-6d4b75cb6d-m6m4q", pod)
+	if key != "pods/kube-system/coredns-6d4b75cb6d" {
+		t.Fatalf("historyKey() = %s", key)
+	}
+	pod.Labels = nil
+	if key := historyKey(resources.ResourceTypePod, "default_web-0", pod); key != "pods/default/web-0" {
+		t.Fatalf("historyKey() = %s", key)
+	}
+
+	now := time.Now()
+	recent := fetcher.HistoryEntry{Count: 1, LastUsed: now.Add(-time.Minute)}
+	old := fetcher.HistoryEntry{Count: 10, LastUsed: now.Add(-30 * 24 * time.Hour)}
+	if recent.Frecency(now) != 4 || old.Frecency(now) != 2.5 {
+		t.Fatalf("unexpected frecencies %f, %f", recent.Frecency(now), old.Frecency(now))
 	}
 }
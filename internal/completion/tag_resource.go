@@ -9,6 +9,9 @@ import (
 	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
 	"golang.org/x/net/context"
+	"k8s.io/apimachinery/pkg/labels"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
 )
 
 type TagType int64
@@ -78,22 +81,81 @@ func getTagResourceOccurrences(ctx context.Context, r resources.ResourceType, na
 	return resourceKeyToOccurrences, nil
 }
 
+// getSavedQueryOccurrences returns the number of resources matching each
+// label query saved in the context, per namespace
+// Invalid queries are skipped.
+func getSavedQueryOccurrences(ctx context.Context, r resources.ResourceType, namespace *string,
+	fetchConfig *fetcher.Fetcher) (map[TagResourceKey]int, error) {
+	queryOccurrences := make(map[TagResourceKey]int, 0)
+	queries := fetchConfig.GetPins().LabelQueries
+	if len(queries) == 0 {
+		return queryOccurrences, nil
+	}
+	resources, err := fetchConfig.GetResources(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	for _, query := range queries {
+		selector, err := labels.Parse(query)
+		if err != nil {
+			log.Debugf("Skipping invalid label query %s: %s", query, err)
+			continue
+		}
+		for _, resource := range resources {
+			if namespace != nil && *namespace != resource.GetNamespace() {
+				continue
+			}
+			if selector.Matches(labels.Set(resource.GetLabels())) {
+				queryOccurrences[TagResourceKey{resource.GetNamespace(), query}] += 1
+			}
+		}
+	}
+	return queryOccurrences, nil
+}
+
+func sortedTagResourcePairs(occurrences map[TagResourceKey]int) TagResourcePairList {
+	tagResourcePairList := make(TagResourcePairList, 0)
+	for k, occurrence := range occurrences {
+		tagResourcePairList = append(tagResourcePairList, TagResourcePair{k, occurrence})
+	}
+	sort.Sort(tagResourcePairList)
+	return tagResourcePairList
+}
+
+// GetTagResourceCompletion returns the header and completions of the labels
+// or field selectors of r
+// Label completions start with the label queries saved in the context. With
+// the marker column, the first column marks them as pinned.
 func GetTagResourceCompletion(ctx context.Context, r resources.ResourceType, namespace *string,
 	fetchConfig *fetcher.Fetcher, tagType TagType) (string, []string, error) {
 	tagResourceOccurrencesMap, err := getTagResourceOccurrences(ctx, r, namespace, fetchConfig, tagType)
 	if err != nil {
 		return "", nil, err
 	}
-	tagResourcePairList := make(TagResourcePairList, 0)
-	for k, occurrence := range tagResourceOccurrencesMap {
-		tagResourcePairList = append(tagResourcePairList, TagResourcePair{k, occurrence})
+	savedQueryOccurrencesMap := make(map[TagResourceKey]int, 0)
+	if tagType == TagTypeLabel {
+		savedQueryOccurrencesMap, err = getSavedQueryOccurrences(ctx, r, namespace, fetchConfig)
+		if err != nil {
+			return "", nil, err
+		}
 	}
-	sort.Sort(tagResourcePairList)
 
 	isNamespaced := r.IsNamespaced()
+	withMarkers := markerEnabled(fetchConfig)
 	labelComps := make([]string, 0)
-	for _, labelPair := range tagResourcePairList {
-		labelComps = append(labelComps, labelPair.ToString(isNamespaced))
+	for _, labelPair := range sortedTagResourcePairs(savedQueryOccurrencesMap) {
+		labelComp := labelPair.ToString(isNamespaced)
+		if withMarkers {
+			labelComp = fmt.Sprintf("%s\t%s", pinnedMarker, labelComp)
+		}
+		labelComps = append(labelComps, labelComp)
+	}
+	for _, labelPair := range sortedTagResourcePairs(tagResourceOccurrencesMap) {
+		labelComp := labelPair.ToString(isNamespaced)
+		if withMarkers {
+			labelComp = fmt.Sprintf("%s\t%s", noMarker, labelComp)
+		}
+		labelComps = append(labelComps, labelComp)
 	}
 
 	labelHeaders := []string{"Occurrences"}
@@ -105,6 +167,6 @@ func GetTagResourceCompletion(ctx context.Context, r resources.ResourceType, nam
 	if isNamespaced {
 		labelHeaders = append([]string{"Namespace"}, labelHeaders...)
 	}
-	labelHeaderStr := strings.Join(labelHeaders, "\t")
+	labelHeaderStr := withMarker(fetchConfig, strings.Join(labelHeaders, "\t"))
 	return labelHeaderStr, labelComps, nil
 }
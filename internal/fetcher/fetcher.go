@@ -3,11 +3,17 @@ package fetcher
 import (
 	"context"
 	"fmt"
+	"net"
+	"syscall"
 	"time"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
 	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	"github.com/pkg/errors"
 )
 
 // Fetcher defines configuration to fetch completion datas
@@ -16,7 +22,16 @@ type Fetcher struct {
 	fetcherCachePath string
 	httpEndpoint     string
 	minimumCache     time.Duration
+	httpClientConfig util.HttpClientConfig
 	fetcherState     FetcherState
+	freshness        *Freshness
+	historyEnabled   bool
+	history          *History
+	pins             *Pins
+
+	autostartServer      bool
+	serverBin            string
+	autostartIdleTimeout time.Duration
 }
 
 func NewFetcher(fetchConfigCli *FetcherCli) *Fetcher {
@@ -25,7 +40,13 @@ func NewFetcher(fetchConfigCli *FetcherCli) *Fetcher {
 		httpEndpoint:     fetchConfigCli.HttpEndpoint,
 		fetcherCachePath: fetchConfigCli.FetcherCachePath,
 		minimumCache:     fetchConfigCli.MinimumCache,
+		httpClientConfig: fetchConfigCli.HttpClientConfig,
 		fetcherState:     *newFetcherState(fetchConfigCli.FetcherCachePath),
+		historyEnabled:   fetchConfigCli.History,
+
+		autostartServer:      fetchConfigCli.AutostartServer,
+		serverBin:            fetchConfigCli.ServerBin,
+		autostartIdleTimeout: fetchConfigCli.AutostartIdleTimeout,
 	}
 	return &f
 }
@@ -35,16 +56,40 @@ func (f *Fetcher) LoadFetcherState() error {
 	if err != nil {
 		return err
 	}
+	if f.historyEnabled {
+		f.history = newHistory(f.fetcherCachePath, f.GetContext())
+		if err := f.history.loadFromDisk(); err != nil {
+			// A corrupted history only disables the ranking
+			log.Warnf("Error loading history %s: %s", f.history.historyPath, err)
+			f.history = nil
+		}
+	}
+	f.pins = newPins(f.fetcherCachePath, f.GetContext())
+	if err := f.pins.loadFromDisk(); err != nil {
+		// A corrupted pins file only hides the pins, pinning again
+		// rewrites it
+		log.Warnf("Error loading pins %s: %s", f.pins.pinsPath, err)
+		f.pins = newPins(f.fetcherCachePath, f.GetContext())
+	}
 	return f.fetcherState.loadStateFromDisk()
 }
 
 func (f *Fetcher) SaveFetcherState() error {
+	if f.history != nil {
+		if err := f.history.writeToDisk(); err != nil {
+			return err
+		}
+	}
+	if f.pins != nil {
+		if err := f.pins.writeToDisk(); err != nil {
+			return err
+		}
+	}
 	return f.fetcherState.writeToDisk()
 }
 
-func loadResourceFromFile(filePath string) (map[string]resources.K8sResource, error) {
-	resources := map[string]resources.K8sResource{}
-	err := util.LoadGobFromFile(&resources, filePath)
+func loadResourceFromFile(filePath string, r resources.ResourceType) (map[string]resources.K8sResource, error) {
+	resources, _, err := store.LoadResourcesFromFile(filePath, r)
 	return resources, err
 }
 
@@ -61,11 +106,69 @@ func (f *Fetcher) GetResources(ctx context.Context, r resources.ResourceType) (m
 	}
 
 	// Fetch remote
-	if util.IsAddressReachable(f.httpEndpoint) {
-		return f.loadResourceFromHttpServer(f.httpEndpoint, r)
+	resources, err = f.fetchRemote(r)
+	if err != nil {
+		// Keep completing from the cache while the server is down, other
+		// errors, e.g. denied access, aren't hidden behind stale data
+		if !isUnreachable(err) {
+			return nil, err
+		}
+		if _, unavailable := err.(EndpointUnavailableError); unavailable && f.autostartServer {
+			if startErr := f.startServer(); startErr != nil {
+				log.Warnf("Error starting server: %s", startErr)
+			}
+		}
+		cached, cacheErr := f.checkStaleCache(r)
+		if cached != nil && cacheErr == nil {
+			log.Warnf("Using cached %s: %s", r, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	f.recordFreshness(Freshness{UpdatedAt: time.Now(), ServerRunning: true})
+	return resources, nil
+}
+
+func (f *Fetcher) fetchRemote(r resources.ResourceType) (map[string]resources.K8sResource, error) {
+	endpoint, err := f.getHttpEndpoint()
+	if err != nil {
+		return nil, err
 	}
+	client, err := endpoint.Client(f.httpClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return f.loadResourceFromHttpServer(endpoint, client, r)
+}
+
+// EndpointUnavailableError is returned when the http endpoint is not
+// configured or can't be reached
+type EndpointUnavailableError string
+
+func (e EndpointUnavailableError) Error() string {
+	return string(e)
+}
+
+// isUnreachable returns true when err means the server can't be reached: the
+// endpoint isn't configured, is down or refuses the connection
+func isUnreachable(err error) bool {
+	var unavailable EndpointUnavailableError
+	var opErr *net.OpError
+	return errors.As(err, &unavailable) || errors.Is(err, syscall.ECONNREFUSED) ||
+		(errors.As(err, &opErr) && opErr.Op == "dial")
+}
+
+// getHttpEndpoint returns the configured http endpoint if it's reachable
+func (f *Fetcher) getHttpEndpoint() (util.HttpEndpoint, error) {
 	if f.httpEndpoint == "" {
-		return nil, fmt.Errorf("http endpoint not configured; run kubectl-fzf-server locally or provide --http-endpoint")
+		return util.HttpEndpoint{}, EndpointUnavailableError("http endpoint not configured; run kubectl-fzf-server locally or provide --http-endpoint")
+	}
+	endpoint, err := util.ParseHttpEndpoint(f.httpEndpoint)
+	if err != nil {
+		return endpoint, err
+	}
+	if !endpoint.IsReachable() {
+		return endpoint, EndpointUnavailableError(fmt.Sprintf("http endpoint %s is not reachable", f.httpEndpoint))
 	}
-	return nil, fmt.Errorf("http endpoint %s is not reachable", f.httpEndpoint)
+	return endpoint, nil
 }
@@ -0,0 +1,81 @@
+package fetcher
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	"github.com/pkg/errors"
+)
+
+// touchLastUsed tells a local server that its files are used, keeping it
+// from exiting on idle timeout
+func (f *Fetcher) touchLastUsed() {
+	lastUsedPath := f.GetLastUsedPath()
+	now := time.Now()
+	err := os.Chtimes(lastUsedPath, now, now)
+	if os.IsNotExist(err) {
+		err = os.WriteFile(lastUsedPath, nil, 0o600)
+	}
+	if err != nil {
+		log.Debugf("Error touching %s: %s", lastUsedPath, err)
+	}
+}
+
+// serverListenAddress returns the listen address of a started server: the
+// configured endpoint when it's local, otherwise the server only writes files
+func (f *Fetcher) serverListenAddress() string {
+	if f.httpEndpoint == "" {
+		return ""
+	}
+	endpoint, err := util.ParseHttpEndpoint(f.httpEndpoint)
+	if err != nil || endpoint.TLS || !endpoint.IsLoopback() {
+		return ""
+	}
+	return f.httpEndpoint
+}
+
+// startServer spawns a detached server for the current context unless
+// one is already running
+// The server outlives the completion: it exits after autostartIdleTimeout
+// without completion. Its output goes to kubectl-fzf-server.log in the cache
+// root.
+func (f *Fetcher) startServer() error {
+	if util.IsPidAlive(f.GetServerPidPath()) {
+		log.Infof("A server is already running, waiting for its files")
+		return nil
+	}
+	serverBin, err := exec.LookPath(f.serverBin)
+	if err != nil {
+		return errors.Wrapf(err, "error looking for %s", f.serverBin)
+	}
+	cacheRoot := filepath.Dir(f.GetServerLockPath())
+	if err := os.MkdirAll(cacheRoot, 0o700); err != nil {
+		return errors.Wrap(err, "error creating cache root")
+	}
+	logPath := filepath.Join(cacheRoot, "kubectl-fzf-server.log")
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "error opening server log")
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(serverBin,
+		"--cache-dir", cacheRoot,
+		"--listen-address", f.serverListenAddress(),
+		"--idle-timeout", f.autostartIdleTimeout.String())
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	// Detach the server from the shell running the completion
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return errors.Wrapf(err, "error starting %s", serverBin)
+	}
+	log.Infof("Started %s with pid %d, logs in %s", serverBin, cmd.Process.Pid, logPath)
+	return cmd.Process.Release()
+}
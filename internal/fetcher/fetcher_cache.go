@@ -1,7 +1,6 @@
 package fetcher
 
 import (
-	"net/http"
 	"os"
 	"path"
 	"time"
@@ -13,17 +12,6 @@ import (
 	"github.com/pkg/errors"
 )
 
-const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
-
-func getLastModifiedFromHeader(headers http.Header) (time.Time, error) {
-	lastModifiedStr := headers.Get("Last-Modified")
-	lastModifiedTime, err := time.Parse(TimeFormat, lastModifiedStr)
-	if err != nil {
-		return lastModifiedTime, errors.Wrap(err, "invalid lastModified timestamp")
-	}
-	return lastModifiedTime, nil
-}
-
 func (f *Fetcher) createCacheDir() (string, error) {
 	cacheDir := path.Join(f.fetcherCachePath, f.GetContext())
 	log.Infof("Creating cache dir %s", cacheDir)
@@ -34,34 +22,26 @@ func (f *Fetcher) createCacheDir() (string, error) {
 	return cacheDir, nil
 }
 
-func (f *Fetcher) writeResourceToCache(headers http.Header, b []byte, r resources.ResourceType) error {
-	cacheDir, err := f.createCacheDir()
-	if err != nil {
+func (f *Fetcher) getCacheFilePath(r resources.ResourceType) string {
+	return path.Join(f.fetcherCachePath, f.GetContext(), r.String())
+}
+
+func (f *Fetcher) writeResourceToCache(etag string, b []byte, r resources.ResourceType) error {
+	if _, err := f.createCacheDir(); err != nil {
 		return err
 	}
-	resourcePath := path.Join(cacheDir, r.String())
+	resourcePath := f.getCacheFilePath(r)
 	log.Debugf("Caching resource in %s", resourcePath)
-	err = os.WriteFile(resourcePath, b, 0o600)
+	err := util.WriteBytesAtomic(resourcePath, b, 0o600)
 	if err != nil {
 		return errors.Wrap(err, "error writing cache file")
 	}
-	lastModifiedTime, err := getLastModifiedFromHeader(headers)
-	if err != nil {
-		return err
-	}
-	f.fetcherState.updateLastModifiedTimes(f.GetContext(), r, lastModifiedTime)
+	f.fetcherState.updateETag(f.GetContext(), r, etag)
 	return nil
 }
 
-func (f *Fetcher) getResourceFromCache(r resources.ResourceType) (map[string]resources.K8sResource, error) {
-	cacheFile := path.Join(f.fetcherCachePath, f.GetContext(), r.String())
-	resources := map[string]resources.K8sResource{}
-	err := util.LoadGobFromFile(&resources, cacheFile)
-	return resources, err
-}
-
 func (f *Fetcher) checkRecentCache(r resources.ResourceType) (map[string]resources.K8sResource, error) {
-	cacheFile := path.Join(f.fetcherCachePath, f.GetContext(), r.String())
+	cacheFile := f.getCacheFilePath(r)
 	finfo, err := os.Stat(cacheFile)
 	if err != nil {
 		log.Infof("No cache file %s present", cacheFile)
@@ -71,48 +51,31 @@ func (f *Fetcher) checkRecentCache(r resources.ResourceType) (map[string]resourc
 	// A cache file is present
 	deltaMod := time.Now().Sub(finfo.ModTime())
 	if deltaMod <= f.minimumCache {
+		if f.heartbeatStopped() {
+			log.Infof("Cache file present but the local server stopped, querying it")
+			return nil, nil
+		}
 		log.Infof("Cache file present and was modified %s ago, using it", deltaMod)
-		resources := map[string]resources.K8sResource{}
-		err := util.LoadGobFromFile(&resources, cacheFile)
-		return resources, err
+		f.recordFreshness(Freshness{UpdatedAt: finfo.ModTime(), ServerRunning: true})
+		return loadResourceFromFile(cacheFile, r)
 	}
 	return nil, nil
 }
 
-func (f *Fetcher) checkHttpCache(endpoint string, r resources.ResourceType) (map[string]resources.K8sResource, error) {
-	cacheFile := path.Join(f.fetcherCachePath, f.GetContext(), r.String())
+// checkStaleCache returns the cached resources regardless of their age, used
+// when the http endpoint can't be reached
+// The cache file is touched on each successful request: its modification time
+// is the last time the resources were up to date.
+func (f *Fetcher) checkStaleCache(r resources.ResourceType) (map[string]resources.K8sResource, error) {
+	cacheFile := f.getCacheFilePath(r)
 	finfo, err := os.Stat(cacheFile)
 	if err != nil {
-		log.Infof("No cache file %s present", cacheFile)
 		return nil, nil
 	}
-
-	// A cache file is present
-	deltaMod := time.Now().Sub(finfo.ModTime())
-	resources := map[string]resources.K8sResource{}
-	if deltaMod <= f.minimumCache {
-		log.Infof("Cache file present and was modified %s ago, using it", deltaMod)
-		err := util.LoadGobFromFile(&resources, cacheFile)
-		return resources, err
-	}
-
-	localLastModified := f.fetcherState.getLastModifiedTime(f.GetContext(), r)
-	if localLastModified != nil {
-		resourcePath := f.getResourceHttpPath(endpoint, r)
-		headers, err := util.HeadFromHttpServer(resourcePath)
-		if err != nil {
-			return nil, errors.Wrapf(err, "error on head of %s", resourcePath)
-		}
-		lastModifiedTime, err := getLastModifiedFromHeader(headers)
-		// No change, load from cache file
-		if lastModifiedTime == *localLastModified {
-			log.Infof("Cache has the same modified time %s, pulling %s data from local files", localLastModified, r)
-			err = util.LoadGobFromFile(&resources, cacheFile)
-			return resources, err
-		}
-		log.Infof("Resource %s was modified on server, pulling new version: old modified time %s, new modified time %s", r, localLastModified, lastModifiedTime)
-	} else {
-		log.Infof("No modified times for %s, pulling it from server", r)
+	resources, err := loadResourceFromFile(cacheFile, r)
+	if err != nil {
+		return nil, err
 	}
-	return nil, err
+	f.recordFreshness(Freshness{UpdatedAt: finfo.ModTime()})
+	return resources, nil
 }
@@ -15,20 +15,46 @@ type FetcherCli struct {
 	HttpEndpoint     string
 	FetcherCachePath string
 	MinimumCache     time.Duration
+	HttpClientConfig util.HttpClientConfig
+	// AutostartServer starts a local server when there's no data for the
+	// current context and the http endpoint is unreachable
+	AutostartServer      bool
+	ServerBin            string
+	AutostartIdleTimeout time.Duration
+	// History records the selected results to rank completions by frecency
+	History bool
 }
 
 func SetFetchConfigFlags(fs *flag.FlagSet) {
 	clusterconfig.SetClusterConfigCli(fs)
-	fs.String("http-endpoint", "", "Force completion to fetch data from a specific http endpoint.")
+	fs.String("http-endpoint", "", "Force completion to fetch data from a specific http endpoint, host:port, https://host:port or unix:///path/to/socket.")
 	fs.String("fetcher-cache-path", filepath.Join(util.DefaultCacheRoot(), "fetcher_cache"), "Location of cached resources fetched from a remote kubectl-fzf instance.")
+	fs.String("http-ca-file", "", "CA bundle verifying the certificate of an https endpoint, system roots are used when empty.")
+	fs.String("http-client-cert-file", "", "Client certificate presented to an https endpoint requiring mTLS.")
+	fs.String("http-client-key-file", "", "Key of the client certificate.")
+	fs.String("http-token-file", "", "File holding the bearer token sent to the http endpoint.")
 	fs.Duration("minimum-cache", 5*time.Second, "The minimum duration after which the http endpoint will be queried to check for resource modification.")
+	fs.Bool("autostart-server", false, "Start a detached kubectl-fzf-server when no data exists for the current context and the http endpoint is unreachable.")
+	fs.String("server-bin", "kubectl-fzf-server", "Server binary started by --autostart-server.")
+	fs.Duration("autostart-idle-timeout", 30*time.Minute, "Duration without completion after which a started server exits.")
+	fs.Bool("history", true, "Record the selected results and rank completions by frecency.")
 }
 
 func NewFetcherCli(store *config.Store) FetcherCli {
 	return FetcherCli{
-		ClusterConfigCli: clusterconfig.NewClusterConfigCli(store),
-		FetcherCachePath: store.GetString("fetcher-cache-path", filepath.Join(util.DefaultCacheRoot(), "fetcher_cache")),
-		HttpEndpoint:     store.GetString("http-endpoint", ""),
-		MinimumCache:     store.GetDuration("minimum-cache", 5*time.Second),
+		ClusterConfigCli:     clusterconfig.NewClusterConfigCli(store),
+		FetcherCachePath:     store.GetString("fetcher-cache-path", filepath.Join(util.DefaultCacheRoot(), "fetcher_cache")),
+		HttpEndpoint:         store.GetString("http-endpoint", ""),
+		MinimumCache:         store.GetDuration("minimum-cache", 5*time.Second),
+		AutostartServer:      store.GetBool("autostart-server", false),
+		ServerBin:            store.GetString("server-bin", "kubectl-fzf-server"),
+		AutostartIdleTimeout: store.GetDuration("autostart-idle-timeout", 30*time.Minute),
+		History:              store.GetBool("history", true),
+		HttpClientConfig: util.HttpClientConfig{
+			CAFile:    store.GetString("http-ca-file", ""),
+			CertFile:  store.GetString("http-client-cert-file", ""),
+			KeyFile:   store.GetString("http-client-key-file", ""),
+			TokenFile: store.GetString("http-token-file", ""),
+		},
 	}
 }
@@ -0,0 +1,107 @@
+package fetcher
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
+)
+
+// Freshness tells how current the resources returned by the fetcher are
+type Freshness struct {
+	// UpdatedAt is the last time the resources were known to match the
+	// cluster, zero when nothing was fetched
+	UpdatedAt time.Time
+	// ServerRunning is false when the resources were read from the files of
+	// a stopped local server or from the cache of an unreachable endpoint
+	ServerRunning bool
+}
+
+// Age returns the time elapsed since the resources were last updated
+func (fr Freshness) Age() time.Duration {
+	if fr.UpdatedAt.IsZero() {
+		return 0
+	}
+	return time.Since(fr.UpdatedAt)
+}
+
+// IsStale returns true if the resources were not updated for threshold
+// A zero threshold disables the check.
+func (fr Freshness) IsStale(threshold time.Duration) bool {
+	return threshold > 0 && fr.Age() >= threshold
+}
+
+// String describes the freshness for the fzf header, e.g. "data 3h old,
+// server not running"
+func (fr Freshness) String() string {
+	s := fmt.Sprintf("data %s old", formatAge(fr.Age()))
+	if !fr.ServerRunning {
+		s += ", server not running"
+	}
+	return s
+}
+
+// formatAge formats d with its largest unit, e.g. 3h or 2d
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	}
+	return fmt.Sprintf("%ds", int(d/time.Second))
+}
+
+// recordFreshness merges the freshness of a fetched resource type: the
+// completion is as stale as its oldest resources
+func (f *Fetcher) recordFreshness(fr Freshness) {
+	if f.freshness == nil {
+		f.freshness = &fr
+		return
+	}
+	if fr.UpdatedAt.Before(f.freshness.UpdatedAt) {
+		f.freshness.UpdatedAt = fr.UpdatedAt
+	}
+	f.freshness.ServerRunning = f.freshness.ServerRunning && fr.ServerRunning
+}
+
+// GetFreshness returns the freshness of the resources fetched so far
+func (f *Fetcher) GetFreshness() Freshness {
+	if f.freshness == nil {
+		return Freshness{}
+	}
+	return *f.freshness
+}
+
+// heartbeatStopped returns true when a local server touched its heartbeat
+// file then stopped
+// Without heartbeat file, the server is remote: the fetcher cache is touched
+// on each successful request.
+func (f *Fetcher) heartbeatStopped() bool {
+	finfo, err := os.Stat(f.GetHeartbeatPath())
+	return err == nil && time.Since(finfo.ModTime()) >= clusterconfig.HeartbeatTimeout
+}
+
+// localServerFreshness returns the freshness of files written by a local
+// server, lastModified being the last write of the resource files
+// A running server touches its heartbeat file, the resources are up to date
+// while it does. Once stopped, they were up to date until its last
+// heartbeat.
+func (f *Fetcher) localServerFreshness(lastModified time.Time) Freshness {
+	finfo, err := os.Stat(f.GetHeartbeatPath())
+	if err != nil {
+		// Files written by a server without heartbeat
+		return Freshness{UpdatedAt: lastModified}
+	}
+	heartbeat := finfo.ModTime()
+	if time.Since(heartbeat) < clusterconfig.HeartbeatTimeout {
+		return Freshness{UpdatedAt: heartbeat, ServerRunning: true}
+	}
+	if lastModified.After(heartbeat) {
+		return Freshness{UpdatedAt: lastModified}
+	}
+	return Freshness{UpdatedAt: heartbeat}
+}
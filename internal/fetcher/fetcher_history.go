@@ -0,0 +1,142 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+)
+
+// maxHistoryEntries bounds the history of a context, the entries with the
+// lowest frecency are dropped first
+const maxHistoryEntries = 1000
+
+// HistoryEntry is the use of a completion result
+type HistoryEntry struct {
+	Count    int       `json:"count"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// Frecency returns the number of uses weighted by the recency of the last
+// one: 4 within the hour, 2 within the day, 0.5 within the week and 0.25
+// after
+func (e *HistoryEntry) Frecency(now time.Time) float64 {
+	age := now.Sub(e.LastUsed)
+	weight := 0.25
+	switch {
+	case age < time.Hour:
+		weight = 4
+	case age < 24*time.Hour:
+		weight = 2
+	case age < 7*24*time.Hour:
+		weight = 0.5
+	}
+	return float64(e.Count) * weight
+}
+
+// History is the results selected in the completions of a context, stored
+// next to the fetcher state
+type History struct {
+	historyPath string
+	Entries     map[string]*HistoryEntry
+	hasChanged  bool
+}
+
+func newHistory(cachePath string, context string) *History {
+	return &History{
+		historyPath: path.Join(cachePath, "history", url.PathEscape(context)),
+		Entries:     map[string]*HistoryEntry{},
+	}
+}
+
+func (h *History) loadFromDisk() error {
+	if !util.FileExists(h.historyPath) {
+		return nil
+	}
+	b, err := os.ReadFile(h.historyPath)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &h); err != nil {
+		return err
+	}
+	if h.Entries == nil {
+		h.Entries = map[string]*HistoryEntry{}
+	}
+	return nil
+}
+
+func (h *History) writeToDisk() error {
+	if !h.hasChanged {
+		return nil
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(h.historyPath), 0o700); err != nil {
+		return err
+	}
+	return util.WriteBytesAtomic(h.historyPath, b, 0o600)
+}
+
+func (h *History) record(key string, now time.Time) {
+	log.Infof("Recording %s in history %s", key, h.historyPath)
+	entry, ok := h.Entries[key]
+	if !ok {
+		entry = &HistoryEntry{}
+		h.Entries[key] = entry
+	}
+	entry.Count++
+	entry.LastUsed = now
+	h.hasChanged = true
+	if len(h.Entries) <= maxHistoryEntries {
+		return
+	}
+	keys := make([]string, 0, len(h.Entries))
+	for k := range h.Entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return h.Entries[keys[i]].Frecency(now) < h.Entries[keys[j]].Frecency(now)
+	})
+	for _, k := range keys[:len(keys)-maxHistoryEntries] {
+		delete(h.Entries, k)
+	}
+}
+
+func (h *History) frecency(key string, now time.Time) float64 {
+	entry, ok := h.Entries[key]
+	if !ok {
+		return 0
+	}
+	return entry.Frecency(now)
+}
+
+// HistoryEnabled returns true when completions are ranked by frecency
+func (f *Fetcher) HistoryEnabled() bool {
+	return f.history != nil
+}
+
+// RecordHistory records the selection of key in the history of the context
+func (f *Fetcher) RecordHistory(key string) {
+	if f.history == nil {
+		return
+	}
+	f.history.record(key, time.Now())
+}
+
+// GetFrecency returns the frecency of key in the history of the context, 0
+// when it was never selected
+func (f *Fetcher) GetFrecency(key string, now time.Time) float64 {
+	if f.history == nil {
+		return 0
+	}
+	return f.history.frecency(key, now)
+}
@@ -0,0 +1,68 @@
+package fetcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFrecencyDecay(t *testing.T) {
+	now := time.Now()
+	testDatas := []struct {
+		age      time.Duration
+		frecency float64
+	}{
+		{time.Minute, 12},
+		{2 * time.Hour, 6},
+		{2 * 24 * time.Hour, 1.5},
+		{30 * 24 * time.Hour, 0.75},
+	}
+	for _, v := range testDatas {
+		entry := HistoryEntry{Count: 3, LastUsed: now.Add(-v.age)}
+		if frecency := entry.Frecency(now); frecency != v.frecency {
+			t.Errorf("Frecency() after %s = %v, want %v", v.age, frecency, v.frecency)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	cachePath := t.TempDir()
+	now := time.Now()
+	h := newHistory(cachePath, "minikube")
+	h.record("pods/default/old", now.Add(-48*time.Hour))
+	h.record("pods/default/old", now.Add(-48*time.Hour))
+	h.record("pods/default/recent", now)
+	// A recent selection outranks older but more frequent ones
+	if recent, old := h.frecency("pods/default/recent", now), h.frecency("pods/default/old", now); recent <= old {
+		t.Fatalf("expected recent frecency %v above old frecency %v", recent, old)
+	}
+	if frecency := h.frecency("pods/default/unknown", now); frecency != 0 {
+		t.Fatalf("expected no frecency, got %v", frecency)
+	}
+	if err := h.writeToDisk(); err != nil {
+		t.Fatalf("writeToDisk() error = %v", err)
+	}
+
+	loaded := newHistory(cachePath, "minikube")
+	if err := loaded.loadFromDisk(); err != nil {
+		t.Fatalf("loadFromDisk() error = %v", err)
+	}
+	if entry := loaded.Entries["pods/default/old"]; entry == nil || entry.Count != 2 {
+		t.Fatalf("unexpected loaded entry %+v", entry)
+	}
+}
+
+func TestHistoryPruning(t *testing.T) {
+	now := time.Now()
+	h := newHistory(t.TempDir(), "minikube")
+	h.record("pods/default/stale", now.Add(-30*24*time.Hour))
+	for i := 0; i < maxHistoryEntries; i++ {
+		h.record(fmt.Sprintf("pods/default/pod-%d", i), now)
+	}
+	if len(h.Entries) != maxHistoryEntries {
+		t.Fatalf("expected %d entries, got %d", maxHistoryEntries, len(h.Entries))
+	}
+	if _, ok := h.Entries["pods/default/stale"]; ok {
+		t.Fatalf("expected the entry with the lowest frecency to be dropped")
+	}
+}
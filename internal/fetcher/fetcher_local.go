@@ -5,21 +5,35 @@ import (
 	"time"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
 )
 
 func (f *Fetcher) checkLocalFiles(r resources.ResourceType) (map[string]resources.K8sResource, error) {
 	resourceStorePath := f.GetResourceStorePath(r)
-	finfo, err := os.Stat(resourceStorePath)
-	if err != nil {
+	resourceLogPath := f.GetResourceLogPath(r)
+	var lastModified time.Time
+	for _, p := range []string{resourceStorePath, resourceLogPath} {
+		finfo, err := os.Stat(p)
+		if err == nil && finfo.ModTime().After(lastModified) {
+			lastModified = finfo.ModTime()
+		}
+	}
+	if lastModified.IsZero() {
 		return nil, nil
 	}
 
-	deltaMod := time.Now().Sub(finfo.ModTime())
 	log.Infof("%s found, using resources from file", resourceStorePath)
-	if deltaMod >= time.Hour {
-		log.Warnf("%s was not modified for more than one hour", resourceStorePath)
+	freshness := f.localServerFreshness(lastModified)
+	if freshness.ServerRunning {
+		f.touchLastUsed()
+	} else {
+		log.Warnf("%s was written by a server that is not running, %s", resourceStorePath, freshness)
+	}
+	resources, _, err := store.LoadResourcesWithDeltaLog(resourceStorePath, resourceLogPath, r)
+	if err != nil {
+		return nil, err
 	}
-	resources, err := loadResourceFromFile(resourceStorePath)
-	return resources, err
+	f.recordFreshness(freshness)
+	return resources, nil
 }
@@ -0,0 +1,129 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+)
+
+// Pins are the resources and label queries pinned in a context, shown first
+// in completions
+// Resources are keyed by <type>/<namespace>/<name>, e.g.
+// deployments/prod/payment, label queries are label selectors, e.g.
+// app=api,tier=web.
+type Pins struct {
+	pinsPath     string
+	Resources    []string `json:"resources"`
+	LabelQueries []string `json:"labelQueries"`
+	hasChanged   bool
+}
+
+func newPins(cachePath string, context string) *Pins {
+	return &Pins{
+		pinsPath: path.Join(cachePath, "pins", url.PathEscape(context)),
+	}
+}
+
+func (p *Pins) loadFromDisk() error {
+	if !util.FileExists(p.pinsPath) {
+		return nil
+	}
+	b, err := os.ReadFile(p.pinsPath)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &p)
+}
+
+func (p *Pins) writeToDisk() error {
+	if !p.hasChanged {
+		return nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(p.pinsPath), 0o700); err != nil {
+		return err
+	}
+	return util.WriteBytesAtomic(p.pinsPath, b, 0o600)
+}
+
+func addPin(pins []string, pin string) ([]string, bool) {
+	if util.IsStringIn(pin, pins) {
+		return pins, false
+	}
+	pins = append(pins, pin)
+	sort.Strings(pins)
+	return pins, true
+}
+
+func removePin(pins []string, pin string) ([]string, bool) {
+	for i, p := range pins {
+		if p == pin {
+			return append(pins[:i], pins[i+1:]...), true
+		}
+	}
+	return pins, false
+}
+
+// AddResource pins a resource, it returns false when it was already pinned
+func (p *Pins) AddResource(key string) bool {
+	var added bool
+	p.Resources, added = addPin(p.Resources, key)
+	p.hasChanged = p.hasChanged || added
+	return added
+}
+
+// RemoveResource unpins a resource, it returns false when it wasn't pinned
+func (p *Pins) RemoveResource(key string) bool {
+	var removed bool
+	p.Resources, removed = removePin(p.Resources, key)
+	p.hasChanged = p.hasChanged || removed
+	return removed
+}
+
+// AddLabelQuery saves a label query, it returns false when it was already
+// saved
+func (p *Pins) AddLabelQuery(query string) bool {
+	var added bool
+	p.LabelQueries, added = addPin(p.LabelQueries, query)
+	p.hasChanged = p.hasChanged || added
+	return added
+}
+
+// RemoveLabelQuery removes a saved label query, it returns false when it
+// wasn't saved
+func (p *Pins) RemoveLabelQuery(query string) bool {
+	var removed bool
+	p.LabelQueries, removed = removePin(p.LabelQueries, query)
+	p.hasChanged = p.hasChanged || removed
+	return removed
+}
+
+// IsResourcePinned returns true if one of keys is pinned
+func (p *Pins) IsResourcePinned(keys ...string) bool {
+	for _, key := range keys {
+		if util.IsStringIn(key, p.Resources) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEmpty returns true when nothing is pinned
+func (p *Pins) IsEmpty() bool {
+	return len(p.Resources) == 0 && len(p.LabelQueries) == 0
+}
+
+// GetPins returns the pins of the context, loaded with the fetcher state
+func (f *Fetcher) GetPins() *Pins {
+	if f.pins == nil {
+		return &Pins{}
+	}
+	return f.pins
+}
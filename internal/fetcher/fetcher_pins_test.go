@@ -0,0 +1,71 @@
+package fetcher
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestPins(t *testing.T) {
+	cachePath := t.TempDir()
+	p := newPins(cachePath, "minikube")
+	if !p.AddResource("pods/default/web") || !p.AddResource("deployments/default/api") {
+		t.Fatalf("expected the resources to be added")
+	}
+	if p.AddResource("pods/default/web") {
+		t.Fatalf("expected pods/default/web to be already pinned")
+	}
+	p.AddLabelQuery("app=api")
+	if err := p.writeToDisk(); err != nil {
+		t.Fatalf("writeToDisk() error = %v", err)
+	}
+
+	loaded := newPins(cachePath, "minikube")
+	if err := loaded.loadFromDisk(); err != nil {
+		t.Fatalf("loadFromDisk() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Resources, []string{"deployments/default/api", "pods/default/web"}) ||
+		!reflect.DeepEqual(loaded.LabelQueries, []string{"app=api"}) {
+		t.Fatalf("unexpected loaded pins %+v", loaded)
+	}
+	if !loaded.IsResourcePinned("pods/default/other", "pods/default/web") {
+		t.Fatalf("expected pods/default/web to be pinned")
+	}
+	if !loaded.RemoveResource("pods/default/web") || loaded.RemoveResource("pods/default/web") {
+		t.Fatalf("expected pods/default/web to be removed once")
+	}
+	if !loaded.RemoveLabelQuery("app=api") || loaded.IsEmpty() {
+		t.Fatalf("expected only the label query to be removed, got %+v", loaded)
+	}
+
+	// Other contexts have their own pins
+	other := newPins(cachePath, "prod")
+	if err := other.loadFromDisk(); err != nil || !other.IsEmpty() {
+		t.Fatalf("expected no pins in another context, got %+v, %v", other, err)
+	}
+}
+
+func TestCorruptedPins(t *testing.T) {
+	cachePath := t.TempDir()
+	p := newPins(cachePath, "minikube")
+	if err := os.MkdirAll(path.Dir(p.pinsPath), 0o700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.WriteFile(p.pinsPath, []byte(`{"resources": [`), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := p.loadFromDisk(); err == nil {
+		t.Fatalf("expected an error loading corrupted pins")
+	}
+
+	// Pinning again rewrites the file
+	p = newPins(cachePath, "minikube")
+	p.AddResource("pods/default/web")
+	if err := p.writeToDisk(); err != nil {
+		t.Fatalf("writeToDisk() error = %v", err)
+	}
+	if err := newPins(cachePath, "minikube").loadFromDisk(); err != nil {
+		t.Fatalf("loadFromDisk() error = %v", err)
+	}
+}
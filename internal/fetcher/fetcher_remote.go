@@ -2,38 +2,113 @@ package fetcher
 
 import (
 	"fmt"
+	"net/http"
+	"os"
 	"path"
+	"time"
 
+	"github.com/codeactual/kubectl-fzf/v4/internal/httpserver"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
 	"github.com/codeactual/kubectl-fzf/v4/internal/util"
 	"github.com/pkg/errors"
 )
 
-func (f *Fetcher) loadResourceFromHttpServer(endpoint string, r resources.ResourceType) (map[string]resources.K8sResource, error) {
-	resources, err := f.checkHttpCache(endpoint, r)
+// loadResourceFromHttpServer gets resources in a single conditional request
+// The cached ETag is sent as If-None-Match: the server answers 304 when the
+// cache is up to date, 226 with the changes since the cached revision or
+// 200 with the full resource file.
+func (f *Fetcher) loadResourceFromHttpServer(endpoint util.HttpEndpoint, client *http.Client, r resources.ResourceType) (map[string]resources.K8sResource, error) {
+	log.Debugf("Loading from %s", endpoint)
+	resourcePath := endpoint.URL(path.Join("k8s", "resources", r.String()))
+	req, err := http.NewRequest(http.MethodGet, resourcePath, nil)
 	if err != nil {
-		log.Infof("Error getting resources from cache: %s", err)
+		return nil, errors.Wrapf(err, "error creating request for %s", resourcePath)
 	}
-	if resources != nil {
-		log.Infof("Returning %s resources from cache", r.String())
-		return resources, nil
+	cached := f.loadCachedResources(r)
+	if cached != nil {
+		req.Header.Set("If-None-Match", f.fetcherState.getETag(f.GetContext(), r))
+		req.Header.Set("A-IM", httpserver.DeltaInstanceManipulation)
 	}
-	log.Debugf("Loading from %s", endpoint)
-	resourcePath := f.getResourceHttpPath(endpoint, r)
-	headers, body, err := util.GetFromHttpServer(resourcePath)
+	resp, body, err := util.DoHttpRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		log.Infof("Cache of %s is up to date, using it", r)
+		f.touchCacheFile(r)
+		return cached, nil
+	case http.StatusIMUsed:
+		return f.applyDeltasToCache(resp.Header.Get("ETag"), body, cached, r)
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("error retrieving resource from server: %s", resp.Status)
+	}
+
+	resources, _, err := store.DecodeResources(body, r)
 	if err != nil {
-		return nil, errors.Wrap(err, "error reading body content")
+		return nil, err
 	}
-	err = f.writeResourceToCache(headers, body, r)
+	err = f.writeResourceToCache(resp.Header.Get("ETag"), body, r)
 	if err != nil {
 		return nil, errors.Wrap(err, "error writing fetcher cache")
 	}
-	util.DecodeGob(&resources, body)
-	return resources, err
+	return resources, nil
 }
 
-func (f *Fetcher) getResourceHttpPath(host string, r resources.ResourceType) string {
-	fullPath := path.Join("k8s", "resources", r.String())
-	return fmt.Sprintf("http://%s/%s", host, fullPath)
+// loadCachedResources returns the cached resources if they can be used for a
+// conditional request
+func (f *Fetcher) loadCachedResources(r resources.ResourceType) map[string]resources.K8sResource {
+	if f.fetcherState.getETag(f.GetContext(), r) == "" {
+		log.Infof("No ETag for %s, pulling it from server", r)
+		return nil
+	}
+	cacheFile := f.getCacheFilePath(r)
+	if !util.FileExists(cacheFile) {
+		log.Infof("No cache file %s present", cacheFile)
+		return nil
+	}
+	cached, err := loadResourceFromFile(cacheFile, r)
+	if err != nil {
+		log.Infof("Ignoring cache file %s: %s", cacheFile, err)
+		return nil
+	}
+	return cached
+}
+
+// applyDeltasToCache applies the changes of a 226 response to the cached
+// resources and rewrites the cache
+func (f *Fetcher) applyDeltasToCache(etag string, body []byte, cached map[string]resources.K8sResource,
+	r resources.ResourceType) (map[string]resources.K8sResource, error) {
+	if cached == nil {
+		return nil, fmt.Errorf("received deltas of %s without cache", r)
+	}
+	deltas, header, err := store.DecodeDeltas(body, r)
+	if err != nil {
+		return nil, err
+	}
+	store.ApplyDeltas(cached, deltas, 0)
+	log.Infof("Applied %d changes of %s, now at revision %d", len(deltas), r, header.Revision)
+
+	header.ItemCount = len(cached)
+	header.WrittenAt = time.Now()
+	if _, err := f.createCacheDir(); err != nil {
+		return nil, err
+	}
+	if err := util.EncodeToFile(cached, header, f.getCacheFilePath(r)); err != nil {
+		return nil, errors.Wrap(err, "error writing fetcher cache")
+	}
+	f.fetcherState.updateETag(f.GetContext(), r, etag)
+	return cached, nil
+}
+
+func (f *Fetcher) touchCacheFile(r resources.ResourceType) {
+	cacheFile := f.getCacheFilePath(r)
+	now := time.Now()
+	if err := os.Chtimes(cacheFile, now, now); err != nil {
+		log.Warnf("Error touching cache file %s: %s", cacheFile, err)
+	}
 }
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"os"
 	"path"
-	"time"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
 	"github.com/codeactual/kubectl-fzf/v4/internal/util"
@@ -19,7 +18,7 @@ type FetcherState struct {
 }
 
 type fetcherContextState struct {
-	LastModifiedTimes map[resources.ResourceType]time.Time // Keep track of last modified times of a resource pulled
+	ETags map[resources.ResourceType]string // Keep track of the ETag of resources pulled
 }
 
 func newFetcherState(cachePath string) *FetcherState {
@@ -33,10 +32,14 @@ func (f *FetcherState) getContextState(context string) *fetcherContextState {
 	contextState, ok := f.ContextStates[context]
 	if !ok {
 		contextState = &fetcherContextState{
-			LastModifiedTimes: map[resources.ResourceType]time.Time{},
+			ETags: map[resources.ResourceType]string{},
 		}
 		f.ContextStates[context] = contextState
 	}
+	// States written by older versions have no ETags
+	if contextState.ETags == nil {
+		contextState.ETags = map[resources.ResourceType]string{}
+	}
 	return contextState
 }
 
@@ -64,21 +67,17 @@ func (f *FetcherState) writeToDisk() error {
 	if err := os.MkdirAll(path.Dir(f.statePath), 0o700); err != nil {
 		return err
 	}
-	return os.WriteFile(f.statePath, b, 0o600)
+	return util.WriteBytesAtomic(f.statePath, b, 0o600)
 }
 
-func (f *FetcherState) getLastModifiedTime(context string, r resources.ResourceType) *time.Time {
+func (f *FetcherState) getETag(context string, r resources.ResourceType) string {
 	contextState := f.getContextState(context)
-	lastModified, ok := contextState.LastModifiedTimes[r]
-	if !ok {
-		return nil
-	}
-	return &lastModified
+	return contextState.ETags[r]
 }
 
-func (f *FetcherState) updateLastModifiedTimes(context string, r resources.ResourceType, newTime time.Time) {
-	log.Infof("Updating last modified times for resource %s, state file %s", r, f.statePath)
+func (f *FetcherState) updateETag(context string, r resources.ResourceType, etag string) {
+	log.Infof("Updating ETag of resource %s to %s, state file %s", r, etag, f.statePath)
 	contextState := f.getContextState(context)
-	contextState.LastModifiedTimes[r] = newTime
+	contextState.ETags[r] = etag
 	f.hasChanged = true
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
 	"github.com/codeactual/kubectl-fzf/v4/internal/util"
@@ -12,9 +13,14 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (f *Fetcher) getStatsFromHttpServer(ctx context.Context, url string) ([]*store.Stats, error) {
+func (f *Fetcher) getStatsFromHttpServer(ctx context.Context, endpoint util.HttpEndpoint) ([]*store.Stats, error) {
+	url := endpoint.URL("stats")
 	log.Debugf("Fetching stats from %s", url)
-	_, body, err := util.GetFromHttpServer(url)
+	client, err := endpoint.Client(f.httpClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	_, body, err := util.GetFromHttpServer(client, url)
 	if err != nil {
 		return nil, errors.Wrap(err, "error on http get")
 	}
@@ -25,12 +31,38 @@ func (f *Fetcher) getStatsFromHttpServer(ctx context.Context, url string) ([]*st
 
 func (f *Fetcher) GetStats(ctx context.Context) ([]*store.Stats, error) {
 	// TODO Handle local file
-	if util.IsAddressReachable(f.httpEndpoint) {
-		url := fmt.Sprintf("http://%s/%s", f.httpEndpoint, "stats")
-		return f.getStatsFromHttpServer(ctx, url)
+	endpoint, err := f.getHttpEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	return f.getStatsFromHttpServer(ctx, endpoint)
+}
+
+// GetHealth returns the sync state of the stores of the server
+func (f *Fetcher) GetHealth(ctx context.Context) (*store.HealthReport, error) {
+	endpoint, err := f.getHttpEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	url := endpoint.URL("health")
+	log.Debugf("Fetching health from %s", url)
+	client, err := endpoint.Client(f.httpClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating request for %s", url)
+	}
+	resp, body, err := util.DoHttpRequest(client, req)
+	if err != nil {
+		return nil, err
 	}
-	if f.httpEndpoint == "" {
-		return nil, fmt.Errorf("http endpoint not configured; run kubectl-fzf-server locally or provide --http-endpoint")
+	// The report is also sent with 503 when the server is not ready
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, fmt.Errorf("error retrieving health from server: %s", resp.Status)
 	}
-	return nil, fmt.Errorf("http endpoint %s is not reachable", f.httpEndpoint)
+	report := &store.HealthReport{}
+	err = json.Unmarshal(body, report)
+	return report, err
 }
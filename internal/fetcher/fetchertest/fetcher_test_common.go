@@ -2,13 +2,40 @@ package fetchertest
 
 import (
 	"fmt"
+	"os"
+	"path"
 	"testing"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
 )
 
-func GetTestFetcher(t *testing.T, clusterName string, port int) (*fetcher.Fetcher, string) {
+// TestFetcherOption changes the fetcher returned by GetTestFetcher
+type TestFetcherOption func(*testFetcherConfig)
+
+type testFetcherConfig struct {
+	fetchCli  *fetcher.FetcherCli
+	loadState bool
+}
+
+// WithHistory enables the history of the fetcher
+func WithHistory() TestFetcherOption {
+	return func(c *testFetcherConfig) {
+		c.fetchCli.History = true
+	}
+}
+
+// WithCachePath sets the cache path of the fetcher and loads its state, from
+// the context of a test kubeconfig: fetchers sharing cachePath load the
+// state, history and pins saved by the previous ones
+func WithCachePath(cachePath string) TestFetcherOption {
+	return func(c *testFetcherConfig) {
+		c.fetchCli.FetcherCachePath = cachePath
+		c.loadState = true
+	}
+}
+
+func GetTestFetcher(t *testing.T, clusterName string, port int, options ...TestFetcherOption) (*fetcher.Fetcher, string) {
 	tempDir := t.TempDir()
 	fetchCli := &fetcher.FetcherCli{
 		FetcherCachePath: tempDir,
@@ -18,11 +45,27 @@ func GetTestFetcher(t *testing.T, clusterName string, port int) (*fetcher.Fetche
 		},
 		HttpEndpoint: fmt.Sprintf("localhost:%d", port),
 	}
+	c := &testFetcherConfig{fetchCli: fetchCli}
+	for _, option := range options {
+		option(c)
+	}
 	f := fetcher.NewFetcher(fetchCli)
-	return f, tempDir
+	if c.loadState {
+		kubeconfig := path.Join(t.TempDir(), "config")
+		kubeconfigContent := fmt.Sprintf("apiVersion: v1\nkind: Config\ncurrent-context: %s\ncontexts:\n- name: %s\n  context: {cluster: %s}\n",
+			clusterName, clusterName, clusterName)
+		if err := os.WriteFile(kubeconfig, []byte(kubeconfigContent), 0600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		t.Setenv("KUBECONFIG", kubeconfig)
+		if err := f.LoadFetcherState(); err != nil {
+			t.Fatalf("LoadFetcherState() error = %v", err)
+		}
+	}
+	return f, fetchCli.FetcherCachePath
 }
 
-func GetTestFetcherWithDefaults(t *testing.T) *fetcher.Fetcher {
-	f, _ := GetTestFetcher(t, "minikube", 8080)
+func GetTestFetcherWithDefaults(t *testing.T, options ...TestFetcherOption) *fetcher.Fetcher {
+	f, _ := GetTestFetcher(t, "minikube", 8080, options...)
 	return f
 }
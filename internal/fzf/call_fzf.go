@@ -8,6 +8,9 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/codeactual/kubectl-fzf/v4/internal/preview"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
 )
 
@@ -45,34 +48,141 @@ func nameColumnIndex(header string) (int, bool) {
 	return 0, false
 }
 
-func CallFzf(comps string, query string) (string, error) {
-	var result strings.Builder
-	header := strings.Split(comps, "\n")[1]
-	// Leave an additional line for overflow
-	numFields := len(strings.Fields(header)) + 1
-	log.Debugf("header: %s, numFields: %d", header, numFields)
-	previewWindow := fmt.Sprintf("--preview-window=down:%d", numFields)
-	previewCmd := fmt.Sprintf("echo -e \"%s\n{}\" | sed -e \"s/'//g\" | awk '(NR==1){for (i=1; i<=NF; i++) a[i]=$i} (NR==2){for (i in a) {printf a[i] \": \" $i \"\\n\"} }' | column -t | fold -w $COLUMNS", header)
-
-	// TODO Make fzf options configurable
-	fzfArgs := []string{
-		"-1",
+// searchFields returns the fzf fields matched by the query: the name column
+// then the columns of searchColumns found in header
+func searchFields(header string, searchColumns []string) (string, bool) {
+	nameIdx, ok := nameColumnIndex(header)
+	if !ok {
+		return "", false
+	}
+	nth := []string{strconv.Itoa(nameIdx)}
+	for i, field := range strings.Fields(header) {
+		if util.IsStringIn(field, searchColumns) {
+			nth = append(nth, strconv.Itoa(i+1))
+		}
+	}
+	return strings.Join(nth, ","), true
+}
+
+// previewBindings are the keys switching the preview mode of resources
+var previewBindings = []struct {
+	key  string
+	mode preview.Mode
+}{
+	{"alt-c", preview.ModeColumns},
+	{"alt-y", preview.ModeYaml},
+	{"alt-l", preview.ModeLogs},
+	{"alt-e", preview.ModeEvents},
+}
+
+// executable returns the path of the running completion binary, called back
+// by fzf
+func executable() string {
+	exe, err := os.Executable()
+	if err != nil {
+		log.Warnf("Couldn't get the path of the completion binary: %s", err)
+		return "kubectl-fzf-completion"
+	}
+	return exe
+}
+
+// previewCommand returns the fzf preview command of the highlighted line,
+// rendered by the preview subcommand of the running binary
+func previewCommand(header string, resourceType string, mode preview.Mode, sessionDir string) string {
+	return fmt.Sprintf("%s preview --header %s --resource-type %s --mode %s --session-dir %s -- {}",
+		util.ShellQuote(executable()), util.ShellQuote(header), util.ShellQuote(resourceType),
+		util.ShellQuote(string(mode)), util.ShellQuote(sessionDir))
+}
+
+// previewArgs returns the fzf arguments of the preview
+// Resources get key bindings switching to the live preview modes, which
+// share a cache in sessionDir.
+func previewArgs(header string, resourceType string, sessionDir string) []string {
+	args := []string{"--preview", previewCommand(header, resourceType, preview.ModeColumns, sessionDir)}
+	if resourceType == "" {
+		return args
+	}
+	labels := make([]string, 0, len(previewBindings))
+	for _, binding := range previewBindings {
+		cmd := previewCommand(header, resourceType, binding.mode, sessionDir)
+		args = append(args, "--bind", fmt.Sprintf("%s:change-preview:%s", binding.key, cmd))
+		labels = append(labels, fmt.Sprintf("%s %s", binding.key, binding.mode))
+	}
+	return append(args, "--preview-label", fmt.Sprintf(" %s ", strings.Join(labels, " | ")))
+}
+
+// listCommand returns the command listing the rows of the session after
+// action
+func listCommand(sessionDir string, action string) string {
+	return fmt.Sprintf("%s list --session-dir %s --action %s",
+		util.ShellQuote(executable()), util.ShellQuote(sessionDir), util.ShellQuote(action))
+}
+
+// ReloadArgs returns the fzf arguments of the key bindings reloading the
+// rows in another namespace or, when switchType is set, another resource type
+func ReloadArgs(sessionDir string, switchType bool) []string {
+	args := []string{"--bind", fmt.Sprintf("ctrl-n:reload:%s", listCommand(sessionDir, "cycle-namespace"))}
+	hint := "ctrl-n: next namespace"
+	if switchType {
+		args = append(args, "--bind", fmt.Sprintf("ctrl-t:reload:%s", listCommand(sessionDir, "cycle-type")))
+		hint += ", ctrl-t: next type"
+	}
+	return append(args, "--header", hint)
+}
+
+// fzfArgs returns the arguments of fzf, before the bindings of the session
+// The query matches the name column and the columns of searchColumns.
+func (c *FzfCli) fzfArgs(header string, query string, searchColumns []string) []string {
+	previewSize := c.PreviewSize
+	if previewSize == "" {
+		// Leave an additional line for overflow
+		numFields := len(strings.Fields(header)) + 1
+		previewSize = strconv.Itoa(numFields)
+	}
+	args := []string{
 		"--header-lines=2",
 		"--layout",
-		"reverse",
+		c.Layout,
 		"--no-hscroll",
 		"--no-sort",
 		"--cycle",
 		"-q",
 		query,
-		previewWindow,
-		"--preview",
-		previewCmd,
+		fmt.Sprintf("--preview-window=%s:%s", c.PreviewPosition, previewSize),
 	}
-
-	if idx, ok := nameColumnIndex(header); ok {
-		fzfArgs = append(fzfArgs, "--delimiter", "\\s+", "--nth", strconv.Itoa(idx))
+	if c.AutoSelect {
+		args = append([]string{"-1"}, args...)
 	}
+	if c.Height != "" {
+		args = append(args, "--height", c.Height)
+	}
+	if c.Ansi {
+		args = append(args, "--ansi")
+	}
+	if nth, ok := searchFields(header, searchColumns); ok {
+		args = append(args, "--delimiter", "\\s+", "--nth", nth)
+	}
+	return args
+}
+
+// CallFzf runs fzf on comps and returns the selected line
+// resourceType is the type of the completed resources, used by the preview to
+// show their full values and their live modes. It's empty when completing
+// something else, e.g. labels.
+// searchColumns are the columns matched by the query besides the name, see
+// resources.Columns.Searchable.
+// sessionDir holds the state of the commands run by fzf, e.g. the preview
+// cache, extraArgs are added to the fzf arguments before the ones of fzfCli.
+func CallFzf(comps string, query string, resourceType string, searchColumns []string, sessionDir string,
+	fzfCli *FzfCli, extraArgs []string) (string, error) {
+	var result strings.Builder
+	header := strings.Split(comps, "\n")[1]
+	log.Debugf("header: %s", header)
+
+	fzfArgs := fzfCli.fzfArgs(header, query, searchColumns)
+	fzfArgs = append(fzfArgs, previewArgs(header, resourceType, sessionDir)...)
+	fzfArgs = append(fzfArgs, extraArgs...)
+	fzfArgs = append(fzfArgs, fzfCli.ExtraArgs...)
 	log.Infof("fzf args: %+v", fzfArgs)
 	cmd := exec.Command("fzf", fzfArgs...)
 	cmd.Stdout = &result
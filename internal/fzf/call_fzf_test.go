@@ -1,6 +1,14 @@
 package fzf
 
-import "testing"
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	configstore "github.com/codeactual/kubectl-fzf/v4/internal/util/config"
+)
 
 func TestNameColumnIndex(t *testing.T) {
 	tests := map[string]struct {
@@ -37,3 +45,55 @@ func TestNameColumnIndex(t *testing.T) {
 		})
 	}
 }
+
+func TestPreviewArgs(t *testing.T) {
+	args := previewArgs("Namespace Name", "", "/tmp/session")
+	if len(args) != 2 || args[0] != "--preview" {
+		t.Fatalf("expected the columns preview only, got %v", args)
+	}
+	args = previewArgs("Namespace Name", "pods", "/tmp/session")
+	// --preview, 4 bindings and the label
+	if len(args) != 2+2*4+2 {
+		t.Fatalf("unexpected args %v", args)
+	}
+	if !strings.HasPrefix(args[5], "alt-y:change-preview:") || !strings.Contains(args[5], "--mode 'yaml' --session-dir '/tmp/session'") {
+		t.Fatalf("unexpected yaml binding %s", args[5])
+	}
+}
+
+func TestReloadArgs(t *testing.T) {
+	args := ReloadArgs("/tmp/session", false)
+	if len(args) != 4 || !strings.HasPrefix(args[1], "ctrl-n:reload:") ||
+		!strings.HasSuffix(args[1], "list --session-dir '/tmp/session' --action 'cycle-namespace'") {
+		t.Fatalf("unexpected args %v", args)
+	}
+	args = ReloadArgs("/tmp/session", true)
+	if len(args) != 6 || !strings.HasPrefix(args[3], "ctrl-t:reload:") {
+		t.Fatalf("unexpected args %v", args)
+	}
+}
+
+func TestFzfCli(t *testing.T) {
+	configDir := t.TempDir()
+	config := `{"fzf-height": "40%", "color": true, "fzf-extra-args": ["--border"], "verbs": {"get": {"fzf-preview-position": "right", "fzf-preview-size": "50%"}}}`
+	if err := os.WriteFile(path.Join(configDir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	store := configstore.NewStore()
+	if err := store.LoadConfigFile([]string{configDir}, "config.json"); err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+
+	getCli := NewFzfCli(store, "get")
+	args := strings.Join(getCli.fzfArgs("Namespace Name Age team", "web", []string{"team"}), " ")
+	expected := "-1 --header-lines=2 --layout reverse --no-hscroll --no-sort --cycle -q web --preview-window=right:50% --height 40% --ansi --delimiter \\s+ --nth 2,4"
+	if args != expected || !reflect.DeepEqual(getCli.ExtraArgs, []string{"--border"}) {
+		t.Fatalf("unexpected get args %q, extra %v", args, getCli.ExtraArgs)
+	}
+
+	deleteCli := NewFzfCli(store, "delete")
+	args = strings.Join(deleteCli.fzfArgs("Namespace Name Age", "", nil), " ")
+	if strings.HasPrefix(args, "-1") || !strings.Contains(args, "--preview-window=down:4 ") {
+		t.Fatalf("unexpected delete args %q", args)
+	}
+}
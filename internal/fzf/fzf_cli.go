@@ -0,0 +1,55 @@
+package fzf
+
+import (
+	"fmt"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/util/config"
+)
+
+// FzfCli configures the fzf command of a completion
+// Settings are read from the configuration file and environment, settings
+// under verbs.<verb> in the configuration file override them for a verb:
+//
+//	{"fzf-height": "40%", "verbs": {"delete": {"fzf-auto-select": false}}}
+type FzfCli struct {
+	// AutoSelect selects the only match without showing fzf
+	AutoSelect bool
+	// Layout is the fzf layout: default, reverse or reverse-list
+	Layout string
+	// Height shows fzf below the cursor with the given height, e.g. 40%,
+	// instead of fullscreen
+	Height string
+	// PreviewPosition is the position of the preview window: up, down, left
+	// or right
+	PreviewPosition string
+	// PreviewSize is the size of the preview window, e.g. 50%. By default,
+	// it fits the columns of the completion.
+	PreviewSize string
+	// Ansi shows the colours of the completions, enabled with color
+	Ansi bool
+	// ExtraArgs are added after the other arguments, overriding them
+	ExtraArgs []string
+}
+
+// NewFzfCli returns the fzf settings of verb
+// Auto-selection is disabled for delete unless enabled under verbs.delete: a
+// single match would be deleted without confirmation.
+func NewFzfCli(store *config.Store, verb string) FzfCli {
+	verbKey := func(key string) string {
+		return fmt.Sprintf("verbs.%s.%s", verb, key)
+	}
+	getString := func(key string, defaultValue string) string {
+		return store.GetString(verbKey(key), store.GetString(key, defaultValue))
+	}
+	autoSelect := store.GetBool("fzf-auto-select", true) && verb != "delete"
+	return FzfCli{
+		AutoSelect:      store.GetBool(verbKey("fzf-auto-select"), autoSelect),
+		Layout:          getString("fzf-layout", "reverse"),
+		Height:          getString("fzf-height", ""),
+		PreviewPosition: getString("fzf-preview-position", "down"),
+		PreviewSize:     getString("fzf-preview-size", ""),
+		Ansi:            store.GetBool("color", false),
+		ExtraArgs: store.GetStringSlice(verbKey("fzf-extra-args"),
+			store.GetStringSlice("fzf-extra-args", []string{})),
+	}
+}
@@ -0,0 +1,177 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ApiListResponse is the JSON answer of /api/v1/<type>
+// Items are sorted by namespace and name. Total is the number of matching
+// items before limit is applied. Columns are the completion header, matching
+// the Rows of each item.
+type ApiListResponse struct {
+	ResourceType string        `json:"resourceType"`
+	Epoch        int64         `json:"epoch"`
+	Revision     uint64        `json:"revision"`
+	Total        int           `json:"total"`
+	Columns      []string      `json:"columns"`
+	Items        []ApiListItem `json:"items"`
+}
+
+// ApiListItem is a resource of an ApiListResponse
+// Rows are the completion lines of the resource split in columns, usually a
+// single one. Resource holds the resource struct, decoded as a generic map by
+// clients.
+type ApiListItem struct {
+	Namespace string      `json:"namespace,omitempty"`
+	Name      string      `json:"name"`
+	Rows      [][]string  `json:"rows,omitempty"`
+	Resource  interface{} `json:"resource,omitempty"`
+}
+
+func resourceRows(r resources.K8sResource) [][]string {
+	lines := r.ToStrings()
+	rows := make([][]string, 0, len(lines))
+	for _, line := range lines {
+		rows = append(rows, strings.Split(strings.TrimRight(line, "\n"), "\t"))
+	}
+	return rows
+}
+
+// apiQuery is the filter of an /api/v1/<type> request
+type apiQuery struct {
+	namespace     string
+	labelSelector labels.Selector
+	fieldSelector fields.Selector
+	q             string
+	limit         int
+}
+
+func parseApiQuery(r *http.Request) (apiQuery, error) {
+	values := r.URL.Query()
+	query := apiQuery{
+		namespace:     values.Get("namespace"),
+		labelSelector: labels.Everything(),
+		fieldSelector: fields.Everything(),
+		q:             strings.ToLower(values.Get("q")),
+	}
+	var err error
+	if s := values.Get("labelSelector"); s != "" {
+		query.labelSelector, err = labels.Parse(s)
+		if err != nil {
+			return query, fmt.Errorf("invalid labelSelector: %w", err)
+		}
+	}
+	if s := values.Get("fieldSelector"); s != "" {
+		query.fieldSelector, err = fields.ParseSelector(s)
+		if err != nil {
+			return query, fmt.Errorf("invalid fieldSelector: %w", err)
+		}
+	}
+	if s := values.Get("limit"); s != "" {
+		query.limit, err = strconv.Atoi(s)
+		if err != nil || query.limit < 0 {
+			return query, fmt.Errorf("invalid limit %q", s)
+		}
+	}
+	return query, nil
+}
+
+// matches evaluates the query on the resource stored under key
+// Like the apiserver, metadata.name and metadata.namespace are always
+// available to field selectors.
+func (q apiQuery) matches(key string, r resources.K8sResource) bool {
+	namespace := r.GetNamespace()
+	if q.namespace != "" && q.namespace != namespace {
+		return false
+	}
+	if !q.labelSelector.Matches(labels.Set(r.GetLabels())) {
+		return false
+	}
+	if !q.fieldSelector.Empty() {
+		fieldSet := fields.Set{
+			"metadata.name":      store.KeyName(key),
+			"metadata.namespace": namespace,
+		}
+		for k, v := range r.GetFieldSelectors() {
+			fieldSet[k] = v
+		}
+		if !q.fieldSelector.Matches(fieldSet) {
+			return false
+		}
+	}
+	if q.q != "" && !strings.Contains(strings.ToLower(strings.Join(r.ToStrings(), " ")), q.q) {
+		return false
+	}
+	return true
+}
+
+// apiListRoute serves the resources of resourceType matching the query
+// parameters as JSON, for clients not sharing the gob definitions
+func (f *FzfHttpServer) apiListRoute(w http.ResponseWriter, r *http.Request, resourceType resources.ResourceType) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	query, err := parseApiQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter, ok := f.checkListAccess(w, r, resourceType)
+	if !ok {
+		return
+	}
+	s := f.getStore(resourceType)
+	if s == nil {
+		http.Error(w, fmt.Sprintf("no store for %s", resourceType), http.StatusNotFound)
+		return
+	}
+	data, revision := s.GetState()
+	res := ApiListResponse{
+		ResourceType: resourceType.String(),
+		Epoch:        s.GetEpoch(),
+		Revision:     revision,
+		Columns:      strings.Split(resources.ResourceToHeader(resourceType), "\t"),
+		Items:        []ApiListItem{},
+	}
+	for key, resource := range filter.filterResources(data) {
+		if !query.matches(key, resource) {
+			continue
+		}
+		res.Items = append(res.Items, ApiListItem{
+			Namespace: resource.GetNamespace(),
+			Name:      store.KeyName(key),
+			Rows:      resourceRows(resource),
+			Resource:  resource,
+		})
+	}
+	sort.Slice(res.Items, func(i, j int) bool {
+		if res.Items[i].Namespace != res.Items[j].Namespace {
+			return res.Items[i].Namespace < res.Items[j].Namespace
+		}
+		return res.Items[i].Name < res.Items[j].Name
+	})
+	res.Total = len(res.Items)
+	if query.limit > 0 && len(res.Items) > query.limit {
+		res.Items = res.Items[:query.limit]
+	}
+	if f.getReviewer() != nil {
+		w.Header().Set("Vary", "Authorization")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Errorf("unable to encode %s response: %v", resourceType, err)
+	}
+}
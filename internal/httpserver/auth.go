@@ -0,0 +1,59 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+	"github.com/pkg/errors"
+)
+
+// getTLSConfig returns the tls configuration of the server, nil when the
+// server serves plain http
+func getTLSConfig(h *HttpServerConfigCli) (*tls.Config, error) {
+	if h.TLSCertFile == "" && h.TLSKeyFile == "" {
+		if h.TLSClientCAFile != "" {
+			return nil, fmt.Errorf("--tls-client-ca-file requires --tls-cert-file and --tls-key-file")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(h.TLSCertFile, h.TLSKeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading server certificate")
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if h.TLSClientCAFile != "" {
+		pool, err := util.LoadCertPool(h.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// authMiddleware rejects requests without the bearer token
+// Paths of skipPaths, like probes, don't need it
+func authMiddleware(token string, skipPaths map[string]struct{}, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := skipPaths[r.URL.Path]; ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		authorization := strings.TrimSpace(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare([]byte(authorization), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kubectl-fzf"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,236 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/accessreview"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	authzv1 "k8s.io/api/authorization/v1"
+)
+
+// maxParallelReviews bounds the SubjectAccessReviews sent for a request
+const maxParallelReviews = 8
+
+type userContextKey struct{}
+
+func userFromContext(ctx context.Context) *accessreview.User {
+	user, _ := ctx.Value(userContextKey{}).(*accessreview.User)
+	return user
+}
+
+// kubernetesAuthMiddleware authenticates the bearer token of requests with a
+// TokenReview and stores the user in the request context
+// Paths of skipPaths, like probes, don't need it
+func (f *FzfHttpServer) kubernetesAuthMiddleware(skipPaths map[string]struct{}, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := skipPaths[r.URL.Path]; ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, ok := strings.CutPrefix(strings.TrimSpace(r.Header.Get("Authorization")), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kubectl-fzf"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		user, err := f.getReviewer().Authenticate(r.Context(), token)
+		if errors.Is(err, accessreview.ErrUnauthenticated) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kubectl-fzf"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Errorf("Error authenticating request: %s", err)
+			http.Error(w, "error authenticating request", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, user)))
+	})
+}
+
+// namespaceFilter is the set of namespaces a user can list, nil allows all
+type namespaceFilter map[string]bool
+
+func (n namespaceFilter) allows(namespace string) bool {
+	return n == nil || n[namespace]
+}
+
+// etagVariant identifies the namespaces of the filter in ETags, empty
+// without filter
+func (n namespaceFilter) etagVariant() string {
+	if n == nil {
+		return ""
+	}
+	namespaces := make([]string, 0, len(n))
+	for namespace := range n {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(namespaces, ",")))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func (n namespaceFilter) filterResources(data map[string]resources.K8sResource) map[string]resources.K8sResource {
+	if n == nil {
+		return data
+	}
+	res := make(map[string]resources.K8sResource, len(data))
+	for key, r := range data {
+		if n.allows(r.GetNamespace()) {
+			res[key] = r
+		}
+	}
+	return res
+}
+
+func (n namespaceFilter) filterDeltas(deltas []store.DeltaRecord) []store.DeltaRecord {
+	if n == nil {
+		return deltas
+	}
+	res := make([]store.DeltaRecord, 0, len(deltas))
+	for _, delta := range deltas {
+		if n.allows(store.KeyNamespace(delta.Key)) {
+			res = append(res, delta)
+		}
+	}
+	return res
+}
+
+func listAttributes(resourceType resources.ResourceType, namespace string) authzv1.ResourceAttributes {
+	return authzv1.ResourceAttributes{
+		Verb:      "list",
+		Group:     resourceType.APIGroup(),
+		Resource:  resourceType.String(),
+		Namespace: namespace,
+	}
+}
+
+// authorizeList returns the namespaces of resourceType the user of r can list
+// The filter is nil when authorization is disabled or the user can list
+// resourceType in all namespaces. ok is false when the user can't list it at
+// all: cluster scoped resources and resources without store can only be
+// listed with a cluster wide permission.
+func (f *FzfHttpServer) authorizeList(r *http.Request, resourceType resources.ResourceType) (filter namespaceFilter, ok bool, err error) {
+	reviewer := f.getReviewer()
+	if reviewer == nil {
+		return nil, true, nil
+	}
+	// API resources come from discovery, available to any authenticated user
+	if resourceType == resources.ResourceTypeApiResource {
+		return nil, true, nil
+	}
+	user := userFromContext(r.Context())
+	if user == nil {
+		return nil, false, nil
+	}
+	allowed, err := reviewer.Allowed(r.Context(), user, listAttributes(resourceType, ""))
+	if err != nil || allowed {
+		return nil, allowed, err
+	}
+	s := f.getStore(resourceType)
+	if !resourceType.IsNamespaced() || s == nil {
+		return nil, false, nil
+	}
+
+	namespaces := s.GetNamespaces()
+	allowedNamespaces := make([]bool, len(namespaces))
+	errs := make([]error, len(namespaces))
+	sem := make(chan struct{}, maxParallelReviews)
+	var wg sync.WaitGroup
+	for i, namespace := range namespaces {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, namespace string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			allowedNamespaces[i], errs[i] = reviewer.Allowed(r.Context(), user, listAttributes(resourceType, namespace))
+		}(i, namespace)
+	}
+	wg.Wait()
+
+	filter = namespaceFilter{}
+	for i, namespace := range namespaces {
+		if errs[i] != nil {
+			return nil, false, errs[i]
+		}
+		if allowedNamespaces[i] {
+			filter[namespace] = true
+		}
+	}
+	log.Debugf("%s can list %s in %d of %d namespaces", user.Username, resourceType, len(filter), len(namespaces))
+	return filter, true, nil
+}
+
+// checkListAccess writes the error response and returns false when the user
+// of r can't list resourceType
+func (f *FzfHttpServer) checkListAccess(w http.ResponseWriter, r *http.Request,
+	resourceType resources.ResourceType) (namespaceFilter, bool) {
+	filter, ok, err := f.authorizeList(r, resourceType)
+	if err != nil {
+		log.Errorf("Error reviewing access to %s: %s", resourceType, err)
+		http.Error(w, "error reviewing access", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return nil, false
+	}
+	return filter, true
+}
+
+// filterHealths drops the health of the resources the user of r can't list
+func (f *FzfHttpServer) filterHealths(r *http.Request, healths []store.Health) ([]store.Health, error) {
+	if f.getReviewer() == nil {
+		return healths, nil
+	}
+	res := make([]store.Health, 0, len(healths))
+	for _, h := range healths {
+		_, ok, err := f.authorizeList(r, h.ResourceType)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, h)
+		}
+	}
+	return res, nil
+}
+
+// filterStats drops the stats the user of r isn't allowed to see
+func (f *FzfHttpServer) filterStats(r *http.Request, stats []*store.Stats) ([]*store.Stats, error) {
+	if f.getReviewer() == nil {
+		return stats, nil
+	}
+	res := make([]*store.Stats, 0, len(stats))
+	for _, s := range stats {
+		filter, ok, err := f.authorizeList(r, s.ResourceType)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if filter != nil {
+			itemPerNamespace := map[string]int{}
+			for namespace, count := range s.ItemPerNamespace {
+				if filter.allows(namespace) {
+					itemPerNamespace[namespace] = count
+				}
+			}
+			s = &store.Stats{ResourceType: s.ResourceType, ItemPerNamespace: itemPerNamespace, LastDumped: s.LastDumped}
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
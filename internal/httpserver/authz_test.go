@@ -0,0 +1,316 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/accessreview"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store/storetest"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// aliceAccess holds the namespaces where alice can list pods
+type aliceAccess struct {
+	mutex      sync.Mutex
+	namespaces map[string]bool
+}
+
+func (a *aliceAccess) set(namespaces ...string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.namespaces = map[string]bool{}
+	for _, namespace := range namespaces {
+		a.namespaces[namespace] = true
+	}
+}
+
+func (a *aliceAccess) allows(namespace string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.namespaces[namespace]
+}
+
+// startAuthzServer starts a server reviewing users with a fake cluster where
+// alice can only list pods in dev and admin can do everything, pods and
+// nodes are watched and pprof is enabled
+func startAuthzServer(t *testing.T) (*httptest.Server, *store.Store) {
+	t.Helper()
+	access := &aliceAccess{}
+	access.set("dev")
+	return startAuthzServerWithAccess(t, time.Minute, access)
+}
+
+// startAuthzServerWithAccess starts the server of startAuthzServer caching
+// reviews for ttl, alice can list pods in the namespaces of access
+func startAuthzServerWithAccess(t *testing.T, ttl time.Duration, access *aliceAccess) (*httptest.Server, *store.Store) {
+	t.Helper()
+	cs := corefake.NewClientset()
+	cs.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authnv1.TokenReview)
+		out := &authnv1.TokenReview{}
+		switch review.Spec.Token {
+		case "alice-token":
+			out.Status = authnv1.TokenReviewStatus{Authenticated: true, User: authnv1.UserInfo{Username: "alice"}}
+		case "admin-token":
+			out.Status = authnv1.TokenReviewStatus{Authenticated: true, User: authnv1.UserInfo{Username: "admin"}}
+		}
+		return true, out, nil
+	})
+	cs.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+		attr := review.Spec.ResourceAttributes
+		allowed := review.Spec.User == "admin" ||
+			(attr != nil && attr.Verb == "list" && attr.Resource == "pods" && access.allows(attr.Namespace))
+		return true, &authzv1.SubjectAccessReview{
+			Status: authzv1.SubjectAccessReviewStatus{Allowed: allowed},
+		}, nil
+	})
+
+	tempDir, podStore := storetest.GetTestPodStore(t)
+	for _, ns := range []string{"dev", "prod"} {
+		pod := storetest.PodResource("Test1", ns, nil)
+		podStore.AddResource(&pod)
+	}
+	if err := podStore.DumpFullState(); err != nil {
+		t.Fatalf("DumpFullState() error = %v", err)
+	}
+	storeConfig := store.NewStoreConfig(&store.StoreConfigCli{
+		ClusterConfigCli:    &clusterconfig.ClusterConfigCli{ClusterName: "test", CacheDir: tempDir},
+		TimeBetweenFullDump: time.Minute,
+	})
+	newReviewer := func() (*accessreview.Reviewer, error) {
+		return accessreview.NewReviewer(cs, ttl), nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	nodeStore := store.NewStore(ctx, storeConfig, resources.CtorConfig{}, resources.ResourceTypeNode)
+	nodeStore.SetSyncState(store.SyncStateSynced)
+	f := &FzfHttpServer{newReviewer: newReviewer, storeConfig: storeConfig, pprof: true}
+	if err := f.SetStores([]*store.Store{podStore, nodeStore}); err != nil {
+		t.Fatalf("SetStores() error = %v", err)
+	}
+	srv := httptest.NewServer(f.setupRouter())
+	t.Cleanup(srv.Close)
+	return srv, podStore
+}
+
+func getWithToken(t *testing.T, url string, token string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	return resp, b
+}
+
+func TestKubernetesAuthentication(t *testing.T) {
+	srv, _ := startAuthzServer(t)
+	for _, token := range []string{"", "bad-token"} {
+		resp, _ := getWithToken(t, srv.URL+"/stats", token)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401 with token %q, got %d", token, resp.StatusCode)
+		}
+	}
+	resp, _ := getWithToken(t, srv.URL+"/readiness", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected readiness without token, got %d", resp.StatusCode)
+	}
+}
+
+func TestKubernetesAuthzFiltersNamespaces(t *testing.T) {
+	srv, podStore := startAuthzServer(t)
+
+	resp, b := getWithToken(t, srv.URL+"/k8s/resources/pods", "alice-token")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, b)
+	}
+	pods, _, err := store.DecodeResources(b, resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("DecodeResources() error = %v", err)
+	}
+	if len(pods) != 1 || pods["dev_Test1"] == nil {
+		t.Fatalf("expected only dev_Test1, got %v", pods)
+	}
+
+	// Changes in prod are filtered from the deltas but still advance the revision
+	etag := resp.Header.Get("ETag")
+	pod := storetest.PodResource("Test2", "prod", nil)
+	podStore.AddResource(&pod)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/k8s/resources/pods", nil)
+	req.Header.Set("Authorization", "Bearer alice-token")
+	req.Header.Set("If-None-Match", etag)
+	req.Header.Set("A-IM", DeltaInstanceManipulation)
+	deltaResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	b, _ = io.ReadAll(deltaResp.Body)
+	deltaResp.Body.Close()
+	if deltaResp.StatusCode != http.StatusIMUsed {
+		t.Fatalf("expected 226, got %d", deltaResp.StatusCode)
+	}
+	deltas, _, err := store.DecodeDeltas(b, resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("DecodeDeltas() error = %v", err)
+	}
+	if len(deltas) != 0 {
+		t.Fatalf("expected prod deltas to be filtered, got %v", deltas)
+	}
+	if deltaResp.Header.Get("ETag") == etag {
+		t.Fatalf("expected a new ETag")
+	}
+	if vary := deltaResp.Header.Get("Vary"); vary != "Authorization" {
+		t.Fatalf("expected Vary: Authorization, got %q", vary)
+	}
+
+	// The body filtered for alice isn't the one of admin
+	req.Header.Set("Authorization", "Bearer admin-token")
+	req.Header.Set("If-None-Match", deltaResp.Header.Get("ETag"))
+	adminResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	adminResp.Body.Close()
+	if adminResp.StatusCode != http.StatusOK || adminResp.Header.Get("ETag") == deltaResp.Header.Get("ETag") {
+		t.Fatalf("expected a full response with another ETag, got %d %s", adminResp.StatusCode, adminResp.Header.Get("ETag"))
+	}
+
+	resp, _ = getWithToken(t, srv.URL+"/k8s/resources/deployments", "alice-token")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 on deployments, got %d", resp.StatusCode)
+	}
+
+	resp, b = getWithToken(t, srv.URL+"/stats", "alice-token")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 on stats, got %d", resp.StatusCode)
+	}
+	stats := []*store.Stats{}
+	if err := json.Unmarshal(b, &stats); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(stats) != 1 || len(stats[0].ItemPerNamespace) != 1 || stats[0].ItemPerNamespace["dev"] != 1 {
+		t.Fatalf("expected stats of dev only, got %v", stats[0])
+	}
+
+	resp, b = getWithToken(t, srv.URL+"/health", "alice-token")
+	report := store.HealthReport{}
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(report.Resources) != 1 || report.Resources[0].ResourceType != resources.ResourceTypePod {
+		t.Fatalf("expected the health of pods only, got %d %+v", resp.StatusCode, report)
+	}
+
+	resp, b = getWithToken(t, srv.URL+"/k8s/resources/pods", "admin-token")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for admin, got %d", resp.StatusCode)
+	}
+	pods, _, err = store.DecodeResources(b, resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("DecodeResources() error = %v", err)
+	}
+	if pods["dev_Test1"] == nil || pods["prod_Test1"] == nil {
+		t.Fatalf("expected the dumped pods of all namespaces, got %v", pods)
+	}
+}
+
+func TestKubernetesAuthzPprof(t *testing.T) {
+	srv, _ := startAuthzServer(t)
+	resp, _ := getWithToken(t, srv.URL+"/debug/pprof/goroutine?debug=1", "alice-token")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for alice, got %d", resp.StatusCode)
+	}
+	resp, _ = getWithToken(t, srv.URL+"/debug/pprof/goroutine?debug=1", "admin-token")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for admin, got %d", resp.StatusCode)
+	}
+}
+
+func TestKubernetesAuthzWatchReview(t *testing.T) {
+	access := &aliceAccess{}
+	access.set("dev")
+	srv, podStore := startAuthzServerWithAccess(t, 100*time.Millisecond, access)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/watch?types=pods", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer alice-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	// next returns the next event, empty once the stream is closed
+	next := func() (event string, data string) {
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "" && event != "":
+				return event, data
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		return "", ""
+	}
+
+	// Namespaces granted after the start of the stream are sent once the
+	// access is reviewed again
+	access.set("dev", "prod")
+	time.Sleep(300 * time.Millisecond)
+	pod := storetest.PodResource("Test2", "prod", nil)
+	podStore.AddResource(&pod)
+	if event, data := next(); event != "add" || !strings.Contains(data, `"namespace":"prod"`) {
+		t.Fatalf("expected the add of prod/Test2, got %s %s", event, data)
+	}
+
+	// A revoked access closes the stream
+	access.set()
+	event, data := next()
+	watchError := WatchError{}
+	if err := json.Unmarshal([]byte(data), &watchError); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if event != "error" || watchError.ResourceType != "pods" || watchError.Message != "access revoked" {
+		t.Fatalf("expected an error event, got %s %s", event, data)
+	}
+	if event, data := next(); event != "" {
+		t.Fatalf("expected the stream to be closed, got %s %s", event, data)
+	}
+}
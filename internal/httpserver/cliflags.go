@@ -2,6 +2,7 @@ package httpserver
 
 import (
 	"flag"
+	"time"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/util/config"
 )
@@ -9,19 +10,44 @@ import (
 type HttpServerConfigCli struct {
 	ListenAddress   string
 	HttpProfAddress string
+	// Pprof serves /debug/pprof on the main listener
+	Pprof           bool
 	Debug           bool
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	AuthTokenFile   string
+	// KubernetesAuthz authenticates requests with a TokenReview and only
+	// serves what the user could list with kubectl
+	KubernetesAuthz    bool
+	KubernetesAuthzTTL time.Duration
 }
 
 func SetHttpServerConfigFlags(fs *flag.FlagSet) {
-	fs.String("listen-address", "localhost:8080", "Listen address of the http server")
-	fs.String("http-prof-address", "localhost:6060", "Listen address of the pprof endpoint")
+	fs.String("listen-address", "localhost:8080", "Listen address of the http server, host:port or unix:///path/to/socket")
+	fs.String("http-prof-address", "", "Listen address of a dedicated pprof endpoint without authentication, disabled when empty")
+	fs.Bool("http-pprof", false, "Serve /debug/pprof on the listen address, behind its authentication")
 	fs.Bool("http-debug", false, "Activate debug mode of the http server")
+	fs.String("tls-cert-file", "", "Certificate of the http server, serves https when set with --tls-key-file")
+	fs.String("tls-key-file", "", "Key of the http server certificate")
+	fs.String("tls-client-ca-file", "", "CA bundle verifying client certificates, clients without a valid certificate are rejected when set")
+	fs.String("auth-token-file", "", "File holding the bearer token required on all requests but /readiness")
+	fs.Bool("kubernetes-authz", false, "Authenticate bearer tokens with the cluster and only serve the resources users are allowed to list")
+	fs.Duration("kubernetes-authz-ttl", 30*time.Second, "Duration for which token and access reviews are cached")
 }
 
 func NewHttpServerConfigCli(store *config.Store) HttpServerConfigCli {
 	return HttpServerConfigCli{
 		ListenAddress:   store.GetString("listen-address", "localhost:8080"),
-		HttpProfAddress: store.GetString("http-prof-address", "localhost:6060"),
+		HttpProfAddress: store.GetString("http-prof-address", ""),
+		Pprof:           store.GetBool("http-pprof", false),
 		Debug:           store.GetBool("http-debug", false),
+		TLSCertFile:     store.GetString("tls-cert-file", ""),
+		TLSKeyFile:      store.GetString("tls-key-file", ""),
+		TLSClientCAFile: store.GetString("tls-client-ca-file", ""),
+		AuthTokenFile:   store.GetString("auth-token-file", ""),
+
+		KubernetesAuthz:    store.GetBool("kubernetes-authz", false),
+		KubernetesAuthzTTL: store.GetDuration("kubernetes-authz-ttl", 30*time.Second),
 	}
 }
@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DeltaInstanceManipulation is the A-IM value asking resource routes to
+// answer with the changes since the If-None-Match revision
+const DeltaInstanceManipulation = "kubectl-fzf-delta"
+
+// ResourceETag returns the strong ETag of a store state
+func ResourceETag(epoch int64, revision uint64) string {
+	return filteredResourceETag(epoch, revision, "")
+}
+
+// filteredResourceETag returns the ETag of a store state restricted to the
+// namespaces identified by variant, see namespaceFilter.etagVariant
+// Bodies filtered differently never share an ETag.
+func filteredResourceETag(epoch int64, revision uint64, variant string) string {
+	if variant == "" {
+		return fmt.Sprintf("\"%d-%d\"", epoch, revision)
+	}
+	return fmt.Sprintf("\"%d-%d-%s\"", epoch, revision, variant)
+}
+
+// ParseResourceETag returns the epoch and revision of an ETag built by
+// ResourceETag
+func ParseResourceETag(etag string) (epoch int64, revision uint64, ok bool) {
+	epoch, revision, _, ok = parseFilteredResourceETag(etag)
+	return epoch, revision, ok
+}
+
+func parseFilteredResourceETag(etag string) (epoch int64, revision uint64, variant string, ok bool) {
+	etag = strings.TrimSpace(etag)
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return 0, 0, "", false
+	}
+	parts := strings.SplitN(etag[1:len(etag)-1], "-", 3)
+	if len(parts) < 2 {
+		return 0, 0, "", false
+	}
+	epoch, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, "", false
+	}
+	revision, err = strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, "", false
+	}
+	if len(parts) == 3 {
+		variant = parts[2]
+	}
+	return epoch, revision, variant, true
+}
+
+func acceptsDeltas(aIM string) bool {
+	for _, im := range strings.Split(aIM, ",") {
+		if strings.TrimSpace(im) == DeltaInstanceManipulation {
+			return true
+		}
+	}
+	return false
+}
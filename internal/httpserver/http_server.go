@@ -1,27 +1,98 @@
 package httpserver
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/accessreview"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
 
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	"github.com/pkg/errors"
 )
 
 type FzfHttpServer struct {
-	Port        int
-	ResourceHit int
-	//LastModifiedHit int
+	Port int
 
-	stores      []*store.Store
+	// authToken is the bearer token required by requests, disabled when empty
+	authToken string
+	// pprof mounts /debug/pprof on the router
+	pprof bool
+	// newReviewer builds the reviewer of the watched cluster, nil when
+	// Kubernetes authorization is disabled
+	newReviewer func() (*accessreview.Reviewer, error)
+
+	resourceHit atomic.Int64
+	// lastHit is the time of the last resource request, in unix nanoseconds
+	lastHit     atomic.Int64
 	storeConfig *store.StoreConfig
+
+	storesMutex sync.RWMutex
+	stores      []*store.Store
+	reviewer    *accessreview.Reviewer
+}
+
+// ResourceHits returns the number of full resource files served
+func (f *FzfHttpServer) ResourceHits() int64 {
+	return f.resourceHit.Load()
+}
+
+// LastHit returns the time of the last resource request, zero without
+// request
+func (f *FzfHttpServer) LastHit() time.Time {
+	lastHit := f.lastHit.Load()
+	if lastHit == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, lastHit)
+}
+
+// SetStores replaces the stores used to build responses
+// This is called when the watched cluster changes: users are then reviewed
+// by the new cluster
+func (f *FzfHttpServer) SetStores(stores []*store.Store) error {
+	var reviewer *accessreview.Reviewer
+	if f.newReviewer != nil {
+		var err error
+		reviewer, err = f.newReviewer()
+		if err != nil {
+			return err
+		}
+	}
+	f.storesMutex.Lock()
+	defer f.storesMutex.Unlock()
+	f.stores = stores
+	f.reviewer = reviewer
+	return nil
+}
+
+func (f *FzfHttpServer) getReviewer() *accessreview.Reviewer {
+	f.storesMutex.RLock()
+	defer f.storesMutex.RUnlock()
+	return f.reviewer
+}
+
+func (f *FzfHttpServer) getStores() []*store.Store {
+	f.storesMutex.RLock()
+	defer f.storesMutex.RUnlock()
+	return f.stores
 }
 
 type routeResourceFunc func(http.ResponseWriter, *http.Request, resources.ResourceType)
@@ -32,15 +103,47 @@ func curryResourceRoute(f routeResourceFunc, resourceType resources.ResourceType
 	}
 }
 
+// readinessRoute succeeds once every store is synced or deliberately stopped
 func (f *FzfHttpServer) readinessRoute(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	notReady := []string{}
+	for _, h := range store.GetHealthFromStores(f.getStores()) {
+		if !h.Ready() {
+			notReady = append(notReady, fmt.Sprintf("%s is %s", h.ResourceType, h.State))
+		}
+	}
+	if len(notReady) > 0 {
+		http.Error(w, fmt.Sprintf("Not ready: %s", strings.Join(notReady, ", ")), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
 		_, _ = w.Write([]byte("Ok"))
-	case http.MethodHead:
-		w.WriteHeader(http.StatusOK)
-	default:
+	}
+}
+
+// healthRoute serves the sync state of the stores the user can list
+func (f *FzfHttpServer) healthRoute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	healths, err := f.filterHealths(r, store.GetHealthFromStores(f.getStores()))
+	if err != nil {
+		log.Errorf("Error reviewing access to health: %s", err)
+		http.Error(w, "error reviewing access", http.StatusServiceUnavailable)
+		return
+	}
+	report := store.HealthReport{Ready: store.AllReady(healths), Resources: healths}
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Errorf("unable to encode health response: %v", err)
 	}
 }
 
@@ -49,7 +152,12 @@ func (f *FzfHttpServer) statsRoute(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
-	stats := store.GetStatsFromStores(f.stores)
+	stats, err := f.filterStats(r, store.GetStatsFromStores(f.getStores()))
+	if err != nil {
+		log.Errorf("Error reviewing access to stats: %s", err)
+		http.Error(w, "error reviewing access", http.StatusServiceUnavailable)
+		return
+	}
 	log.Debugf("Sending stats: %v", stats)
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(stats); err != nil {
@@ -57,43 +165,256 @@ func (f *FzfHttpServer) statsRoute(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// resourcesRoute serves the resources of resourceType with a strong ETag
+// When If-None-Match holds the current state, 304 Not Modified is returned.
+// Clients sending the DeltaInstanceManipulation A-IM get 226 IM Used with the
+// changes since their revision when the store still has them.
+// Full responses are built from the store state, the resource file can be up
+// to --time-between-full-dump old. The file is only served until the store is
+// listed, and for api resources which have no store.
+// Users only allowed to list some namespaces get the filtered store state.
 func (f *FzfHttpServer) resourcesRoute(w http.ResponseWriter, r *http.Request, resourceType resources.ResourceType) {
-	switch r.Method {
-	case http.MethodGet:
-		f.ResourceHit++
-	case http.MethodHead:
-	// handled below without incrementing hits
-	default:
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
+	f.lastHit.Store(time.Now().UnixNano())
 	if resourceType == resources.ResourceTypeUnknown {
 		http.Error(w, "Resource type unknown", http.StatusBadRequest)
 		return
 	}
+	filter, ok := f.checkListAccess(w, r, resourceType)
+	if !ok {
+		return
+	}
+	if f.getReviewer() != nil {
+		w.Header().Set("Vary", "Authorization")
+	}
+	if s := f.getStore(resourceType); s != nil && (filter != nil || !s.GetHealth().LastSynced.IsZero()) {
+		f.serveStoreResources(w, r, s, filter)
+		return
+	}
 	if !f.storeConfig.FileStoreExists(resourceType) {
 		http.Error(w, fmt.Sprintf("resource file for %s not found", resourceType), http.StatusNotFound)
 		return
 	}
 	filePath := f.storeConfig.GetResourceStorePath(resourceType)
+	// Keep the file opened: it can be replaced by a new dump while serving
+	file, err := os.Open(filePath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error opening resource file for %s", resourceType), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	finfo, err := file.Stat()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error reading resource file for %s", resourceType), http.StatusInternalServerError)
+		return
+	}
+	header, err := util.ReadCacheHeader(file)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error reading resource file for %s: %s", resourceType, err), http.StatusInternalServerError)
+		return
+	}
+	fileETag := ResourceETag(header.Epoch, header.Revision)
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if f.serveDeltas(w, r, resourceType, ifNoneMatch, nil) {
+		return
+	}
+	w.Header().Set("ETag", fileETag)
+	if ifNoneMatch == fileETag {
+		resourceResponsesCounter.WithLabelValues(resourceType.String(), "not_modified").Inc()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, fmt.Sprintf("error reading resource file for %s", resourceType), http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodGet {
+		f.resourceHit.Add(1)
+		resourceResponsesCounter.WithLabelValues(resourceType.String(), "full").Inc()
+	}
 	log.Debugf("Serving file %s", filePath)
-	http.ServeFile(w, r, filePath)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, filePath, finfo.ModTime(), file)
+}
+
+// serveStoreResources serves the current state of s, restricted to the
+// namespaces of filter, encoded like resource files
+func (f *FzfHttpServer) serveStoreResources(w http.ResponseWriter, r *http.Request,
+	s *store.Store, filter namespaceFilter) {
+	resourceType := s.GetResourceType()
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if f.serveDeltas(w, r, resourceType, ifNoneMatch, filter) {
+		return
+	}
+	data, revision := s.GetState()
+	etag := filteredResourceETag(s.GetEpoch(), revision, filter.etagVariant())
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch == etag {
+		resourceResponsesCounter.WithLabelValues(resourceType.String(), "not_modified").Inc()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	data = filter.filterResources(data)
+	var buf bytes.Buffer
+	if err := util.Encode(&buf, data, s.CacheHeader(len(data), revision)); err != nil {
+		http.Error(w, fmt.Sprintf("error encoding resources of %s", resourceType), http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodGet {
+		f.resourceHit.Add(1)
+		resourceResponsesCounter.WithLabelValues(resourceType.String(), "full").Inc()
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, resourceType.String(), time.Time{}, bytes.NewReader(buf.Bytes()))
+}
+
+// serveDeltas answers with 304 or the changes since the revision of ifNoneMatch
+// Changes outside of the namespaces of filter are dropped.
+// It returns false when the store can't provide them
+func (f *FzfHttpServer) serveDeltas(w http.ResponseWriter, r *http.Request, resourceType resources.ResourceType,
+	ifNoneMatch string, filter namespaceFilter) bool {
+	// The changes of another filter don't apply to the cached body
+	epoch, revision, variant, ok := parseFilteredResourceETag(ifNoneMatch)
+	if !ok || variant != filter.etagVariant() {
+		return false
+	}
+	s := f.getStore(resourceType)
+	if s == nil || s.GetEpoch() != epoch {
+		return false
+	}
+	deltas, currentRevision, ok := s.GetDeltasSince(epoch, revision)
+	if !ok {
+		return false
+	}
+	w.Header().Set("ETag", filteredResourceETag(epoch, currentRevision, variant))
+	if currentRevision == revision {
+		resourceResponsesCounter.WithLabelValues(resourceType.String(), "not_modified").Inc()
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	// Filtered out changes still need a 226 for the client to move to
+	// currentRevision
+	deltas = filter.filterDeltas(deltas)
+	if !acceptsDeltas(r.Header.Get("A-IM")) {
+		w.Header().Del("ETag")
+		return false
+	}
+	resourceResponsesCounter.WithLabelValues(resourceType.String(), "delta").Inc()
+	w.Header().Set("IM", DeltaInstanceManipulation)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusIMUsed)
+	if r.Method == http.MethodHead {
+		return true
+	}
+	if err := store.EncodeDeltas(w, s.CacheHeader(len(deltas), currentRevision), deltas); err != nil {
+		log.Errorf("unable to encode deltas of %s: %v", resourceType, err)
+	}
+	return true
+}
+
+func (f *FzfHttpServer) getStore(resourceType resources.ResourceType) *store.Store {
+	for _, s := range f.getStores() {
+		if s.GetResourceType() == resourceType {
+			return s
+		}
+	}
+	return nil
+}
+
+// deltasRoute serves the changes of a store after the since revision of epoch
+// 304 Not Modified is returned when there's no change and 410 Gone when the
+// changes are not available anymore and the full resource file needs to be
+// fetched
+// Deprecated: resourcesRoute serves the same changes to clients sending the
+// DeltaInstanceManipulation A-IM with the ETag of their revision.
+func (f *FzfHttpServer) deltasRoute(w http.ResponseWriter, r *http.Request, resourceType resources.ResourceType) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Deprecation", "true")
+	epoch, err := strconv.ParseInt(r.URL.Query().Get("epoch"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid epoch: %s", err), http.StatusBadRequest)
+		return
+	}
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid since: %s", err), http.StatusBadRequest)
+		return
+	}
+	filter, ok := f.checkListAccess(w, r, resourceType)
+	if !ok {
+		return
+	}
+	s := f.getStore(resourceType)
+	if s == nil {
+		http.Error(w, fmt.Sprintf("no store for %s", resourceType), http.StatusNotFound)
+		return
+	}
+	deltas, revision, ok := s.GetDeltasSince(epoch, since)
+	if !ok {
+		http.Error(w, fmt.Sprintf("deltas of %s since %d.%d are not available", resourceType, epoch, since), http.StatusGone)
+		return
+	}
+	if revision == since {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	deltas = filter.filterDeltas(deltas)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := store.EncodeDeltas(w, s.CacheHeader(len(deltas), revision), deltas); err != nil {
+		log.Errorf("unable to encode deltas of %s: %v", resourceType, err)
+	}
 }
 
 func (f *FzfHttpServer) setupRouter() http.Handler {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/readiness", f.readinessRoute)
-	mux.HandleFunc("/stats", f.statsRoute)
+	routes := map[string]struct{}{}
+	handleFunc := func(path string, handler http.HandlerFunc) {
+		routes[path] = struct{}{}
+		mux.HandleFunc(path, handler)
+	}
+	handleFunc("/readiness", f.readinessRoute)
+	handleFunc("/health", f.healthRoute)
+	handleFunc("/stats", f.statsRoute)
+	handleFunc("/metrics", f.metricsRoute)
+	handleFunc("/api/v1/watch", f.watchRoute)
 
 	for r := resources.ResourceTypeApiResource; r < resources.ResourceTypeUnknown; r++ {
 		path := fmt.Sprintf("/k8s/resources/%s", r.String())
-		mux.HandleFunc(path, curryResourceRoute(f.resourcesRoute, r))
+		handleFunc(path, curryResourceRoute(f.resourcesRoute, r))
+		deltasPath := fmt.Sprintf("/k8s/deltas/%s", r.String())
+		handleFunc(deltasPath, curryResourceRoute(f.deltasRoute, r))
+		apiPath := fmt.Sprintf("/api/v1/%s", r.String())
+		handleFunc(apiPath, curryResourceRoute(f.apiListRoute, r))
+	}
+	if f.pprof {
+		registerPprof(mux, f.authorizePprof)
 	}
 
 	skipLogs := map[string]struct{}{
-		"/health": {},
+		"/health":  {},
+		"/metrics": {},
+	}
+	var handler http.Handler = mux
+	if f.authToken != "" {
+		skipAuth := map[string]struct{}{
+			"/readiness": {},
+		}
+		handler = authMiddleware(f.authToken, skipAuth, handler)
 	}
-	return recoveryMiddleware(loggingMiddleware(skipLogs, mux))
+	if f.newReviewer != nil {
+		skipAuth := map[string]struct{}{
+			"/readiness": {},
+		}
+		handler = f.kubernetesAuthMiddleware(skipAuth, handler)
+	}
+	return recoveryMiddleware(loggingMiddleware(skipLogs, routes, handler))
 }
 
 type loggingResponseWriter struct {
@@ -111,21 +432,29 @@ func (w *loggingResponseWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
+// Unwrap gives http.ResponseController access to the flusher of streams
+func (w *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (w *loggingResponseWriter) Write(b []byte) (int, error) {
 	n, err := w.ResponseWriter.Write(b)
 	w.bytes += n
 	return n, err
 }
 
-func loggingMiddleware(skipPaths map[string]struct{}, next http.Handler) http.Handler {
+// loggingMiddleware logs requests, but the ones of skipPaths, and records
+// their metrics by route
+func loggingMiddleware(skipPaths map[string]struct{}, routes map[string]struct{}, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		lrw := newLoggingResponseWriter(w)
 		next.ServeHTTP(lrw, r)
+		duration := time.Since(start)
+		observeRequest(routes, r, lrw.status, duration.Seconds())
 		if _, ok := skipPaths[r.URL.Path]; ok {
 			return
 		}
-		duration := time.Since(start)
 		log.Infof("%s %s %d %s %dB", r.Method, r.URL.Path, lrw.status, duration, lrw.bytes)
 	})
 }
@@ -159,17 +488,123 @@ func startHttpServer(ctx context.Context, listener net.Listener, srv *http.Serve
 	log.Info("Exiting http server")
 }
 
+// listen opens the listener of endpoint
+// Unix sockets are only accessible by the current user: a missing parent
+// directory is created with 0700 and the socket is created with 0600.
+func listen(endpoint util.HttpEndpoint) (net.Listener, error) {
+	if endpoint.Network != "unix" {
+		return net.Listen(endpoint.Network, endpoint.Address)
+	}
+	if err := checkSocketDir(filepath.Dir(endpoint.Address)); err != nil {
+		return nil, err
+	}
+	if finfo, err := os.Lstat(endpoint.Address); err == nil {
+		if finfo.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", endpoint.Address)
+		}
+		if endpoint.IsReachable() {
+			return nil, fmt.Errorf("%s is already used by another server", endpoint.Address)
+		}
+		log.Infof("Removing stale socket %s", endpoint.Address)
+		if err := os.Remove(endpoint.Address); err != nil {
+			return nil, errors.Wrapf(err, "error removing stale socket %s", endpoint.Address)
+		}
+	}
+	// The socket is created with the umask, restrict it for the socket to
+	// never be reachable by others, even before a chmod
+	oldUmask := syscall.Umask(0o177)
+	listener, err := net.Listen("unix", endpoint.Address)
+	syscall.Umask(oldUmask)
+	return listener, err
+}
+
+// checkSocketDir creates dir with 0700 if missing
+// An existing directory is kept as it is, unless other users can write in
+// it: they could replace the socket.
+func checkSocketDir(dir string) error {
+	finfo, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return errors.Wrapf(err, "error creating directory %s", dir)
+		}
+		if err := os.Chmod(dir, 0o700); err != nil {
+			return errors.Wrapf(err, "error setting permissions of %s", dir)
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error reading directory %s", dir)
+	}
+	if !finfo.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if finfo.Mode().Perm()&0o022 != 0 && finfo.Mode()&os.ModeSticky == 0 {
+		return fmt.Errorf("%s is writable by other users (%o), use a 0700 directory for the socket", dir, finfo.Mode().Perm())
+	}
+	if finfo.Mode().Perm()&0o077 != 0 {
+		log.Warnf("%s is accessible by other users (%o), the socket itself is only accessible by its owner", dir, finfo.Mode().Perm())
+	}
+	return nil
+}
+
 func StartHttpServer(ctx context.Context, h *HttpServerConfigCli, storeConfig *store.StoreConfig, stores []*store.Store) (*FzfHttpServer, error) {
 	if h.ListenAddress == "" {
 		return nil, nil
 	}
-	listener, err := net.Listen("tcp", h.ListenAddress)
+	endpoint, err := util.ParseHttpEndpoint(h.ListenAddress)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := getTLSConfig(h)
+	if err != nil {
+		return nil, err
+	}
+	authToken := ""
+	if h.AuthTokenFile != "" {
+		authToken, err = util.ReadTokenFile(h.AuthTokenFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if h.KubernetesAuthz && authToken != "" {
+		return nil, errors.New("--kubernetes-authz and --auth-token-file are mutually exclusive")
+	}
+	var newReviewer func() (*accessreview.Reviewer, error)
+	var reviewer *accessreview.Reviewer
+	if h.KubernetesAuthz {
+		newReviewer = func() (*accessreview.Reviewer, error) {
+			clientset, err := storeConfig.GetClientset()
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting clientset for access reviews")
+			}
+			return accessreview.NewReviewer(clientset, h.KubernetesAuthzTTL), nil
+		}
+		reviewer, err = newReviewer()
+		if err != nil {
+			return nil, err
+		}
+	}
+	authenticated := authToken != "" || reviewer != nil || (tlsConfig != nil && tlsConfig.ClientCAs != nil)
+	if !authenticated && !endpoint.IsLoopback() {
+		log.Warnf("%s is reachable from the network without authentication, resources can be read by anyone", h.ListenAddress)
+	}
+	listener, err := listen(endpoint)
 	if err != nil {
 		return nil, err
 	}
-	port := listener.Addr().(*net.TCPAddr).Port
-	f := FzfHttpServer{
+	port := 0
+	if tcpAddr, ok := listener.Addr().(*net.TCPAddr); ok {
+		port = tcpAddr.Port
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	f := &FzfHttpServer{
 		Port:        port,
+		authToken:   authToken,
+		pprof:       h.Pprof,
+		newReviewer: newReviewer,
+		reviewer:    reviewer,
 		stores:      stores,
 		storeConfig: storeConfig,
 	}
@@ -179,5 +614,5 @@ func StartHttpServer(ctx context.Context, h *HttpServerConfigCli, storeConfig *s
 		Handler: router,
 	}
 	go startHttpServer(ctx, listener, srv)
-	return &f, nil
+	return f, nil
 }
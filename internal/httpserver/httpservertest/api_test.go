@@ -0,0 +1,87 @@
+package httpservertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/httpserver"
+)
+
+func getApiList(t *testing.T, port int, resourceType string, query url.Values) (int, httpserver.ApiListResponse) {
+	t.Helper()
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/api/v1/%s?%s", port, resourceType, query.Encode()))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	res := httpserver.ApiListResponse{}
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, res
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	return resp.StatusCode, res
+}
+
+func itemNames(res httpserver.ApiListResponse) []string {
+	names := []string{}
+	for _, item := range res.Items {
+		names = append(names, fmt.Sprintf("%s/%s", item.Namespace, item.Name))
+	}
+	return names
+}
+
+func TestApiList(t *testing.T) {
+	fzfHttpServer, podStore := StartTestHttpServerWithStore(t)
+
+	tests := []struct {
+		name          string
+		query         url.Values
+		expectedNames []string
+		expectedTotal int
+	}{
+		{"all", url.Values{}, []string{"aaa/Test4", "ns1/Test1", "ns2/Test2", "ns2/Test3"}, 4},
+		{"namespace", url.Values{"namespace": {"ns2"}}, []string{"ns2/Test2", "ns2/Test3"}, 2},
+		{"label selector", url.Values{"labelSelector": {"app in (app1,app3)"}}, []string{"aaa/Test4", "ns1/Test1"}, 2},
+		{"field selector", url.Values{"fieldSelector": {"metadata.name=Test3"}}, []string{"ns2/Test3"}, 1},
+		{"query", url.Values{"q": {"test2"}}, []string{"ns2/Test2"}, 1},
+		{"limit", url.Values{"namespace": {"ns2"}, "limit": {"1"}}, []string{"ns2/Test2"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, res := getApiList(t, fzfHttpServer.Port, "pods", tt.query)
+			if status != http.StatusOK {
+				t.Fatalf("expected 200, got %d", status)
+			}
+			if fmt.Sprint(itemNames(res)) != fmt.Sprint(tt.expectedNames) {
+				t.Fatalf("expected %v, got %v", tt.expectedNames, itemNames(res))
+			}
+			if res.Total != tt.expectedTotal {
+				t.Fatalf("expected total %d, got %d", tt.expectedTotal, res.Total)
+			}
+			if res.Epoch != podStore.GetEpoch() || res.ResourceType != "pods" {
+				t.Fatalf("unexpected response metadata %+v", res)
+			}
+			for _, item := range res.Items {
+				if len(item.Rows) != 1 || len(item.Rows[0]) != len(res.Columns) {
+					t.Fatalf("expected a row of %d columns, got %v", len(res.Columns), item.Rows)
+				}
+			}
+		})
+	}
+
+	status, _ := getApiList(t, fzfHttpServer.Port, "pods", url.Values{"labelSelector": {"app in ("}})
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected 400 on invalid selector, got %d", status)
+	}
+	status, _ = getApiList(t, fzfHttpServer.Port, "deployments", url.Values{})
+	if status != http.StatusNotFound {
+		t.Fatalf("expected 404 without store, got %d", status)
+	}
+}
@@ -2,11 +2,21 @@ package httpservertest
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"os"
+	"path"
 	"testing"
 
+	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
 	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher/fetchertest"
+	"github.com/codeactual/kubectl-fzf/v4/internal/httpserver"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store/storetest"
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
 )
 
 func TestMain(m *testing.M) {
@@ -16,7 +26,7 @@ func TestMain(m *testing.M) {
 }
 
 func TestHttpServerApiCompletion(t *testing.T) {
-	fzfHttpServer := StartTestHttpServer(t)
+	fzfHttpServer, _ := StartTestHttpServerWithStore(t)
 	f, _ := fetchertest.GetTestFetcher(t, "nothing", fzfHttpServer.Port)
 	ctx := context.Background()
 	s, err := f.GetStats(ctx)
@@ -27,3 +37,223 @@ func TestHttpServerApiCompletion(t *testing.T) {
 		t.Fatalf("expected 1 stat, got %d", len(s))
 	}
 }
+
+func TestHttpServerDeltas(t *testing.T) {
+	fzfHttpServer, podStore := StartTestHttpServerWithStore(t)
+	baseUrl := fmt.Sprintf("http://localhost:%d/k8s/deltas/pods", fzfHttpServer.Port)
+
+	headers, b, err := util.GetFromHttpServer(http.DefaultClient, fmt.Sprintf("%s?epoch=%d&since=1", baseUrl, podStore.GetEpoch()))
+	if err != nil {
+		t.Fatalf("GetFromHttpServer() error = %v", err)
+	}
+	if headers.Get("Deprecation") != "true" {
+		t.Fatalf("expected the deprecated endpoint to be flagged, got headers %v", headers)
+	}
+	deltas, header, err := store.DecodeDeltas(b, resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("DecodeDeltas() error = %v", err)
+	}
+	if len(deltas) != 3 || header.Revision != 4 || header.Epoch != podStore.GetEpoch() {
+		t.Fatalf("expected 3 deltas up to revision 4, got %d, header %+v", len(deltas), header)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s?epoch=%d&since=4", baseUrl, podStore.GetEpoch()))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304 at current revision, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("%s?epoch=%d&since=1", baseUrl, podStore.GetEpoch()+1))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGone {
+		t.Fatalf("expected 410 for another epoch, got %d", resp.StatusCode)
+	}
+}
+
+func TestHttpServerServesStoreState(t *testing.T) {
+	fzfHttpServer, podStore := startDumpedPodServer(t)
+	url := fmt.Sprintf("http://localhost:%d/k8s/resources/pods", fzfHttpServer.Port)
+
+	// Changes not dumped yet are part of full responses
+	pod := storetest.PodResource("Test5", "ns1", nil)
+	podStore.AddResource(&pod)
+	headers, b, err := util.GetFromHttpServer(http.DefaultClient, url)
+	if err != nil {
+		t.Fatalf("GetFromHttpServer() error = %v", err)
+	}
+	pods, header, err := store.DecodeResources(b, resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("DecodeResources() error = %v", err)
+	}
+	if _, ok := pods["ns1_Test5"]; !ok || len(pods) != 5 {
+		t.Fatalf("expected 5 pods with ns1_Test5, got %v", pods)
+	}
+	if headers.Get("ETag") != httpserver.ResourceETag(podStore.GetEpoch(), podStore.GetRevision()) ||
+		header.Revision != podStore.GetRevision() {
+		t.Fatalf("unexpected ETag %s and header %+v", headers.Get("ETag"), header)
+	}
+}
+
+// startDumpedPodServer starts a server on the dump of the test pod store
+func startDumpedPodServer(t *testing.T) (*httpserver.FzfHttpServer, *store.Store) {
+	tempDir, podStore := storetest.GetTestPodStore(t)
+	t.Cleanup(func() { util.RemoveTempDir(tempDir) })
+	if err := podStore.DumpFullState(); err != nil {
+		t.Fatalf("DumpFullState() error = %v", err)
+	}
+	storeConfig := store.NewStoreConfig(&store.StoreConfigCli{
+		ClusterConfigCli: &clusterconfig.ClusterConfigCli{ClusterName: "test", CacheDir: tempDir}})
+	h := &httpserver.HttpServerConfigCli{ListenAddress: "localhost:0"}
+	fzfHttpServer, err := httpserver.StartHttpServer(context.Background(), h, storeConfig, []*store.Store{podStore})
+	if err != nil {
+		t.Fatalf("StartHttpServer() error = %v", err)
+	}
+	return fzfHttpServer, podStore
+}
+
+func TestFetcherDeltaSync(t *testing.T) {
+	fzfHttpServer, podStore := startDumpedPodServer(t)
+	f, _ := fetchertest.GetTestFetcher(t, "nothing", fzfHttpServer.Port)
+
+	pods, err := f.GetResources(context.Background(), resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("GetResources() error = %v", err)
+	}
+	if len(pods) != 4 {
+		t.Fatalf("expected 4 pods, got %d", len(pods))
+	}
+
+	// New pods come from deltas, not from a new download
+	for _, name := range []string{"Test5", "Test6"} {
+		pod := storetest.PodResource(name, "ns1", nil)
+		podStore.AddResource(&pod)
+		pods, err = f.GetResources(context.Background(), resources.ResourceTypePod)
+		if err != nil {
+			t.Fatalf("GetResources() error = %v", err)
+		}
+		if _, ok := pods["ns1_"+name]; !ok {
+			t.Fatalf("expected ns1_%s from deltas, got %v", name, pods)
+		}
+	}
+	pods, err = f.GetResources(context.Background(), resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("GetResources() error = %v", err)
+	}
+	if len(pods) != 6 {
+		t.Fatalf("expected 6 pods, got %d", len(pods))
+	}
+	if fzfHttpServer.ResourceHits() != 1 {
+		t.Fatalf("expected a single full download, got %d", fzfHttpServer.ResourceHits())
+	}
+}
+
+func TestHttpServerConditionalGet(t *testing.T) {
+	fzfHttpServer, podStore := startDumpedPodServer(t)
+	url := fmt.Sprintf("http://localhost:%d/k8s/resources/pods", fzfHttpServer.Port)
+
+	headers, _, err := util.GetFromHttpServer(http.DefaultClient, url)
+	if err != nil {
+		t.Fatalf("GetFromHttpServer() error = %v", err)
+	}
+	etag := headers.Get("ETag")
+	if _, _, ok := httpserver.ParseResourceETag(etag); !ok {
+		t.Fatalf("expected a resource ETag, got %q", etag)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.Header.Set("If-None-Match", etag)
+	resp, body, err := util.DoHttpRequest(http.DefaultClient, req)
+	if err != nil {
+		t.Fatalf("DoHttpRequest() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusNotModified || len(body) != 0 {
+		t.Fatalf("expected empty 304, got %d with %d bytes", resp.StatusCode, len(body))
+	}
+	if fzfHttpServer.ResourceHits() != 1 {
+		t.Fatalf("expected 1 full download, got %d", fzfHttpServer.ResourceHits())
+	}
+
+	pod := storetest.PodResource("Test5", "ns1", nil)
+	podStore.AddResource(&pod)
+	req.Header.Set("A-IM", httpserver.DeltaInstanceManipulation)
+	resp, body, err = util.DoHttpRequest(http.DefaultClient, req)
+	if err != nil {
+		t.Fatalf("DoHttpRequest() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusIMUsed {
+		t.Fatalf("expected 226, got %d", resp.StatusCode)
+	}
+	deltas, _, err := store.DecodeDeltas(body, resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("DecodeDeltas() error = %v", err)
+	}
+	if len(deltas) != 1 || deltas[0].Key != "ns1_Test5" {
+		t.Fatalf("expected the addition of ns1_Test5, got %v", deltas)
+	}
+	if resp.Header.Get("ETag") != httpserver.ResourceETag(podStore.GetEpoch(), 5) {
+		t.Fatalf("unexpected ETag %s", resp.Header.Get("ETag"))
+	}
+}
+
+func TestHttpServerUnixSocket(t *testing.T) {
+	socketPath := path.Join(t.TempDir(), "run", "kubectl-fzf.sock")
+	listenAddress := "unix://" + socketPath
+	_, podStore := storetest.GetTestPodStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storeConfig := store.NewStoreConfig(GetTestStoreConfigCli())
+	h := &httpserver.HttpServerConfigCli{ListenAddress: listenAddress}
+	if _, err := httpserver.StartHttpServer(ctx, h, storeConfig, []*store.Store{podStore}); err != nil {
+		t.Fatalf("StartHttpServer() error = %v", err)
+	}
+	finfo, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if finfo.Mode().Perm() != 0o600 {
+		t.Fatalf("expected socket permissions 0600, got %o", finfo.Mode().Perm())
+	}
+	if finfo, err = os.Stat(path.Dir(socketPath)); err != nil || finfo.Mode().Perm() != 0o700 {
+		t.Fatalf("expected a 0700 socket directory, got %v, %v", finfo, err)
+	}
+
+	f := fetcher.NewFetcher(&fetcher.FetcherCli{
+		FetcherCachePath: t.TempDir(),
+		ClusterConfigCli: &clusterconfig.ClusterConfigCli{ClusterName: "nothing", CacheDir: "testdata"},
+		HttpEndpoint:     listenAddress,
+	})
+	stats, err := f.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 stat, got %d", len(stats))
+	}
+
+	// A second server can't take over the socket
+	if _, err := httpserver.StartHttpServer(ctx, h, storeConfig, []*store.Store{podStore}); err == nil {
+		t.Fatalf("expected error listening on a socket in use")
+	}
+
+	// Other users could replace a socket in a directory they can write to
+	sharedDir := path.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(sharedDir, 0o700); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	if err := os.Chmod(sharedDir, 0o777); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+	h = &httpserver.HttpServerConfigCli{ListenAddress: "unix://" + path.Join(sharedDir, "kubectl-fzf.sock")}
+	if _, err := httpserver.StartHttpServer(ctx, h, storeConfig, []*store.Store{podStore}); err == nil {
+		t.Fatalf("expected error listening in a shared directory")
+	}
+}
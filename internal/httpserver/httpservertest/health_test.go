@@ -0,0 +1,63 @@
+package httpservertest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+)
+
+func TestReadinessAndHealth(t *testing.T) {
+	fzfHttpServer, podStore := StartTestHttpServerWithStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storeConfigCli := GetTestStoreConfigCli()
+	storeConfigCli.TimeBetweenFullDump = time.Minute
+	deploymentStore := store.NewStore(ctx, store.NewStoreConfig(storeConfigCli),
+		resources.CtorConfig{}, resources.ResourceTypeDeployment)
+	if err := fzfHttpServer.SetStores([]*store.Store{podStore, deploymentStore}); err != nil {
+		t.Fatalf("SetStores() error = %v", err)
+	}
+	readiness := func() int {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/readiness", fzfHttpServer.Port))
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	f := fetcher.NewFetcher(&fetcher.FetcherCli{
+		FetcherCachePath: t.TempDir(),
+		ClusterConfigCli: &clusterconfig.ClusterConfigCli{ClusterName: "nothing", CacheDir: "testdata"},
+		HttpEndpoint:     fmt.Sprintf("localhost:%d", fzfHttpServer.Port),
+	})
+
+	if status := readiness(); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while deployments are listing, got %d", status)
+	}
+	report, err := f.GetHealth(ctx)
+	if err != nil {
+		t.Fatalf("GetHealth() error = %v", err)
+	}
+	if report.Ready || len(report.Resources) != 2 || report.Resources[1].State != store.SyncStateListing {
+		t.Fatalf("unexpected health report %+v", report)
+	}
+
+	deploymentStore.SetSyncState(store.SyncStateWatching)
+	if status := readiness(); status != http.StatusOK {
+		t.Fatalf("expected 200 once synced, got %d", status)
+	}
+	report, err = f.GetHealth(ctx)
+	if err != nil {
+		t.Fatalf("GetHealth() error = %v", err)
+	}
+	if !report.Ready || report.Resources[1].ResourceType != resources.ResourceTypeDeployment {
+		t.Fatalf("unexpected health report %+v", report)
+	}
+}
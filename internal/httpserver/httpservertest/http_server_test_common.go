@@ -18,7 +18,20 @@ func GetTestStoreConfigCli() *store.StoreConfigCli {
 	return &store.StoreConfigCli{ClusterConfigCli: GetTestClusterConfigCli()}
 }
 
+// StartTestHttpServer starts a test server serving the resource files of
+// testdata, without store
 func StartTestHttpServer(t *testing.T) *httpserver.FzfHttpServer {
+	storeConfig := store.NewStoreConfig(GetTestStoreConfigCli())
+	h := &httpserver.HttpServerConfigCli{ListenAddress: "localhost:0", Debug: false}
+	fzfHttpServer, err := httpserver.StartHttpServer(context.Background(), h, storeConfig, nil)
+	if err != nil {
+		t.Fatalf("StartHttpServer() error = %v", err)
+	}
+	return fzfHttpServer
+}
+
+// StartTestHttpServerWithStore starts a test server backed by the test pod store
+func StartTestHttpServerWithStore(t *testing.T) (*httpserver.FzfHttpServer, *store.Store) {
 	ctx := context.Background()
 	storeConfigCli := GetTestStoreConfigCli()
 	storeConfig := store.NewStoreConfig(storeConfigCli)
@@ -28,5 +41,5 @@ func StartTestHttpServer(t *testing.T) *httpserver.FzfHttpServer {
 	if err != nil {
 		t.Fatalf("StartHttpServer() error = %v", err)
 	}
-	return fzfHttpServer
+	return fzfHttpServer, podStore
 }
@@ -0,0 +1,42 @@
+package httpservertest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	fzfHttpServer, _ := StartTestHttpServerWithStore(t)
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/stats", fzfHttpServer.Port))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/metrics", fzfHttpServer.Port))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected metrics response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	for _, expected := range []string{
+		`kubectl_fzf_store_items{namespace="ns2",resource="pods"} 2`,
+		`kubectl_fzf_store_revision{resource="pods"} 4`,
+		`kubectl_fzf_http_requests_total{code="200",method="GET",route="/stats"}`,
+		`kubectl_fzf_http_request_duration_seconds_bucket{route="/stats",le="+Inf"}`,
+		"# TYPE go_goroutines gauge",
+	} {
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected %q in metrics:\n%s", expected, b)
+		}
+	}
+}
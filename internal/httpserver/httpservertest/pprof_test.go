@@ -0,0 +1,62 @@
+package httpservertest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/httpserver"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store/storetest"
+)
+
+func TestPprof(t *testing.T) {
+	get := func(url string, token string) int {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error = %v", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Disabled by default
+	fzfHttpServer := StartTestHttpServer(t)
+	if status := get(fmt.Sprintf("http://localhost:%d/debug/pprof/", fzfHttpServer.Port), ""); status != http.StatusNotFound {
+		t.Fatalf("expected 404 without --http-pprof, got %d", status)
+	}
+
+	tokenFile := path.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	_, podStore := storetest.GetTestPodStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := &httpserver.HttpServerConfigCli{
+		ListenAddress: "localhost:0",
+		AuthTokenFile: tokenFile,
+		Pprof:         true,
+	}
+	fzfHttpServer, err := httpserver.StartHttpServer(ctx, h, store.NewStoreConfig(GetTestStoreConfigCli()), []*store.Store{podStore})
+	if err != nil {
+		t.Fatalf("StartHttpServer() error = %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/debug/pprof/goroutine?debug=1", fzfHttpServer.Port)
+	if status := get(url, ""); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", status)
+	}
+	if status := get(url, "s3cret"); status != http.StatusOK {
+		t.Fatalf("expected 200 with token, got %d", status)
+	}
+}
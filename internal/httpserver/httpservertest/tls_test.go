@@ -0,0 +1,133 @@
+package httpservertest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
+	"github.com/codeactual/kubectl-fzf/v4/internal/httpserver"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store/storetest"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+)
+
+// writeSelfSignedCert writes a certificate for localhost and its key in dir
+func writeSelfSignedCert(t *testing.T, dir string) (certFile string, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+	certFile = path.Join(dir, "server.crt")
+	keyFile = path.Join(dir, "server.key")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := os.WriteFile(certFile, certPem, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.WriteFile(keyFile, keyPem, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestHttpServerTLSAndToken(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSignedCert(t, dir)
+	tokenFile := path.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	_, podStore := storetest.GetTestPodStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storeConfig := store.NewStoreConfig(GetTestStoreConfigCli())
+	h := &httpserver.HttpServerConfigCli{
+		ListenAddress: "localhost:0",
+		TLSCertFile:   certFile,
+		TLSKeyFile:    keyFile,
+		AuthTokenFile: tokenFile,
+	}
+	fzfHttpServer, err := httpserver.StartHttpServer(ctx, h, storeConfig, []*store.Store{podStore})
+	if err != nil {
+		t.Fatalf("StartHttpServer() error = %v", err)
+	}
+	endpoint := fmt.Sprintf("https://localhost:%d", fzfHttpServer.Port)
+
+	newFetcher := func(clientConfig util.HttpClientConfig) *fetcher.Fetcher {
+		return fetcher.NewFetcher(&fetcher.FetcherCli{
+			FetcherCachePath: t.TempDir(),
+			ClusterConfigCli: &clusterconfig.ClusterConfigCli{ClusterName: "nothing", CacheDir: "testdata"},
+			HttpEndpoint:     endpoint,
+			HttpClientConfig: clientConfig,
+		})
+	}
+
+	stats, err := newFetcher(util.HttpClientConfig{CAFile: certFile, TokenFile: tokenFile}).GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 stat, got %d", len(stats))
+	}
+
+	_, err = newFetcher(util.HttpClientConfig{CAFile: certFile}).GetStats(ctx)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected 401 without token, got %v", err)
+	}
+
+	_, err = newFetcher(util.HttpClientConfig{TokenFile: tokenFile}).GetStats(ctx)
+	if err == nil {
+		t.Fatalf("expected certificate verification error without CA")
+	}
+
+	// Probes don't need the token
+	client, err := util.HttpEndpoint{Network: "tcp", Address: fmt.Sprintf("localhost:%d", fzfHttpServer.Port), TLS: true}.
+		Client(util.HttpClientConfig{CAFile: certFile})
+	if err != nil {
+		t.Fatalf("Client() error = %v", err)
+	}
+	resp, err := client.Get(fmt.Sprintf("%s/readiness", endpoint))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected readiness without token, got %d", resp.StatusCode)
+	}
+}
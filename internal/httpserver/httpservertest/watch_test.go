@@ -0,0 +1,117 @@
+package httpservertest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/httpserver"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store/storetest"
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// openWatch starts a watch and returns a reader of its events
+func openWatch(t *testing.T, ctx context.Context, url string, lastEventID string) func() sseEvent {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected watch response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	return func() sseEvent {
+		t.Helper()
+		event := sseEvent{}
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "" && event.event != "":
+				return event
+			case strings.HasPrefix(line, "id: "):
+				event.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		t.Fatalf("watch stream ended: %v", scanner.Err())
+		return event
+	}
+}
+
+func TestWatch(t *testing.T) {
+	fzfHttpServer, podStore := StartTestHttpServerWithStore(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	url := fmt.Sprintf("http://localhost:%d/api/v1/watch?types=pods&namespace=ns2", fzfHttpServer.Port)
+
+	// Resume from revision 1: the ns2 pods are replayed, Test4 in aaa is filtered
+	since := fmt.Sprintf("pods:%d.1", podStore.GetEpoch())
+	next := openWatch(t, ctx, url+"&since="+since, "")
+	for _, name := range []string{"Test2", "Test3"} {
+		event := next()
+		watchEvent := httpserver.WatchEvent{}
+		if err := json.Unmarshal([]byte(event.data), &watchEvent); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if event.event != "add" || watchEvent.Name != name || watchEvent.ResourceType != "pods" || len(watchEvent.Rows) != 1 {
+			t.Fatalf("expected add of %s, got %+v", name, event)
+		}
+	}
+
+	pod := storetest.PodResource("Test5", "ns2", nil)
+	podStore.AddResource(&pod)
+	podStore.DeleteResource(&pod)
+	if event := next(); event.event != "add" {
+		t.Fatalf("expected add, got %+v", event)
+	}
+	event := next()
+	if event.event != "delete" || !strings.Contains(event.data, `"name":"Test5"`) {
+		t.Fatalf("expected delete of Test5, got %+v", event)
+	}
+	expectedID := fmt.Sprintf("pods:%d.6", podStore.GetEpoch())
+	if event.id != expectedID {
+		t.Fatalf("expected id %s, got %s", expectedID, event.id)
+	}
+
+	// Resuming from an unknown epoch resets the client
+	next = openWatch(t, ctx, url, fmt.Sprintf("pods:%d.6", podStore.GetEpoch()+1))
+	event = next()
+	reset := httpserver.WatchReset{}
+	if err := json.Unmarshal([]byte(event.data), &reset); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if event.event != "reset" || reset.Epoch != podStore.GetEpoch() || reset.Revision != 6 || event.id != expectedID {
+		t.Fatalf("expected reset at revision 6, got %+v", event)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/api/v1/watch?types=nope", fzfHttpServer.Port))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 on unknown type, got %d", resp.StatusCode)
+	}
+}
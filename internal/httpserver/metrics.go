@@ -0,0 +1,90 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+)
+
+var (
+	httpRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubectl_fzf_http_requests_total",
+		Help: "HTTP requests by route, method and status code",
+	}, []string{"route", "method", "code"})
+	httpDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kubectl_fzf_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by route, watch streams excluded",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route"})
+	resourceResponsesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubectl_fzf_resource_responses_total",
+		Help: "Resource requests by result: full, not_modified or delta when the client cache was reused",
+	}, []string{"resource", "result"})
+)
+
+// routeLabel returns the route of path for metric labels, unknown paths are
+// grouped to bound the number of series
+func routeLabel(routes map[string]struct{}, path string) string {
+	if _, ok := routes[path]; ok {
+		return path
+	}
+	return "other"
+}
+
+func observeRequest(routes map[string]struct{}, r *http.Request, status int, seconds float64) {
+	route := routeLabel(routes, r.URL.Path)
+	httpRequestsCounter.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+	if route != "/api/v1/watch" {
+		httpDurationHistogram.WithLabelValues(route).Observe(seconds)
+	}
+}
+
+// metricsRoute serves the metrics in Prometheus text format
+// Store items are computed on each scrape and only include what the user is
+// allowed to list.
+func (f *FzfHttpServer) metricsRoute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	stats, err := f.filterStats(r, store.GetStatsFromStores(f.getStores()))
+	if err != nil {
+		log.Errorf("Error reviewing access to metrics: %s", err)
+		http.Error(w, "error reviewing access", http.StatusServiceUnavailable)
+		return
+	}
+	items := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubectl_fzf_store_items",
+		Help: "Resources in the store by namespace",
+	}, []string{"resource", "namespace"})
+	revisions := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubectl_fzf_store_revision",
+		Help: "Revision of the store, increased on each change",
+	}, []string{"resource"})
+	for _, s := range stats {
+		for namespace, count := range s.ItemPerNamespace {
+			items.WithLabelValues(s.ResourceType.String(), namespace).Set(float64(count))
+		}
+		if st := f.getStore(s.ResourceType); st != nil {
+			revisions.WithLabelValues(s.ResourceType.String()).Set(float64(st.GetRevision()))
+		}
+	}
+	scrapeRegistry := prometheus.NewRegistry()
+	scrapeRegistry.MustRegister(items, revisions)
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, scrapeRegistry}
+	promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{ErrorLog: promhttpLogger{}}).ServeHTTP(w, r)
+}
+
+// promhttpLogger logs the errors of promhttp
+type promhttpLogger struct{}
+
+func (promhttpLogger) Println(v ...interface{}) {
+	log.Errorf("unable to write metrics: %s", fmt.Sprint(v...))
+}
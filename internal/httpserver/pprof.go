@@ -0,0 +1,92 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	authzv1 "k8s.io/api/authorization/v1"
+)
+
+// pprofAttributes is the permission required to read profiles with
+// Kubernetes authorization
+var pprofAttributes = authzv1.NonResourceAttributes{Verb: "get", Path: "/debug/pprof"}
+
+// registerPprof mounts the pprof handlers under /debug/pprof/, wrapped by
+// authorize
+// The handlers are mounted explicitly: the net/http/pprof import registers
+// them on http.DefaultServeMux, which is never served.
+func registerPprof(mux *http.ServeMux, authorize func(http.HandlerFunc) http.HandlerFunc) {
+	mux.HandleFunc("/debug/pprof/", authorize(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", authorize(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", authorize(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", authorize(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", authorize(pprof.Trace))
+}
+
+func noAuthorization(next http.HandlerFunc) http.HandlerFunc {
+	return next
+}
+
+// authorizePprof requires users reviewed by the cluster to be allowed to get
+// /debug/pprof: profiles expose the memory of the server, its cache included
+func (f *FzfHttpServer) authorizePprof(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reviewer := f.getReviewer()
+		if reviewer == nil {
+			next(w, r)
+			return
+		}
+		user := userFromContext(r.Context())
+		if user == nil {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		allowed, err := reviewer.AllowedNonResource(r.Context(), user, pprofAttributes)
+		if err != nil {
+			log.Errorf("Error reviewing access to pprof: %s", err)
+			http.Error(w, "error reviewing access", http.StatusServiceUnavailable)
+			return
+		}
+		if !allowed {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// StartPprofServer serves pprof on its own listener until ctx is done
+// It's disabled when address is empty. The listener has no authentication,
+// use --http-pprof to serve pprof behind the authentication of the main
+// listener instead.
+func StartPprofServer(ctx context.Context, address string) error {
+	if address == "" {
+		return nil
+	}
+	endpoint, err := util.ParseHttpEndpoint(address)
+	if err != nil {
+		return err
+	}
+	if endpoint.TLS {
+		log.Warnf("Ignoring https scheme of %s, pprof is served over http", address)
+	}
+	if !endpoint.IsLoopback() {
+		log.Warnf("pprof is reachable from the network on %s without authentication", address)
+	}
+	listener, err := listen(endpoint)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	registerPprof(mux, noAuthorization)
+	srv := &http.Server{
+		Addr:    address,
+		Handler: mux,
+	}
+	go startHttpServer(ctx, listener, srv)
+	return nil
+}
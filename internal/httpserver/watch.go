@@ -0,0 +1,362 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+)
+
+const (
+	// watchBufferSize is the number of changes buffered per watched store
+	// before a slow client needs to catch up from the retained deltas
+	watchBufferSize = 256
+	// watchHeartbeatInterval keeps idle streams open through proxies
+	watchHeartbeatInterval = 15 * time.Second
+)
+
+// WatchEvent is the data of the add, update and delete events of
+// /api/v1/watch
+// Rows and Resource are empty for deletions.
+type WatchEvent struct {
+	ResourceType string `json:"resourceType"`
+	Revision     uint64 `json:"revision"`
+	ApiListItem
+}
+
+// WatchReset is the data of the reset event of /api/v1/watch, sent when the
+// changes since the requested revision are not available anymore
+// Clients need to list ResourceType again: the following events are the
+// changes after Revision of Epoch.
+type WatchReset struct {
+	ResourceType string `json:"resourceType"`
+	Epoch        int64  `json:"epoch"`
+	Revision     uint64 `json:"revision"`
+}
+
+// WatchError is the data of the error event of /api/v1/watch, sent before
+// closing the stream when the user can't list ResourceType anymore
+type WatchError struct {
+	ResourceType string `json:"resourceType"`
+	Message      string `json:"message"`
+}
+
+type watchPosition struct {
+	epoch    int64
+	revision uint64
+}
+
+// watchCursor is the position of a stream in each watched store
+// Its text form, pods:<epoch>.<revision>,deployments:<epoch>.<revision>, is
+// the id of events: EventSource clients send it back in Last-Event-ID when
+// reconnecting.
+type watchCursor map[resources.ResourceType]watchPosition
+
+func parseWatchCursor(s string) (watchCursor, error) {
+	cursor := watchCursor{}
+	if s == "" {
+		return cursor, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		typeStr, positionStr, found := strings.Cut(part, ":")
+		epochStr, revisionStr, foundRevision := strings.Cut(positionStr, ".")
+		if !found || !foundRevision {
+			return nil, fmt.Errorf("invalid cursor %q", part)
+		}
+		resourceType := resources.ParseResourceType(typeStr)
+		if resourceType == resources.ResourceTypeUnknown {
+			return nil, fmt.Errorf("unknown resource type %q in cursor", typeStr)
+		}
+		epoch, err := strconv.ParseInt(epochStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid epoch in cursor %q", part)
+		}
+		revision, err := strconv.ParseUint(revisionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid revision in cursor %q", part)
+		}
+		cursor[resourceType] = watchPosition{epoch: epoch, revision: revision}
+	}
+	return cursor, nil
+}
+
+func (c watchCursor) String() string {
+	parts := make([]string, 0, len(c))
+	for resourceType, position := range c {
+		parts = append(parts, fmt.Sprintf("%s:%d.%d", resourceType, position.epoch, position.revision))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func parseWatchTypes(s string, stores []*store.Store) ([]resources.ResourceType, error) {
+	res := []resources.ResourceType{}
+	if s == "" {
+		for _, st := range stores {
+			res = append(res, st.GetResourceType())
+		}
+		return res, nil
+	}
+	seen := map[resources.ResourceType]bool{}
+	for _, typeStr := range strings.Split(s, ",") {
+		resourceType := resources.ParseResourceType(strings.TrimSpace(typeStr))
+		if resourceType == resources.ResourceTypeUnknown {
+			return nil, fmt.Errorf("unknown resource type %q", typeStr)
+		}
+		if !seen[resourceType] {
+			seen[resourceType] = true
+			res = append(res, resourceType)
+		}
+	}
+	return res, nil
+}
+
+// watchedStore is the state of a stream for one of its stores
+type watchedStore struct {
+	resourceType resources.ResourceType
+	store        *store.Store
+	filter       namespaceFilter
+	sub          *store.Subscription
+}
+
+// watchStream writes server-sent events
+type watchStream struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	namespace  string
+	cursor     watchCursor
+}
+
+func (s *watchStream) writeEvent(event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "id: %s\nevent: %s\ndata: %s\n\n", s.cursor, event, b)
+	return err
+}
+
+func (s *watchStream) flush() error {
+	return s.controller.Flush()
+}
+
+// writeChange writes record unless it's filtered out, the cursor moves
+// forward either way
+func (s *watchStream) writeChange(ws *watchedStore, record store.DeltaRecord) error {
+	s.cursor[ws.resourceType] = watchPosition{epoch: ws.store.GetEpoch(), revision: record.Revision}
+	namespace := store.KeyNamespace(record.Key)
+	if !ws.filter.allows(namespace) || (s.namespace != "" && s.namespace != namespace) {
+		return nil
+	}
+	event := WatchEvent{
+		ResourceType: ws.resourceType.String(),
+		Revision:     record.Revision,
+		ApiListItem:  ApiListItem{Namespace: namespace, Name: store.KeyName(record.Key)},
+	}
+	if record.Resource != nil {
+		event.Rows = resourceRows(record.Resource)
+		event.Resource = record.Resource
+	}
+	return s.writeEvent(record.Op.String(), event)
+}
+
+// subscribe starts the subscription of ws from the cursor position
+// When the stream can't resume, a reset event is sent and the subscription
+// starts from the current revision.
+func (s *watchStream) subscribe(ws *watchedStore) error {
+	position, resuming := s.cursor[ws.resourceType]
+	if resuming {
+		sub, backlog, ok := ws.store.Subscribe(position.epoch, position.revision, watchBufferSize)
+		if ok {
+			ws.sub = sub
+			for _, record := range backlog {
+				if err := s.writeChange(ws, record); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	sub, revision := ws.store.SubscribeCurrent(watchBufferSize)
+	ws.sub = sub
+	s.cursor[ws.resourceType] = watchPosition{epoch: ws.store.GetEpoch(), revision: revision}
+	if !resuming {
+		return nil
+	}
+	return s.writeEvent("reset", WatchReset{
+		ResourceType: ws.resourceType.String(),
+		Epoch:        ws.store.GetEpoch(),
+		Revision:     revision,
+	})
+}
+
+// reviewAccess updates the namespace filters of watched with the current
+// access of the user of r
+// When the user can't list one of the types anymore, including in all the
+// namespaces that were allowed, or the access can't be reviewed, an error
+// event is sent and false is returned to close the stream.
+func (f *FzfHttpServer) reviewAccess(r *http.Request, stream *watchStream, watched []*watchedStore) bool {
+	for _, ws := range watched {
+		filter, ok, err := f.authorizeList(r, ws.resourceType)
+		revoked := filter != nil && len(filter) == 0 && (ws.filter == nil || len(ws.filter) > 0)
+		if err == nil && ok && !revoked {
+			ws.filter = filter
+			continue
+		}
+		message := "access revoked"
+		if err != nil {
+			log.Errorf("Error reviewing access to %s: %s", ws.resourceType, err)
+			message = "error reviewing access"
+		}
+		if err := stream.writeEvent("error", WatchError{ResourceType: ws.resourceType.String(), Message: message}); err == nil {
+			_ = stream.flush()
+		}
+		return false
+	}
+	return true
+}
+
+// watchRoute streams the changes of stores as server-sent events
+// The stream resumes from the since parameter or the Last-Event-ID header.
+// Without them, only the changes after the request are sent: clients list
+// the resources with /api/v1/<type> and watch from the epoch and revision of
+// the response.
+// Each client has its own buffer: a slow client never blocks the stores, it
+// catches up from the retained deltas or gets a reset event.
+// With Kubernetes authorization, the access is reviewed again each time the
+// cached reviews expire, see reviewAccess.
+func (f *FzfHttpServer) watchRoute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	types, err := parseWatchTypes(query.Get("types"), f.getStores())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	since := r.Header.Get("Last-Event-ID")
+	if since == "" {
+		since = query.Get("since")
+	}
+	cursor, err := parseWatchCursor(since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	watched := make([]*watchedStore, 0, len(types))
+	for _, resourceType := range types {
+		filter, ok := f.checkListAccess(w, r, resourceType)
+		if !ok {
+			return
+		}
+		s := f.getStore(resourceType)
+		if s == nil {
+			http.Error(w, fmt.Sprintf("no store for %s", resourceType), http.StatusNotFound)
+			return
+		}
+		watched = append(watched, &watchedStore{resourceType: resourceType, store: s, filter: filter})
+	}
+
+	stream := &watchStream{
+		w:          w,
+		controller: http.NewResponseController(w),
+		namespace:  query.Get("namespace"),
+		cursor:     watchCursor{},
+	}
+	for _, ws := range watched {
+		if position, ok := cursor[ws.resourceType]; ok {
+			stream.cursor[ws.resourceType] = position
+		}
+	}
+	defer func() {
+		for _, ws := range watched {
+			if ws.sub != nil {
+				ws.sub.Close()
+			}
+		}
+	}()
+
+	if f.getReviewer() != nil {
+		w.Header().Set("Vary", "Authorization")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, ws := range watched {
+		if err := stream.subscribe(ws); err != nil {
+			return
+		}
+	}
+	if err := stream.flush(); err != nil {
+		log.Debugf("Error flushing watch stream: %s", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+	// Without reviewer, the access never changes
+	var review <-chan time.Time
+	if reviewer := f.getReviewer(); reviewer != nil {
+		interval := reviewer.TTL()
+		if interval <= 0 {
+			interval = watchHeartbeatInterval
+		}
+		reviewTicker := time.NewTicker(interval)
+		defer reviewTicker.Stop()
+		review = reviewTicker.C
+	}
+	// The first cases are the subscriptions, in the order of watched
+	cases := make([]reflect.SelectCase, len(watched), len(watched)+3)
+	for {
+		for i, ws := range watched {
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ws.sub.C)}
+		}
+		cases = append(cases[:len(watched)],
+			reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(r.Context().Done())},
+			reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(heartbeat.C)},
+			reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(review)})
+		chosen, value, recvOK := reflect.Select(cases)
+		switch {
+		case chosen == len(watched):
+			return
+		case chosen == len(watched)+1:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case chosen == len(watched)+2:
+			if !f.reviewAccess(r, stream, watched) {
+				return
+			}
+		case recvOK:
+			if err := stream.writeChange(watched[chosen], value.Interface().(store.DeltaRecord)); err != nil {
+				return
+			}
+		case watched[chosen].sub.Overflowed():
+			ws := watched[chosen]
+			log.Debugf("Watch of %s fell behind, resuming from %s", ws.resourceType, stream.cursor)
+			if err := stream.subscribe(ws); err != nil {
+				return
+			}
+		default:
+			// The store was stopped by a context switch, the client reconnects
+			// and gets a reset from the new store
+			log.Debugf("Store of %s stopped, closing watch", watched[chosen].resourceType)
+			return
+		}
+		if err := stream.flush(); err != nil {
+			return
+		}
+	}
+}
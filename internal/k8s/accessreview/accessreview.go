@@ -0,0 +1,183 @@
+// Package accessreview authenticates bearer tokens with a TokenReview and
+// authorizes the resulting users with SubjectAccessReviews, the same way the
+// apiserver would for kubectl.
+// A shared kubectl-fzf-server uses it to never hand out more than what the
+// requesting user could list. Results are cached for a short TTL: completion
+// sends several requests per TAB and each review is an apiserver round-trip.
+package accessreview
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// maxCacheEntries is the cache size after which expired entries are purged
+const maxCacheEntries = 4096
+
+// ErrUnauthenticated is returned when the apiserver doesn't accept a token
+var ErrUnauthenticated = errors.New("token not authenticated")
+
+// User is an identity returned by a TokenReview
+type User struct {
+	Username string
+	UID      string
+	Groups   []string
+	Extra    map[string][]string
+}
+
+type tokenEntry struct {
+	user    *User
+	err     error
+	expires time.Time
+}
+
+type accessEntry struct {
+	allowed bool
+	expires time.Time
+}
+
+// Reviewer sends and caches TokenReviews and SubjectAccessReviews
+type Reviewer struct {
+	client kubernetes.Interface
+	ttl    time.Duration
+	now    func() time.Time
+
+	mutex  sync.Mutex
+	tokens map[[sha256.Size]byte]tokenEntry
+	access map[string]accessEntry
+}
+
+// NewReviewer creates a reviewer caching results for ttl
+func NewReviewer(client kubernetes.Interface, ttl time.Duration) *Reviewer {
+	return &Reviewer{
+		client: client,
+		ttl:    ttl,
+		now:    time.Now,
+		tokens: map[[sha256.Size]byte]tokenEntry{},
+		access: map[string]accessEntry{},
+	}
+}
+
+// TTL returns the duration for which reviews are cached
+func (r *Reviewer) TTL() time.Duration {
+	return r.ttl
+}
+
+// Authenticate returns the user owning token
+// Rejected tokens are also cached to not forward every bad request to the
+// apiserver.
+func (r *Reviewer) Authenticate(ctx context.Context, token string) (*User, error) {
+	// Only keep a hash of the tokens in memory
+	key := sha256.Sum256([]byte(token))
+	r.mutex.Lock()
+	entry, ok := r.tokens[key]
+	r.mutex.Unlock()
+	if ok && r.now().Before(entry.expires) {
+		return entry.user, entry.err
+	}
+
+	tokenReview := &authnv1.TokenReview{Spec: authnv1.TokenReviewSpec{Token: token}}
+	out, err := r.client.AuthenticationV1().TokenReviews().Create(ctx, tokenReview, metav1.CreateOptions{})
+	if err != nil {
+		// Don't cache apiserver errors
+		return nil, fmt.Errorf("create TokenReview: %w", err)
+	}
+	entry = tokenEntry{expires: r.now().Add(r.ttl)}
+	if out.Status.Authenticated {
+		entry.user = &User{
+			Username: out.Status.User.Username,
+			UID:      out.Status.User.UID,
+			Groups:   out.Status.User.Groups,
+			Extra:    map[string][]string{},
+		}
+		for k, v := range out.Status.User.Extra {
+			entry.user.Extra[k] = v
+		}
+	} else {
+		entry.err = ErrUnauthenticated
+	}
+	r.mutex.Lock()
+	r.purgeExpiredLocked()
+	r.tokens[key] = entry
+	r.mutex.Unlock()
+	return entry.user, entry.err
+}
+
+// purgeExpiredLocked drops expired entries once the caches grow
+// The caller needs to hold mutex
+func (r *Reviewer) purgeExpiredLocked() {
+	if len(r.tokens)+len(r.access) < maxCacheEntries {
+		return
+	}
+	now := r.now()
+	for k, entry := range r.tokens {
+		if !now.Before(entry.expires) {
+			delete(r.tokens, k)
+		}
+	}
+	for k, entry := range r.access {
+		if !now.Before(entry.expires) {
+			delete(r.access, k)
+		}
+	}
+}
+
+func accessKey(user *User, attr ...string) string {
+	groups := append([]string(nil), user.Groups...)
+	sort.Strings(groups)
+	return strings.Join(append([]string{user.Username, user.UID, strings.Join(groups, ",")}, attr...), "|")
+}
+
+// Allowed returns true if user is allowed to perform attr
+func (r *Reviewer) Allowed(ctx context.Context, user *User, attr authzv1.ResourceAttributes) (bool, error) {
+	key := accessKey(user, attr.Verb, attr.Group, attr.Resource, attr.Namespace)
+	return r.review(ctx, user, key, authzv1.SubjectAccessReviewSpec{ResourceAttributes: &attr})
+}
+
+// AllowedNonResource returns true if user is allowed to perform attr on a
+// non resource URL, e.g. get /debug/pprof
+func (r *Reviewer) AllowedNonResource(ctx context.Context, user *User, attr authzv1.NonResourceAttributes) (bool, error) {
+	key := accessKey(user, attr.Verb, "nonResourceURL", attr.Path)
+	return r.review(ctx, user, key, authzv1.SubjectAccessReviewSpec{NonResourceAttributes: &attr})
+}
+
+// review sends the SubjectAccessReview of spec for user, unless its result
+// is cached under key
+func (r *Reviewer) review(ctx context.Context, user *User, key string, spec authzv1.SubjectAccessReviewSpec) (bool, error) {
+	r.mutex.Lock()
+	entry, ok := r.access[key]
+	r.mutex.Unlock()
+	if ok && r.now().Before(entry.expires) {
+		return entry.allowed, nil
+	}
+
+	extra := map[string]authzv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = v
+	}
+	spec.User = user.Username
+	spec.UID = user.UID
+	spec.Groups = user.Groups
+	spec.Extra = extra
+	sar := &authzv1.SubjectAccessReview{Spec: spec}
+	out, err := r.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, sar, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("create SubjectAccessReview: %w", err)
+	}
+	r.mutex.Lock()
+	r.purgeExpiredLocked()
+	r.access[key] = accessEntry{allowed: out.Status.Allowed, expires: r.now().Add(r.ttl)}
+	r.mutex.Unlock()
+	return out.Status.Allowed, nil
+}
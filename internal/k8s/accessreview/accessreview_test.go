@@ -0,0 +1,117 @@
+package accessreview
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// tokenReviewReactor authenticates "good-token" as alice
+func tokenReviewReactor(calls *int) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*calls++
+		review := action.(k8stesting.CreateAction).GetObject().(*authnv1.TokenReview)
+		out := &authnv1.TokenReview{}
+		if review.Spec.Token == "good-token" {
+			out.Status = authnv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authnv1.UserInfo{Username: "alice", Groups: []string{"dev"}},
+			}
+		}
+		return true, out, nil
+	}
+}
+
+// sarReactor only allows listing pods in the dev namespace
+func sarReactor(calls *int) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*calls++
+		review := action.(k8stesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+		attr := review.Spec.ResourceAttributes
+		allowed := review.Spec.User == "alice" && attr.Verb == "list" &&
+			attr.Resource == "pods" && attr.Namespace == "dev"
+		return true, &authzv1.SubjectAccessReview{
+			Status: authzv1.SubjectAccessReviewStatus{Allowed: allowed},
+		}, nil
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	cs := corefake.NewClientset()
+	calls := 0
+	cs.PrependReactor("create", "tokenreviews", tokenReviewReactor(&calls))
+	r := NewReviewer(cs, time.Minute)
+
+	user, err := r.Authenticate(context.Background(), "good-token")
+	if err != nil {
+		t.Fatalf("Authenticate(good-token): %v", err)
+	}
+	if user.Username != "alice" {
+		t.Fatalf("Authenticate(good-token) user = %q, want alice", user.Username)
+	}
+	if _, err := r.Authenticate(context.Background(), "good-token"); err != nil {
+		t.Fatalf("cached Authenticate(good-token): %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("TokenReview created %d times, want 1 with cache", calls)
+	}
+
+	_, err = r.Authenticate(context.Background(), "bad-token")
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("Authenticate(bad-token) error = %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestAllowedCacheExpires(t *testing.T) {
+	cs := corefake.NewClientset()
+	calls := 0
+	cs.PrependReactor("create", "subjectaccessreviews", sarReactor(&calls))
+	r := NewReviewer(cs, time.Minute)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	user := &User{Username: "alice", Groups: []string{"dev"}}
+
+	cases := []struct {
+		namespace string
+		allowed   bool
+	}{
+		{"dev", true},
+		{"prod", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		attr := authzv1.ResourceAttributes{Verb: "list", Resource: "pods", Namespace: c.namespace}
+		allowed, err := r.Allowed(context.Background(), user, attr)
+		if err != nil {
+			t.Fatalf("Allowed(%q): %v", c.namespace, err)
+		}
+		if allowed != c.allowed {
+			t.Fatalf("Allowed(%q) = %v, want %v", c.namespace, allowed, c.allowed)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("SubjectAccessReview created %d times, want 3", calls)
+	}
+
+	attr := authzv1.ResourceAttributes{Verb: "list", Resource: "pods", Namespace: "dev"}
+	if _, err := r.Allowed(context.Background(), user, attr); err != nil {
+		t.Fatalf("cached Allowed: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("SubjectAccessReview created %d times, want 3 with cache", calls)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := r.Allowed(context.Background(), user, attr); err != nil {
+		t.Fatalf("Allowed after expiry: %v", err)
+	}
+	if calls != 4 {
+		t.Fatalf("SubjectAccessReview created %d times, want 4 after expiry", calls)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
@@ -15,6 +16,15 @@ import (
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
+const (
+	// HeartbeatInterval is the period at which a running server touches its
+	// heartbeat file
+	HeartbeatInterval = 5 * time.Second
+	// HeartbeatTimeout is the heartbeat age after which the server writing
+	// the files is considered stopped
+	HeartbeatTimeout = 3 * HeartbeatInterval
+)
+
 type ClusterConfig struct {
 	clusterName string
 	destDir     string
@@ -60,6 +70,34 @@ func (c *ClusterConfig) GetResourceStorePath(r resources.ResourceType) string {
 	return path.Join(c.destDir, r.String())
 }
 
+// GetResourceLogPath returns the path of the delta log written next to the
+// resource store file
+func (c *ClusterConfig) GetResourceLogPath(r resources.ResourceType) string {
+	return c.GetResourceStorePath(r) + ".log"
+}
+
+// GetHeartbeatPath returns the path of the file touched by a running server
+func (c *ClusterConfig) GetHeartbeatPath() string {
+	return path.Join(c.destDir, ".heartbeat")
+}
+
+// GetServerLockPath returns the path of the lock held by the running server,
+// a single server runs per cache root
+func (c *ClusterConfig) GetServerLockPath() string {
+	return path.Join(c.cacheDir, "kubectl-fzf-server.lock")
+}
+
+// GetServerPidPath returns the path of the pidfile of the running server
+func (c *ClusterConfig) GetServerPidPath() string {
+	return path.Join(c.cacheDir, "kubectl-fzf-server.pid")
+}
+
+// GetLastUsedPath returns the path of the file touched by completions reading
+// the files of the server, the server exits when it's idle for too long
+func (c *ClusterConfig) GetLastUsedPath() string {
+	return path.Join(c.cacheDir, ".last-used")
+}
+
 func (c *ClusterConfig) FileStoreExists(r resources.ResourceType) bool {
 	p := c.GetResourceStorePath(r)
 	return util.FileExists(p)
@@ -75,6 +113,9 @@ func (c *ClusterConfig) GetClientset() (*kubernetes.Clientset, error) {
 }
 
 func (c *ClusterConfig) GetNamespace() (string, error) {
+	if c.apiConfig == nil {
+		return "", errors.New("kubeconfig not loaded")
+	}
 	contextStruct, ok := c.apiConfig.Contexts[c.apiConfig.CurrentContext]
 	if !ok {
 		return "", fmt.Errorf("context %s not found in config", c.apiConfig.CurrentContext)
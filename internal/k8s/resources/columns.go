@@ -0,0 +1,328 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+)
+
+// extraColumns are the columns available per resource type besides the
+// default ones of ResourceToHeader, their values are returned by
+// extraColumner
+var extraColumns = map[ResourceType][]string{
+	ResourceTypePod: {"Images", "ImageTags", "Restarts"},
+}
+
+// extraColumner is implemented by the resources of extraColumns
+type extraColumner interface {
+	extraColumn(name string) string
+}
+
+// annotated is implemented by the resources embedding ResourceMeta
+type annotated interface {
+	GetAnnotations() map[string]string
+}
+
+// selectorer is implemented by the resources with a Selector column
+type selectorer interface {
+	getSelectors() []string
+}
+
+// UnknownColumnError is returned when selecting a column not available for a
+// resource type
+type UnknownColumnError struct {
+	ResourceType ResourceType
+	Column       string
+}
+
+func (u UnknownColumnError) Error() string {
+	return fmt.Sprintf("unknown column %s for %s, available columns: %s", u.Column, u.ResourceType,
+		strings.Join(AvailableColumns(u.ResourceType), ", "))
+}
+
+// column is a selected column: a default column found at defaultIdx in
+// ToStrings, an extra column, the value of a label or a captured annotation,
+// or a template column
+type column struct {
+	name       string
+	defaultIdx int
+	tmpl       *template.Template
+	// label or annotation of the column
+	label      string
+	annotation string
+}
+
+// searchable returns true when fzf matches the query on the column besides
+// the name
+func (c *column) searchable() bool {
+	return c.label != "" || c.annotation != ""
+}
+
+// columnSelection is the columns selected for a resource type
+type columnSelection struct {
+	header  string
+	columns []column
+}
+
+// Columns are the columns selected per resource type, see Select, and the
+// labels left out of the Labels and Selector columns. The types without
+// selection use their default columns, as does a nil Columns, which leaves
+// out DefaultExcludedLabels.
+type Columns struct {
+	selections     map[ResourceType]*columnSelection
+	excludedLabels map[string]bool
+}
+
+// NewColumns selects the columns of specs per resource type, the types with
+// invalid columns keep their default ones and the first error is returned
+// excludedLabels replace DefaultExcludedLabels.
+func NewColumns(specs map[ResourceType][]string, excludedLabels []string) (*Columns, error) {
+	c := &Columns{excludedLabels: util.StringSliceToSet(excludedLabels)}
+	var err error
+	for r := ResourceTypeApiResource + 1; r < ResourceTypeUnknown; r++ {
+		s, ok := specs[r]
+		if !ok {
+			continue
+		}
+		if selectErr := c.Select(r, s); selectErr != nil && err == nil {
+			err = selectErr
+		}
+	}
+	return c, err
+}
+
+// AvailableColumns returns the columns that can be selected for r: its
+// default columns then the extra ones
+func AvailableColumns(r ResourceType) []string {
+	return append(strings.Split(ResourceToHeader(r), "\t"), extraColumns[r]...)
+}
+
+// identityColumns are the columns the selected line is parsed from, always
+// first
+func identityColumns(r ResourceType) []string {
+	if r.IsNamespaced() {
+		return []string{"Namespace", "Name"}
+	}
+	return []string{"Name"}
+}
+
+// identityColumn returns the identity column of r named name, ignoring case
+func identityColumn(r ResourceType, name string) (string, bool) {
+	for _, c := range identityColumns(r) {
+		if strings.EqualFold(c, name) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+func parseColumn(r ResourceType, spec string) (column, error) {
+	if key := strings.TrimPrefix(spec, "label:"); key != spec && key != "" {
+		return column{name: key, defaultIdx: -1, label: key}, nil
+	}
+	if key := strings.TrimPrefix(spec, "annotation:"); key != spec && key != "" {
+		return column{name: key, defaultIdx: -1, annotation: key}, nil
+	}
+	// A template column is <name>={{ template }}
+	if i := strings.Index(spec, "="); i > 0 && strings.Contains(spec[i+1:], "{{") {
+		name := strings.TrimSpace(spec[:i])
+		tmpl, err := template.New(name).Option("missingkey=zero").Parse(spec[i+1:])
+		if err != nil {
+			return column{}, fmt.Errorf("invalid template of column %s: %w", name, err)
+		}
+		return column{name: name, defaultIdx: -1, tmpl: tmpl}, nil
+	}
+	defaultColumns := strings.Split(ResourceToHeader(r), "\t")
+	for i, name := range defaultColumns {
+		if strings.EqualFold(name, spec) {
+			return column{name: name, defaultIdx: i}, nil
+		}
+	}
+	for _, name := range extraColumns[r] {
+		if strings.EqualFold(name, spec) {
+			return column{name: name, defaultIdx: -1}, nil
+		}
+	}
+	return column{}, UnknownColumnError{ResourceType: r, Column: spec}
+}
+
+// Select selects and orders the columns of the completions of r
+// A column is either one of AvailableColumns, the value of a label written
+// label:<key>, the value of an annotation captured by the server written
+// annotation:<key> or a Go template executed with the resource, written
+// <name>={{ template }}, e.g. Team={{ index .Labels "team" }}. Namespace and
+// Name always come first: the selected line is parsed from them, other
+// columns can't take their name.
+// Empty specs restore the default columns.
+func (c *Columns) Select(r ResourceType, specs []string) error {
+	if r == ResourceTypeApiResource || r == ResourceTypeUnknown {
+		return fmt.Errorf("columns of %s can't be selected", r)
+	}
+	if len(specs) == 0 {
+		delete(c.selections, r)
+		return nil
+	}
+	selection := &columnSelection{}
+	for _, name := range identityColumns(r) {
+		col, err := parseColumn(r, name)
+		if err != nil {
+			return err
+		}
+		selection.columns = append(selection.columns, col)
+	}
+	for _, spec := range specs {
+		col, err := parseColumn(r, spec)
+		if err != nil {
+			return err
+		}
+		if name, ok := identityColumn(r, col.name); ok {
+			if col.defaultIdx >= 0 {
+				continue
+			}
+			return fmt.Errorf("column %s of %s can't be named %s, rename it with a template column", spec, r, name)
+		}
+		selection.columns = append(selection.columns, col)
+	}
+	names := make([]string, 0, len(selection.columns))
+	for _, col := range selection.columns {
+		names = append(names, col.name)
+	}
+	selection.header = strings.Join(names, "\t")
+	if c.selections == nil {
+		c.selections = map[ResourceType]*columnSelection{}
+	}
+	c.selections[r] = selection
+	return nil
+}
+
+func (c *Columns) selection(r ResourceType) (*columnSelection, bool) {
+	if c == nil {
+		return nil, false
+	}
+	selection, ok := c.selections[r]
+	return selection, ok
+}
+
+// Header returns the header of the completions of r with the selected
+// columns
+func (c *Columns) Header(r ResourceType) string {
+	if selection, ok := c.selection(r); ok {
+		return selection.header
+	}
+	return ResourceToHeader(r)
+}
+
+// Searchable returns the selected columns of r matched by the fzf query
+// besides the name: the label and annotation columns
+func (c *Columns) Searchable(r ResourceType) []string {
+	selection, ok := c.selection(r)
+	if !ok {
+		return nil
+	}
+	res := []string{}
+	for _, col := range selection.columns {
+		if col.searchable() {
+			res = append(res, col.name)
+		}
+	}
+	return res
+}
+
+// Strings returns the completion lines of resource, of type r, with the
+// selected columns
+func (c *Columns) Strings(r ResourceType, resource K8sResource) []string {
+	return c.lines(r, resource, resource.ToStrings())
+}
+
+// FullStrings returns the lines of Strings with full values, used by
+// previews
+func (c *Columns) FullStrings(r ResourceType, resource K8sResource) []string {
+	return c.lines(r, resource, ToFullStrings(resource))
+}
+
+// lines returns defaultLines, the default columns of resource, with the
+// selected columns and the configured excluded labels
+func (c *Columns) lines(r ResourceType, resource K8sResource, defaultLines []string) []string {
+	selection, ok := c.selection(r)
+	if !ok && (c == nil || c.excludedLabels == nil) {
+		return defaultLines
+	}
+	res := make([]string, 0, len(defaultLines))
+	for _, line := range defaultLines {
+		values := strings.Split(line, "\t")
+		c.excludeLabels(r, resource, values)
+		if ok {
+			res = append(res, selection.line(resource, values))
+		} else {
+			res = append(res, util.DumpLine(values))
+		}
+	}
+	return res
+}
+
+// excludeLabels replaces the Labels and Selector columns of the default
+// values of resource, which leave out DefaultExcludedLabels, with the ones
+// leaving out the configured excluded labels
+func (c *Columns) excludeLabels(r ResourceType, resource K8sResource, values []string) {
+	if c == nil || c.excludedLabels == nil {
+		return
+	}
+	for i, name := range strings.Split(ResourceToHeader(r), "\t") {
+		if i >= len(values) {
+			break
+		}
+		switch name {
+		case "Labels":
+			values[i] = joinLabels(resource.GetLabels(), c.excludedLabels)
+		case "Selector":
+			if s, ok := resource.(selectorer); ok {
+				values[i] = joinSelectors(s.getSelectors(), c.excludedLabels)
+			}
+		}
+	}
+}
+
+// line returns the values of the selected columns of resource, the default
+// ones being taken from defaultValues
+func (s *columnSelection) line(resource K8sResource, defaultValues []string) string {
+	values := make([]string, 0, len(s.columns))
+	for _, c := range s.columns {
+		values = append(values, c.value(resource, defaultValues))
+	}
+	return util.DumpLine(values)
+}
+
+// singleField replaces the whitespace of s with _, columns are split on
+// whitespace
+func singleField(s string) string {
+	return strings.Join(strings.Fields(s), "_")
+}
+
+func (c *column) value(resource K8sResource, defaultValues []string) string {
+	switch {
+	case c.label != "":
+		return resource.GetLabels()[c.label]
+	case c.annotation != "":
+		if a, ok := resource.(annotated); ok {
+			return singleField(a.GetAnnotations()[c.annotation])
+		}
+		return ""
+	case c.tmpl != nil:
+		b := new(strings.Builder)
+		if err := c.tmpl.Execute(b, resource); err != nil {
+			return "Error"
+		}
+		return singleField(b.String())
+	case c.defaultIdx >= 0:
+		if c.defaultIdx < len(defaultValues) {
+			return defaultValues[c.defaultIdx]
+		}
+		return ""
+	}
+	if e, ok := resource.(extraColumner); ok {
+		return e.extraColumn(c.name)
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package resources
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectColumns(t *testing.T) {
+	pod := &Pod{
+		ResourceMeta: ResourceMeta{Namespace: "prod", Name: "api-1", Labels: map[string]string{"team": "payment squad"}},
+		Phase:        "Running",
+		Images:       []string{"registry.local:5000/api:1.2.3", "envoy@sha256:abc"},
+		Restarts:     4,
+	}
+	columns, err := NewColumns(map[ResourceType][]string{
+		ResourceTypePod: {"imagetags", "Restarts", "Name", "Phase", `Team={{ index .Labels "team" }}`},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewColumns() error = %v", err)
+	}
+	if header := columns.Header(ResourceTypePod); header != "Namespace\tName\tImageTags\tRestarts\tPhase\tTeam" {
+		t.Fatalf("unexpected header %q", header)
+	}
+	expected := []string{"prod\tapi-1\t1.2.3,sha256:abc\t4\tRunning\tpayment_squad"}
+	if lines := columns.Strings(ResourceTypePod, pod); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("Strings() = %q, want %q", lines, expected)
+	}
+	if lines := columns.FullStrings(ResourceTypePod, pod); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("FullStrings() = %q, want %q", lines, expected)
+	}
+
+	var unknownColumnError UnknownColumnError
+	if err := columns.Select(ResourceTypeNode, []string{"Images"}); !errors.As(err, &unknownColumnError) {
+		t.Fatalf("expected unknown column error, got %v", err)
+	}
+	if header := columns.Header(ResourceTypeNode); header != ResourceToHeader(ResourceTypeNode) {
+		t.Fatalf("expected default node header, got %q", header)
+	}
+
+	for _, spec := range []string{"label:name", "annotation:Namespace", `Name={{ .Name }}`} {
+		if err := columns.Select(ResourceTypePod, []string{spec}); err == nil {
+			t.Fatalf("expected an error selecting %s", spec)
+		}
+	}
+	if err := columns.Select(ResourceTypePod, nil); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if lines := columns.Strings(ResourceTypePod, pod); !reflect.DeepEqual(lines, pod.ToStrings()) {
+		t.Fatalf("expected default columns, got %q", lines)
+	}
+	var defaults *Columns
+	if header := defaults.Header(ResourceTypePod); header != ResourceToHeader(ResourceTypePod) {
+		t.Fatalf("expected default pod header, got %q", header)
+	}
+}
+
+func TestPodRestarts(t *testing.T) {
+	pod := NewPodFromRuntime(&corev1.Pod{Status: corev1.PodStatus{
+		InitContainerStatuses: []corev1.ContainerStatus{{Name: "init", RestartCount: 2}},
+		ContainerStatuses:     []corev1.ContainerStatus{{Name: "app", RestartCount: 3}},
+	}}, CtorConfig{}).(*Pod)
+	if pod.Restarts != 5 {
+		t.Fatalf("Restarts = %d, want 5", pod.Restarts)
+	}
+}
+
+func TestLabelAndAnnotationColumns(t *testing.T) {
+	meta := metav1.ObjectMeta{
+		Namespace:   "prod",
+		Name:        "api",
+		Labels:      map[string]string{"app": "api"},
+		Annotations: map[string]string{"owner": "payment squad", "ignored": "value"},
+	}
+	deployment := NewDeploymentFromRuntime(&appsv1.Deployment{ObjectMeta: meta},
+		CtorConfig{CapturedAnnotations: []string{"owner", "missing"}})
+	if annotations := deployment.(annotated).GetAnnotations(); !reflect.DeepEqual(annotations, map[string]string{"owner": "payment squad"}) {
+		t.Fatalf("unexpected captured annotations %v", annotations)
+	}
+
+	columns := &Columns{}
+	err := columns.Select(ResourceTypeDeployment, []string{"annotation:owner", "Age", "label:app"})
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if header := columns.Header(ResourceTypeDeployment); header != "Namespace\tName\towner\tAge\tapp" {
+		t.Fatalf("unexpected header %q", header)
+	}
+	lines := columns.Strings(ResourceTypeDeployment, deployment)
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "prod\tapi\tpayment_squad\t") || !strings.HasSuffix(lines[0], "\tapi") {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+	if searchable := columns.Searchable(ResourceTypeDeployment); !reflect.DeepEqual(searchable, []string{"owner", "app"}) {
+		t.Fatalf("Searchable() = %q", searchable)
+	}
+	if searchable := columns.Searchable(ResourceTypePod); searchable != nil {
+		t.Fatalf("expected no searchable pod column, got %q", searchable)
+	}
+}
@@ -3,6 +3,9 @@ package resources
 // CtorConfig is the configuration passed to all resource constructors
 type CtorConfig struct {
 	IgnoredNodeRoles map[string]bool
+	// CapturedAnnotations are the annotation keys kept in
+	// ResourceMeta.Annotations
+	CapturedAnnotations []string
 }
 
 type ResourceCtor func(obj interface{}, config CtorConfig) K8sResource
@@ -17,6 +17,10 @@ type Deployment struct {
 	CurrentReplicas   string
 }
 
+// deploymentAvailableColumn is the column of the available replicas of a
+// deployment
+const deploymentAvailableColumn = "Available"
+
 // NewDeploymentFromRuntime builds a k8sresource from informer result
 func NewDeploymentFromRuntime(obj interface{}, config CtorConfig) K8sResource {
 	d := &Deployment{}
@@ -44,6 +48,20 @@ func (d *Deployment) HasChanged(k K8sResource) bool {
 	return true
 }
 
+// StatusColor colours the available replicas in yellow when less than
+// desired
+func (d *Deployment) StatusColor() ColumnColor {
+	desired, err := strconv.Atoi(d.DesiredReplicas)
+	if err != nil {
+		return ColumnColor{}
+	}
+	available, err := strconv.Atoi(d.AvailableReplicas)
+	if err != nil || available >= desired {
+		return ColumnColor{}
+	}
+	return ColumnColor{Column: deploymentAvailableColumn, Color: util.ColorYellow}
+}
+
 // ToString serializes the object to strings
 func (d *Deployment) ToStrings() []string {
 	line := []string{
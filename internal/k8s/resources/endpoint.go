@@ -53,14 +53,27 @@ func (e *Endpoints) HasChanged(k K8sResource) bool {
 
 // ToString serializes the object to strings
 func (e *Endpoints) ToStrings() []string {
+	return e.toStrings(func(sl []string) string {
+		return util.JoinSlicesWithMaxOrNone(sl, 20, ",")
+	})
+}
+
+// ToFullStrings serializes the object to strings with all addresses
+func (e *Endpoints) ToFullStrings() []string {
+	return e.toStrings(func(sl []string) string {
+		return util.JoinSlicesOrNone(sl, ",")
+	})
+}
+
+func (e *Endpoints) toStrings(join func([]string) string) []string {
 	line := []string{
 		e.Namespace,
 		e.Name,
 		e.resourceAge(),
-		util.JoinSlicesWithMaxOrNone(e.ReadyIps, 20, ","),
-		util.JoinSlicesWithMaxOrNone(e.ReadyPods, 20, ","),
-		util.JoinSlicesWithMaxOrNone(e.NotReadyIps, 20, ","),
-		util.JoinSlicesWithMaxOrNone(e.NotReadyPods, 20, ","),
+		join(e.ReadyIps),
+		join(e.ReadyPods),
+		join(e.NotReadyIps),
+		join(e.NotReadyPods),
 		e.labelsString(),
 	}
 	return util.DumpLines(line)
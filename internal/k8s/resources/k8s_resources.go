@@ -2,6 +2,7 @@ package resources
 
 import (
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/util"
@@ -22,14 +23,68 @@ type K8sResource interface {
 	FromRuntime(obj interface{}, config CtorConfig)
 }
 
+// FullStringer is implemented by resources truncating long values in
+// ToStrings: ToFullStrings returns the same columns with full values
+type FullStringer interface {
+	ToFullStrings() []string
+}
+
+// ToFullStrings returns the columns of r with full values, used by previews
+func ToFullStrings(r K8sResource) []string {
+	if f, ok := r.(FullStringer); ok {
+		return f.ToFullStrings()
+	}
+	return r.ToStrings()
+}
+
+// ColumnColor is the colour of a column of the completion of a resource
+type ColumnColor struct {
+	// Column is the name of the column in the header
+	Column string
+	Color  util.Color
+}
+
+// StatusColorer is implemented by resources with a status worth colouring:
+// StatusColor returns the column holding it and its colour
+type StatusColorer interface {
+	StatusColor() ColumnColor
+}
+
+// GetStatusColor returns the colour of the status column of r, ColorNone
+// when it has no status or it's fine
+func GetStatusColor(r K8sResource) ColumnColor {
+	if s, ok := r.(StatusColorer); ok {
+		return s.StatusColor()
+	}
+	return ColumnColor{}
+}
+
 // ResourceMeta is the generic information of a k8s entity
 type ResourceMeta struct {
-	Name         string
-	Namespace    string // Namespace can be None
-	Labels       map[string]string
+	Name      string
+	Namespace string // Namespace can be None
+	Labels    map[string]string
+	// Annotations are the annotations of CtorConfig.CapturedAnnotations
+	Annotations  map[string]string
 	CreationTime time.Time
 }
 
+// captureAnnotations returns the annotations of keys, nil when there's none
+func captureAnnotations(annotations map[string]string, keys []string) map[string]string {
+	var res map[string]string
+	for _, key := range keys {
+		value, ok := annotations[key]
+		if !ok {
+			continue
+		}
+		if res == nil {
+			res = map[string]string{}
+		}
+		res[key] = value
+	}
+	return res
+}
+
 func (r *ResourceMeta) GetNamespace() string {
 	return r.Namespace
 }
@@ -42,11 +97,17 @@ func (r *ResourceMeta) GetLabels() map[string]string {
 	return r.Labels
 }
 
+// GetAnnotations returns the captured annotations
+func (r *ResourceMeta) GetAnnotations() map[string]string {
+	return r.Annotations
+}
+
 // FromObjectMeta copies meta information to the object
 func (r *ResourceMeta) FromObjectMeta(meta metav1.ObjectMeta, config CtorConfig) {
 	r.Name = meta.Name
 	r.Namespace = meta.Namespace
 	r.Labels = meta.Labels
+	r.Annotations = captureAnnotations(meta.Annotations, config.CapturedAnnotations)
 	r.CreationTime = meta.CreationTimestamp.Time
 }
 
@@ -62,6 +123,11 @@ func (r *ResourceMeta) FromDynamicMeta(u *unstructured.Unstructured, config Ctor
 	if !found {
 		log.Debugf("metadata.labels was not found in %#v", u.Object)
 	}
+	if len(config.CapturedAnnotations) > 0 {
+		annotations, _, err := unstructured.NestedStringMap(u.Object, "metadata", "annotations")
+		util.FatalIf(err)
+		r.Annotations = captureAnnotations(annotations, config.CapturedAnnotations)
+	}
 	r.CreationTime, err = time.Parse(time.RFC3339, metadata["creationTimestamp"].(string))
 	util.FatalIf(err)
 }
@@ -70,22 +136,46 @@ func (r *ResourceMeta) resourceAge() string {
 	return util.TimeToAge(r.CreationTime)
 }
 
-// ExcludedLabels is a list of excluded label/selector from the dump
-var ExcludedLabels = map[string]string{"pod-template-generation": "",
-	"app.kubernetes.io/name": "", "controller-revision-hash": "",
-	"app.kubernetes.io/managed-by": "", "pod-template-hash": "",
-	"statefulset.kubernetes.io/pod-name": "",
-	"controler-uid":                      ""}
+// DefaultExcludedLabels are the labels left out of the Labels column and the
+// selectors unless configured otherwise
+var DefaultExcludedLabels = []string{"pod-template-generation",
+	"app.kubernetes.io/name", "controller-revision-hash",
+	"app.kubernetes.io/managed-by", "pod-template-hash",
+	"statefulset.kubernetes.io/pod-name",
+	"controler-uid"}
+
+var defaultExcludedLabels = util.StringSliceToSet(DefaultExcludedLabels)
 
 func (r *ResourceMeta) labelsString() string {
-	if len(r.Labels) == 0 {
+	return joinLabels(r.Labels, defaultExcludedLabels)
+}
+
+// joinLabels returns the sorted key=value pairs of labels, without the
+// excluded ones
+func joinLabels(labels map[string]string, excluded map[string]bool) string {
+	if len(labels) == 0 {
 		return "None"
 	}
-	els := util.JoinStringMap(r.Labels, ExcludedLabels, "=")
+	els := util.JoinStringMap(labels, excluded, "=")
 	sort.Strings(els)
 	return util.JoinSlicesOrNone(els, ",")
 }
 
+// joinSelectors returns selectors, written key=value, without the ones of
+// the excluded labels
+// All the selectors are stored: the completion excludes labels, see
+// Columns.
+func joinSelectors(selectors []string, excluded map[string]bool) string {
+	els := make([]string, 0, len(selectors))
+	for _, selector := range selectors {
+		if excluded[strings.SplitN(selector, "=", 2)[0]] {
+			continue
+		}
+		els = append(els, selector)
+	}
+	return util.JoinSlicesOrNone(els, ",")
+}
+
 func ResourceToHeader(r ResourceType) string {
 	replicaSetHeader := "Namespace\tName\tReplicas\tAvailableReplicas\tReadyReplicas\tSelector\tAge\tLabels"
 	apiResourceHeader := "Name\tShortnames\tApiVersion\tNamespaced\tKind"
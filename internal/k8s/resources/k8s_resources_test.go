@@ -0,0 +1,64 @@
+package resources
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+)
+
+func TestStatusColor(t *testing.T) {
+	testDatas := []struct {
+		resource K8sResource
+		color    ColumnColor
+	}{
+		{&Pod{Phase: "Running"}, ColumnColor{podPhaseColumn, util.ColorNone}},
+		{&Pod{Phase: "CrashLoopBackOff"}, ColumnColor{podPhaseColumn, util.ColorRed}},
+		{&Pod{Phase: "Pending"}, ColumnColor{podPhaseColumn, util.ColorYellow}},
+		{&Pod{Phase: "Init:PodInitializing"}, ColumnColor{podPhaseColumn, util.ColorYellow}},
+		{&Pod{Phase: "Init:Error"}, ColumnColor{podPhaseColumn, util.ColorYellow}},
+		{&Pod{Phase: "Init:CrashLoopBackOff"}, ColumnColor{podPhaseColumn, util.ColorYellow}},
+		{&Node{Status: "Ready"}, ColumnColor{nodeStatusColumn, util.ColorGreen}},
+		{&Node{Status: "Ready", Taints: []string{unschedulableTaint}}, ColumnColor{nodeStatusColumn, util.ColorRed}},
+		{&Node{Status: "KubeletNotReady"}, ColumnColor{nodeStatusColumn, util.ColorRed}},
+		{&Deployment{DesiredReplicas: "3", AvailableReplicas: "1"}, ColumnColor{deploymentAvailableColumn, util.ColorYellow}},
+		{&Deployment{DesiredReplicas: "3", AvailableReplicas: "3"}, ColumnColor{}},
+		{&ConfigMap{}, ColumnColor{}},
+	}
+
+	for _, v := range testDatas {
+		if color := GetStatusColor(v.resource); color != v.color {
+			t.Errorf("GetStatusColor(%+v) = %v, want %v", v.resource, color, v.color)
+		}
+	}
+}
+
+func TestExcludedLabels(t *testing.T) {
+	service := &Service{
+		ResourceMeta: ResourceMeta{Namespace: "prod", Name: "api", Labels: map[string]string{"app": "api", "pod-template-hash": "abc"}},
+		Selectors:    []string{"app=api", "pod-template-hash=abc"},
+	}
+	header := strings.Split(ResourceToHeader(ResourceTypeService), "\t")
+	column := func(line string, name string) string {
+		for i, h := range header {
+			if h == name {
+				return strings.Split(line, "\t")[i]
+			}
+		}
+		t.Fatalf("no %s column", name)
+		return ""
+	}
+	defaultLine := service.ToStrings()[0]
+	if labels, selectors := column(defaultLine, "Labels"), column(defaultLine, "Selector"); labels != "app=api" || selectors != "app=api" {
+		t.Fatalf("unexpected default labels %q and selectors %q", labels, selectors)
+	}
+
+	columns, err := NewColumns(nil, []string{"app"})
+	if err != nil {
+		t.Fatalf("NewColumns() error = %v", err)
+	}
+	line := columns.Strings(ResourceTypeService, service)[0]
+	if labels, selectors := column(line, "Labels"), column(line, "Selector"); labels != "pod-template-hash=abc" || selectors != "pod-template-hash=abc" {
+		t.Fatalf("unexpected labels %q and selectors %q", labels, selectors)
+	}
+}
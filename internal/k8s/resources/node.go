@@ -29,6 +29,12 @@ func NewNodeFromRuntime(obj interface{}, config CtorConfig) K8sResource {
 	return n
 }
 
+// nodeStatusColumn is the column of the status of a node
+const nodeStatusColumn = "Status"
+
+// unschedulableTaint is the taint of cordoned nodes
+var unschedulableTaint = corev1.TaintNodeUnschedulable + ":" + string(corev1.TaintEffectNoSchedule)
+
 func getNodeStatus(node *corev1.Node) string {
 	for _, condition := range node.Status.Conditions {
 		if condition.Type == "Ready" {
@@ -87,6 +93,16 @@ func (n *Node) HasChanged(k K8sResource) bool {
 	return true
 }
 
+// StatusColor colours the status of the node: green when it's ready, red
+// when it's not ready or cordoned
+// Cordoned nodes are told by their taint, the status is kept as reported.
+func (n *Node) StatusColor() ColumnColor {
+	if n.Status == "Ready" && !util.IsStringIn(unschedulableTaint, n.Taints) {
+		return ColumnColor{Column: nodeStatusColumn, Color: util.ColorGreen}
+	}
+	return ColumnColor{Column: nodeStatusColumn, Color: util.ColorRed}
+}
+
 // ToString serializes the object to strings
 func (n *Node) ToStrings() []string {
 	line := []string{
@@ -2,6 +2,7 @@ package resources
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
@@ -17,6 +18,8 @@ type Pod struct {
 	NodeName    string
 	Tolerations []string
 	Containers  []string
+	Images      []string
+	Restarts    int
 	Claims      []string
 	Phase       string
 	QosClass    string
@@ -51,6 +54,25 @@ func getPhase(p *corev1.Pod) string {
 	return string(p.Status.Phase)
 }
 
+// podPhaseColumn is the column of the phase of a pod
+const podPhaseColumn = "Phase"
+
+// phaseColor returns the colour of a phase returned by getPhase: none when
+// the pod runs or completed, yellow while it's starting or running its init
+// containers and red when failing
+func phaseColor(phase string) util.Color {
+	switch phase {
+	case "Running", "Succeeded", "Completed", "PodCompleted":
+		return util.ColorNone
+	case "Pending", "ContainerCreating", "PodInitializing", "ContainersNotReady", "Unschedulable":
+		return util.ColorYellow
+	}
+	if strings.HasPrefix(phase, "Init:") {
+		return util.ColorYellow
+	}
+	return util.ColorRed
+}
+
 // NewPodFromRuntime builds a pod from informer result
 func NewPodFromRuntime(obj interface{}, config CtorConfig) K8sResource {
 	p := &Pod{}
@@ -73,8 +95,18 @@ func (p *Pod) FromRuntime(obj interface{}, config CtorConfig) {
 	containers := spec.Containers
 	containers = append(containers, spec.InitContainers...)
 	p.Containers = make([]string, len(containers))
+	p.Images = make([]string, len(containers))
 	for k, v := range containers {
 		p.Containers[k] = v.Name
+		p.Images[k] = v.Image
+	}
+	// As kubectl, the restarts of the init containers are counted too
+	p.Restarts = 0
+	for _, v := range pod.Status.InitContainerStatuses {
+		p.Restarts += int(v.RestartCount)
+	}
+	for _, v := range pod.Status.ContainerStatuses {
+		p.Restarts += int(v.RestartCount)
 	}
 
 	volumes := spec.Volumes
@@ -108,10 +140,44 @@ func (p *Pod) HasChanged(k K8sResource) bool {
 	oldPod := k.(*Pod)
 	return (p.PodIP != oldPod.PodIP ||
 		p.Phase != oldPod.Phase ||
+		p.Restarts != oldPod.Restarts ||
 		util.StringMapsEqual(p.Labels, oldPod.Labels) ||
 		p.NodeName != oldPod.NodeName)
 }
 
+// StatusColor colours the phase of the pod
+func (p *Pod) StatusColor() ColumnColor {
+	return ColumnColor{Column: podPhaseColumn, Color: phaseColor(p.Phase)}
+}
+
+// imageTag returns the tag or digest of an image, latest without one
+func imageTag(image string) string {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return "latest"
+}
+
+func (p *Pod) extraColumn(name string) string {
+	switch name {
+	case "Images":
+		return util.JoinSlicesOrNone(p.Images, ",")
+	case "ImageTags":
+		tags := make([]string, 0, len(p.Images))
+		for _, image := range p.Images {
+			tags = append(tags, imageTag(image))
+		}
+		return util.JoinSlicesOrNone(tags, ",")
+	case "Restarts":
+		return strconv.Itoa(p.Restarts)
+	}
+	return ""
+}
+
 func (p *Pod) GetFieldSelectors() map[string]string {
 	return map[string]string{
 		"spec.nodeName": p.NodeName,
@@ -120,6 +186,16 @@ func (p *Pod) GetFieldSelectors() map[string]string {
 
 // ToString serializes the object to strings
 func (p *Pod) ToStrings() []string {
+	return p.toStrings(util.TruncateString(util.JoinSlicesOrNone(p.Containers, ","), 300))
+}
+
+// ToFullStrings serializes the object to strings without truncating the
+// containers
+func (p *Pod) ToFullStrings() []string {
+	return p.toStrings(util.JoinSlicesOrNone(p.Containers, ","))
+}
+
+func (p *Pod) toStrings(containers string) []string {
 	lst := []string{
 		p.Namespace,
 		p.Name,
@@ -128,7 +204,7 @@ func (p *Pod) ToStrings() []string {
 		p.NodeName,
 		p.Phase,
 		p.QosClass,
-		util.TruncateString(util.JoinSlicesOrNone(p.Containers, ","), 300),
+		containers,
 		util.JoinSlicesOrNone(p.Tolerations, ","),
 		util.JoinSlicesOrNone(p.Claims, ","),
 		p.resourceAge(),
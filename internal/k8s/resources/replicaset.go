@@ -30,8 +30,7 @@ func (r *ReplicaSet) FromRuntime(obj interface{}, config CtorConfig) {
 	r.Replicas = strconv.Itoa(int(replicaSet.Status.Replicas))
 	r.ReadyReplicas = strconv.Itoa(int(replicaSet.Status.ReadyReplicas))
 	r.AvailableReplicas = strconv.Itoa(int(replicaSet.Status.AvailableReplicas))
-	r.Selectors = util.JoinStringMap(replicaSet.Spec.Selector.MatchLabels,
-		ExcludedLabels, "=")
+	r.Selectors = util.JoinStringMap(replicaSet.Spec.Selector.MatchLabels, nil, "=")
 }
 
 // HasChanged returns true if the resource'r dump needs to be updated
@@ -44,9 +43,13 @@ func (r *ReplicaSet) HasChanged(k K8sResource) bool {
 		util.StringMapsEqual(r.Labels, oldRs.Labels))
 }
 
+func (r *ReplicaSet) getSelectors() []string {
+	return r.Selectors
+}
+
 // ToString serializes the object to strings
 func (r *ReplicaSet) ToStrings() []string {
-	selectorList := util.JoinSlicesOrNone(r.Selectors, ",")
+	selectorList := joinSelectors(r.Selectors, defaultExcludedLabels)
 	line := []string{
 		r.Namespace,
 		r.Name,
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 type UnknownResourceError struct {
@@ -83,6 +84,72 @@ func (r ResourceType) IsNamespaced() bool {
 	return false
 }
 
+// APIGroup returns the api group of the resource, empty for the core group
+func (r ResourceType) APIGroup() string {
+	switch r {
+	case ResourceTypeDaemonSet, ResourceTypeDeployment, ResourceTypeReplicaSet, ResourceTypeStatefulSet:
+		return "apps"
+	case ResourceTypeCronJob, ResourceTypeJob:
+		return "batch"
+	case ResourceTypeHorizontalPodAutoscaler:
+		return "autoscaling"
+	case ResourceTypeIngress:
+		return "networking.k8s.io"
+	}
+	return ""
+}
+
+// Kind returns the kind of the objects of the resource
+func (r ResourceType) Kind() string {
+	switch r {
+	case ResourceTypeApiResource:
+		return "APIResource"
+	case ResourceTypeConfigMap:
+		return "ConfigMap"
+	case ResourceTypeCronJob:
+		return "CronJob"
+	case ResourceTypeDaemonSet:
+		return "DaemonSet"
+	case ResourceTypeDeployment:
+		return "Deployment"
+	case ResourceTypeEndpoints:
+		return "Endpoints"
+	case ResourceTypeHorizontalPodAutoscaler:
+		return "HorizontalPodAutoscaler"
+	case ResourceTypeIngress:
+		return "Ingress"
+	case ResourceTypeJob:
+		return "Job"
+	case ResourceTypeNamespace:
+		return "Namespace"
+	case ResourceTypeNode:
+		return "Node"
+	case ResourceTypePod:
+		return "Pod"
+	case ResourceTypePersistentVolume:
+		return "PersistentVolume"
+	case ResourceTypePersistentVolumeClaim:
+		return "PersistentVolumeClaim"
+	case ResourceTypeReplicaSet:
+		return "ReplicaSet"
+	case ResourceTypeSecret:
+		return "Secret"
+	case ResourceTypeService:
+		return "Service"
+	case ResourceTypeServiceAccount:
+		return "ServiceAccount"
+	case ResourceTypeStatefulSet:
+		return "StatefulSet"
+	}
+	return "Unknown"
+}
+
+// GroupVersionResource returns the API resource of r, in the version watched
+// by the server
+func (r ResourceType) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: r.APIGroup(), Version: "v1", Resource: r.String()}
+}
+
 func (r ResourceType) String() string {
 	switch r {
 	case ResourceTypeApiResource:
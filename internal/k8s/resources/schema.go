@@ -0,0 +1,100 @@
+package resources
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strings"
+)
+
+// NewEmptyResource returns an empty resource of the given type
+func NewEmptyResource(r ResourceType) K8sResource {
+	switch r {
+	case ResourceTypeApiResource:
+		return &APIResourceList{}
+	case ResourceTypeConfigMap:
+		return &ConfigMap{}
+	case ResourceTypeCronJob:
+		return &CronJob{}
+	case ResourceTypeDaemonSet:
+		return &DaemonSet{}
+	case ResourceTypeDeployment:
+		return &Deployment{}
+	case ResourceTypeEndpoints:
+		return &Endpoints{}
+	case ResourceTypeHorizontalPodAutoscaler:
+		return &HorizontalPodAutoscaler{}
+	case ResourceTypeIngress:
+		return &Ingress{}
+	case ResourceTypeJob:
+		return &Job{}
+	case ResourceTypeNamespace:
+		return &Namespace{}
+	case ResourceTypeNode:
+		return &Node{}
+	case ResourceTypePod:
+		return &Pod{}
+	case ResourceTypePersistentVolume:
+		return &PersistentVolume{}
+	case ResourceTypePersistentVolumeClaim:
+		return &PersistentVolumeClaim{}
+	case ResourceTypeReplicaSet:
+		return &ReplicaSet{}
+	case ResourceTypeSecret:
+		return &Secret{}
+	case ResourceTypeService:
+		return &Service{}
+	case ResourceTypeServiceAccount:
+		return &ServiceAccount{}
+	case ResourceTypeStatefulSet:
+		return &StatefulSet{}
+	}
+	return nil
+}
+
+func describeType(b *strings.Builder, t reflect.Type) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		b.WriteString("*")
+		describeType(b, t.Elem())
+	case reflect.Slice:
+		b.WriteString("[]")
+		describeType(b, t.Elem())
+	case reflect.Map:
+		b.WriteString("map[")
+		describeType(b, t.Key())
+		b.WriteString("]")
+		describeType(b, t.Elem())
+	case reflect.Struct:
+		b.WriteString(t.Name())
+		b.WriteString("{")
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			b.WriteString(f.Name)
+			b.WriteString(":")
+			describeType(b, f.Type)
+			b.WriteString(";")
+		}
+		b.WriteString("}")
+	default:
+		b.WriteString(t.Kind().String())
+	}
+}
+
+// Schema returns a fingerprint of the fields stored for a resource type.
+// Any change to the resource struct changes the fingerprint, which lets
+// readers detect cache files written with different struct definitions.
+func Schema(r ResourceType) string {
+	empty := NewEmptyResource(r)
+	if empty == nil {
+		return ""
+	}
+	b := new(strings.Builder)
+	describeType(b, reflect.TypeOf(empty))
+	h := fnv.New64a()
+	h.Write([]byte(b.String()))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
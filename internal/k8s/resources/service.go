@@ -37,7 +37,7 @@ func (s *Service) FromRuntime(obj interface{}, config CtorConfig) {
 			s.Ports[k] = fmt.Sprintf("%s:%d", v.Name, v.Port)
 		}
 	}
-	s.Selectors = util.JoinStringMap(service.Spec.Selector, ExcludedLabels, "=")
+	s.Selectors = util.JoinStringMap(service.Spec.Selector, nil, "=")
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
@@ -48,10 +48,14 @@ func (s *Service) HasChanged(k K8sResource) bool {
 		util.StringMapsEqual(s.Labels, oldService.Labels))
 }
 
+func (s *Service) getSelectors() []string {
+	return s.Selectors
+}
+
 // ToString serializes the object to strings
 func (s *Service) ToStrings() []string {
 	portList := util.JoinSlicesOrNone(s.Ports, ",")
-	selectorList := util.JoinSlicesOrNone(s.Selectors, ",")
+	selectorList := joinSelectors(s.Selectors, defaultExcludedLabels)
 	line := []string{
 		s.Namespace,
 		s.Name,
@@ -28,7 +28,7 @@ func (s *StatefulSet) FromRuntime(obj interface{}, config CtorConfig) {
 	s.FromObjectMeta(statefulset.ObjectMeta, config)
 	s.currentReplicas = int(statefulset.Status.CurrentReplicas)
 	s.replicas = int(statefulset.Status.Replicas)
-	s.selectors = util.JoinStringMap(statefulset.Spec.Selector.MatchLabels, ExcludedLabels, "=")
+	s.selectors = util.JoinStringMap(statefulset.Spec.Selector.MatchLabels, nil, "=")
 }
 
 // HasChanged returns true if the resource's dump needs to be updated
@@ -40,9 +40,13 @@ func (s *StatefulSet) HasChanged(k K8sResource) bool {
 		util.StringMapsEqual(s.Labels, oldSts.Labels))
 }
 
+func (s *StatefulSet) getSelectors() []string {
+	return s.selectors
+}
+
 // ToString serializes the object to strings
 func (s *StatefulSet) ToStrings() []string {
-	selectorList := util.JoinSlicesOrNone(s.selectors, ",")
+	selectorList := joinSelectors(s.selectors, defaultExcludedLabels)
 	line := []string{
 		s.Namespace,
 		s.Name,
@@ -0,0 +1,45 @@
+package resourcewatcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+var watchErrorsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "kubectl_fzf_watch_errors_total",
+	Help: "Errors of resource watches and polls by reason",
+}, []string{"resource", "reason"})
+
+// recordWatchError counts err in watchErrorsCounter
+// Watches regularly closed by the apiserver are not errors
+func recordWatchError(resourceType resources.ResourceType, err error) {
+	if errors.Is(err, io.EOF) {
+		return
+	}
+	watchErrorsCounter.WithLabelValues(resourceType.String(), watchErrorReason(err)).Inc()
+}
+
+// watchErrorReason classifies err for watchErrorsCounter
+func watchErrorReason(err error) string {
+	var netErr net.Error
+	switch {
+	case apierrors.IsForbidden(err):
+		return "forbidden"
+	case apierrors.IsUnauthorized(err):
+		return "unauthorized"
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case apierrors.IsResourceExpired(err), apierrors.IsGone(err):
+		return "expired"
+	}
+	return "other"
+}
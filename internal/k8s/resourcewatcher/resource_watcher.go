@@ -2,7 +2,11 @@ package resourcewatcher
 
 import (
 	"context"
+	stderrors "errors"
+	"io"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
@@ -26,6 +30,10 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
+// syncCheckPeriod is the period at which erroring watches are checked for
+// recovery
+const syncCheckPeriod = 10 * time.Second
+
 // ResourceWatcher contains rest clients for a given kubernetes context
 type ResourceWatcher struct {
 	namespaces  []string // List of namespaces filtered using excludedNamespaces
@@ -39,6 +47,7 @@ type ResourceWatcher struct {
 	namespacePollingPeriod time.Duration
 	nodePollingPeriod      time.Duration
 	ctorConfig             resources.CtorConfig
+	capturedAnnotations    map[resources.ResourceType][]string
 	exitOnUnauthorized     bool
 }
 
@@ -81,7 +90,8 @@ func NewResourceWatcher(cluster string, resourceWatcherCli ResourceWatcherCli, s
 		ctorConfig: resources.CtorConfig{
 			IgnoredNodeRoles: ignoredNodeRoles,
 		},
-		exitOnUnauthorized: resourceWatcherCli.exitOnUnauthorized,
+		capturedAnnotations: resourceWatcherCli.capturedAnnotations,
+		exitOnUnauthorized:  resourceWatcherCli.exitOnUnauthorized,
 	}
 	return &resourceWatcher, nil
 }
@@ -90,7 +100,9 @@ func NewResourceWatcher(cluster string, resourceWatcherCli ResourceWatcherCli, s
 func (r *ResourceWatcher) Start(parentCtx context.Context, cfg WatchConfig) *store.Store {
 	ctx, cancel := context.WithCancel(parentCtx)
 	r.cancelFuncs = append(r.cancelFuncs, cancel)
-	store := store.NewStore(ctx, r.storeConfig, r.ctorConfig, cfg.resourceType)
+	ctorConfig := r.ctorConfig
+	ctorConfig.CapturedAnnotations = r.capturedAnnotations[cfg.resourceType]
+	store := store.NewStore(ctx, r.storeConfig, ctorConfig, cfg.resourceType)
 	if cfg.pollingPeriod > 0 {
 		go r.pollResource(ctx, cfg, store)
 	} else {
@@ -152,16 +164,32 @@ func (r *ResourceWatcher) GetWatchConfigs() ([]WatchConfig, error) {
 	return watchConfigs, nil
 }
 
-func (r *ResourceWatcher) doPoll(cacheListWatch *cache.ListWatch, store *store.Store) {
+// doPoll replaces the content of the store with the listed resources
+// On error, the current content is kept until the next successful poll
+func (r *ResourceWatcher) doPoll(cacheListWatch *cache.ListWatch, s *store.Store) {
 	obj, err := cacheListWatch.List(metav1.ListOptions{})
 	if err != nil {
+		recordWatchError(s.GetResourceType(), err)
+		s.RecordSyncError(syncErrorState(err), err)
 		log.Warnf("Error on listing resource: %v", err)
+		return
 	}
 	lst, err := apimeta.ExtractList(obj)
 	if err != nil {
+		s.RecordSyncError(store.SyncStateErroring, err)
 		log.Warnf("Error extracting list: %v", err)
+		return
+	}
+	s.AddResourceList(lst)
+	s.SetSyncState(store.SyncStateSynced)
+}
+
+// syncErrorState returns the state of a store after err
+func syncErrorState(err error) store.SyncState {
+	if errors.IsForbidden(err) {
+		return store.SyncStateForbidden
 	}
-	store.AddResourceList(lst)
+	return store.SyncStateErroring
 }
 
 // FetchNamespaces gets the list of namespace from the cluster and fill
@@ -218,8 +246,7 @@ func (r *ResourceWatcher) DumpAPIResources() error {
 		a.FromRuntime(resourceList, r.ctorConfig)
 		res[resourceList.GroupVersion] = &a
 	}
-	err = util.EncodeToFile(res, destFile)
-	return err
+	return store.EncodeResourcesToFile(res, r.storeConfig.GetContext(), resources.ResourceTypeApiResource, destFile)
 }
 
 func (r *ResourceWatcher) getCacheListWatch(cfg WatchConfig, store *store.Store, namespace string) *cache.ListWatch {
@@ -249,13 +276,17 @@ func (r *ResourceWatcher) pollResource(ctx context.Context,
 	}
 }
 
-func (r *ResourceWatcher) startWatch(cfg WatchConfig,
-	store *store.Store, namespace string, stop chan struct{}) {
-	cacheListWatch := r.getCacheListWatch(cfg, store, namespace)
+// newInformer creates the informer of a resource in namespace, all
+// namespaces when empty
+// stopWatch is called to stop all informers of the resource when it's
+// forbidden.
+func (r *ResourceWatcher) newInformer(cfg WatchConfig,
+	s *store.Store, namespace string, stopWatch func()) cache.SharedInformer {
+	cacheListWatch := r.getCacheListWatch(cfg, s, namespace)
 	resourceHandlers := cache.ResourceEventHandlerFuncs{
-		AddFunc:    store.AddResource,
-		DeleteFunc: store.DeleteResource,
-		UpdateFunc: store.UpdateResource,
+		AddFunc:    s.AddResource,
+		DeleteFunc: s.DeleteResource,
+		UpdateFunc: s.UpdateResource,
 	}
 	controller := cache.NewSharedInformer(
 		cacheListWatch,
@@ -265,37 +296,94 @@ func (r *ResourceWatcher) startWatch(cfg WatchConfig,
 	)
 	controller.AddEventHandler(resourceHandlers)
 	watchErrorHandler := func(reflector *cache.Reflector, err error) {
+		recordWatchError(cfg.resourceType, err)
+		if !stderrors.Is(err, io.EOF) {
+			s.RecordSyncError(syncErrorState(err), err)
+		}
 		if errors.IsUnauthorized(err) && r.exitOnUnauthorized {
 			log.Warnf("Resource %s is unauthorized, stopping watcher", cfg.resourceType)
 			r.Stop()
 		}
 		if errors.IsForbidden(err) {
 			log.Warnf("Resource %s is forbidden, stopping watcher. err: %s", cfg.resourceType, err)
-			close(stop)
+			stopWatch()
 		}
 	}
 	controller.SetWatchErrorHandler(watchErrorHandler)
-	controller.Run(stop)
+	return controller
+}
+
+// trackSync sets the store as watching once all informers are synced, and
+// again when they make progress after an error
+func trackSync(s *store.Store, informers []cache.SharedInformer, stop chan struct{}) {
+	hasSynced := make([]cache.InformerSynced, 0, len(informers))
+	for _, informer := range informers {
+		hasSynced = append(hasSynced, informer.HasSynced)
+	}
+	if !cache.WaitForCacheSync(stop, hasSynced...) {
+		return
+	}
+	s.SetSyncState(store.SyncStateWatching)
+
+	resourceVersions := func() string {
+		versions := make([]string, 0, len(informers))
+		for _, informer := range informers {
+			versions = append(versions, informer.LastSyncResourceVersion())
+		}
+		return strings.Join(versions, ",")
+	}
+	ticker := time.NewTicker(syncCheckPeriod)
+	defer ticker.Stop()
+	erroringVersions := ""
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if s.GetHealth().State != store.SyncStateErroring {
+			erroringVersions = ""
+			continue
+		}
+		versions := resourceVersions()
+		switch {
+		case erroringVersions == "":
+			erroringVersions = versions
+		case versions != erroringVersions:
+			log.Infof("Watch of %s recovered", s.GetResourceType())
+			s.SetSyncState(store.SyncStateWatching)
+			erroringVersions = ""
+		}
+	}
 }
 
 func (r *ResourceWatcher) watchResource(ctx context.Context,
-	cfg WatchConfig, store *store.Store, namespaces []string) {
+	cfg WatchConfig, s *store.Store, namespaces []string) {
 	stop := make(chan struct{})
+	var stopOnce sync.Once
+	stopWatch := func() {
+		stopOnce.Do(func() { close(stop) })
+	}
 	resourceType := cfg.resourceType
 	isNamespaced := resourceType.IsNamespaced()
 	if !isNamespaced {
 		log.Infof("Resource %s is not Namespaced, will ignore namespace filters", resourceType)
 	}
+	informers := []cache.SharedInformer{}
 	if isNamespaced && len(namespaces) > 0 {
 		log.Infof("Start watch for %s on namespace %s", resourceType, namespaces)
 		for _, ns := range namespaces {
-			go r.startWatch(cfg, store, ns, stop)
+			informers = append(informers, r.newInformer(cfg, s, ns, stopWatch))
 		}
 	} else {
 		log.Infof("Start watch for %s on all namespaces", resourceType)
-		go r.startWatch(cfg, store, "", stop)
+		informers = append(informers, r.newInformer(cfg, s, "", stopWatch))
+	}
+	for _, informer := range informers {
+		go informer.Run(stop)
 	}
+	go trackSync(s, informers, stop)
 	<-ctx.Done()
 	log.Infof("Exiting watch of %s namespace %s", resourceType, namespaces)
-	close(stop)
+	stopWatch()
 }
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"time"
 
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
 	"github.com/codeactual/kubectl-fzf/v4/internal/util/config"
 )
 
@@ -16,6 +17,9 @@ type ResourceWatcherCli struct {
 	nodePollingPeriod      time.Duration
 	namespacePollingPeriod time.Duration
 	exitOnUnauthorized     bool
+	// capturedAnnotations are the annotation keys captured per resource type,
+	// configured under capture-annotations.<type>
+	capturedAnnotations map[resources.ResourceType][]string
 }
 
 func SetResourceWatcherCli(fs *flag.FlagSet) {
@@ -30,6 +34,12 @@ func SetResourceWatcherCli(fs *flag.FlagSet) {
 }
 
 func NewResourceWatcherCli(store *config.Store) ResourceWatcherCli {
+	capturedAnnotations := map[resources.ResourceType][]string{}
+	for r := resources.ResourceTypeApiResource + 1; r < resources.ResourceTypeUnknown; r++ {
+		if keys := store.GetStringSlice("capture-annotations."+r.String(), nil); len(keys) > 0 {
+			capturedAnnotations[r] = keys
+		}
+	}
 	return ResourceWatcherCli{
 		watchResources:         store.GetStringSlice("watch-resources", []string{}),
 		excludResources:        store.GetStringSlice("exclude-resources", []string{}),
@@ -39,5 +49,6 @@ func NewResourceWatcherCli(store *config.Store) ResourceWatcherCli {
 		nodePollingPeriod:      store.GetDuration("node-polling-period", 300*time.Second),
 		namespacePollingPeriod: store.GetDuration("namespace-polling-period", 600*time.Second),
 		exitOnUnauthorized:     store.GetBool("exit-on-unauthorized", false),
+		capturedAnnotations:    capturedAnnotations,
 	}
 }
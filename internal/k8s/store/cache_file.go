@@ -0,0 +1,72 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+)
+
+// NewCacheHeader builds the header written in front of a resource dump
+func NewCacheHeader(cluster string, r resources.ResourceType, itemCount int) util.CacheHeader {
+	return util.CacheHeader{
+		ResourceType: r.String(),
+		Cluster:      cluster,
+		Schema:       resources.Schema(r),
+		WrittenAt:    time.Now(),
+		ItemCount:    itemCount,
+	}
+}
+
+// CheckCacheHeader returns an IncompatibleCacheError if the cache file
+// wasn't written for r with the same resource definitions as this binary
+func CheckCacheHeader(header util.CacheHeader, r resources.ResourceType) error {
+	if header.ResourceType != r.String() {
+		return util.IncompatibleCacheError{
+			Reason: fmt.Sprintf("file contains %s, expected %s", header.ResourceType, r)}
+	}
+	if header.Schema != resources.Schema(r) {
+		return util.IncompatibleCacheError{
+			Reason: fmt.Sprintf("%s schema %s, expected %s", r, header.Schema, resources.Schema(r))}
+	}
+	return nil
+}
+
+// EncodeResourcesToFile dumps resources of type r to filePath
+func EncodeResourcesToFile(data map[string]resources.K8sResource, cluster string,
+	r resources.ResourceType, filePath string) error {
+	header := NewCacheHeader(cluster, r, len(data))
+	return util.EncodeToFile(data, header, filePath)
+}
+
+// LoadResourcesFromFile loads a resource dump and checks it was written for r
+func LoadResourcesFromFile(filePath string, r resources.ResourceType) (map[string]resources.K8sResource, util.CacheHeader, error) {
+	data := map[string]resources.K8sResource{}
+	header, err := util.LoadGobFromFile(&data, filePath)
+	return checkDecodedResources(data, header, err, r)
+}
+
+// DecodeResources decodes the content of a resource dump and checks it was written for r
+func DecodeResources(b []byte, r resources.ResourceType) (map[string]resources.K8sResource, util.CacheHeader, error) {
+	data := map[string]resources.K8sResource{}
+	header, err := util.DecodeGob(&data, b)
+	return checkDecodedResources(data, header, err, r)
+}
+
+func checkDecodedResources(data map[string]resources.K8sResource, header util.CacheHeader,
+	decodeErr error, r resources.ResourceType) (map[string]resources.K8sResource, util.CacheHeader, error) {
+	if decodeErr != nil && header.ResourceType == "" {
+		// The header itself couldn't be read
+		return nil, header, decodeErr
+	}
+	// Check the header before reporting decode errors: a schema change is
+	// the likely cause of a failed decode
+	if err := CheckCacheHeader(header, r); err != nil {
+		return nil, header, err
+	}
+	if decodeErr != nil {
+		return nil, header, decodeErr
+	}
+	return data, header, nil
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+	"github.com/pkg/errors"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+)
+
+// DeltaOp is the kind of change recorded in a DeltaRecord
+type DeltaOp int
+
+const (
+	DeltaAdd DeltaOp = iota
+	DeltaUpdate
+	DeltaDelete
+)
+
+func (op DeltaOp) String() string {
+	switch op {
+	case DeltaAdd:
+		return "add"
+	case DeltaUpdate:
+		return "update"
+	case DeltaDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("DeltaOp(%d)", int(op))
+}
+
+// DeltaRecord is a single change of the store
+// Resource is nil for deletions
+type DeltaRecord struct {
+	Revision uint64
+	Op       DeltaOp
+	Key      string
+	Resource resources.K8sResource
+}
+
+// ApplyDeltas applies records with a revision above fromRevision to data
+// It returns the revision of the last applied record
+func ApplyDeltas(data map[string]resources.K8sResource, records []DeltaRecord, fromRevision uint64) uint64 {
+	revision := fromRevision
+	for _, record := range records {
+		if record.Revision <= revision {
+			continue
+		}
+		switch record.Op {
+		case DeltaAdd, DeltaUpdate:
+			data[record.Key] = record.Resource
+		case DeltaDelete:
+			delete(data, record.Key)
+		}
+		revision = record.Revision
+	}
+	return revision
+}
+
+// EncodeDeltas writes deltas in the same format as resource dumps
+func EncodeDeltas(w io.Writer, header util.CacheHeader, deltas []DeltaRecord) error {
+	header.ItemCount = len(deltas)
+	return util.Encode(w, deltas, header)
+}
+
+// DecodeDeltas decodes deltas written by EncodeDeltas and checks they were
+// written for r
+func DecodeDeltas(b []byte, r resources.ResourceType) ([]DeltaRecord, util.CacheHeader, error) {
+	deltas := []DeltaRecord{}
+	header, err := util.DecodeGob(&deltas, b)
+	if err != nil && header.ResourceType == "" {
+		return nil, header, err
+	}
+	if err := CheckCacheHeader(header, r); err != nil {
+		return nil, header, err
+	}
+	return deltas, header, err
+}
+
+// deltaLog is the append-only log of changes written next to a snapshot
+// The file starts with a cache header whose revision is the base revision of
+// the log, followed by a single gob stream of DeltaRecord.
+type deltaLog struct {
+	file    *os.File
+	encoder *gob.Encoder
+}
+
+// createDeltaLog atomically replaces the log at filePath with a log starting
+// at header.Revision and containing records
+func createDeltaLog(filePath string, header util.CacheHeader, records []DeltaRecord) (*deltaLog, error) {
+	f, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating delta log for %s", filePath)
+	}
+	l := &deltaLog{file: f, encoder: gob.NewEncoder(f)}
+	cleanup := func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+	if err := util.WriteCacheHeader(f, header); err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := l.append(records); err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := f.Chmod(0o600); err != nil {
+		cleanup()
+		return nil, errors.Wrapf(err, "error setting permissions of %s", f.Name())
+	}
+	if err := os.Rename(f.Name(), filePath); err != nil {
+		cleanup()
+		return nil, errors.Wrapf(err, "error renaming %s to %s", f.Name(), filePath)
+	}
+	return l, nil
+}
+
+func (l *deltaLog) append(records []DeltaRecord) error {
+	for i := range records {
+		if err := l.encoder.Encode(&records[i]); err != nil {
+			return errors.Wrapf(err, "error appending to delta log %s", l.file.Name())
+		}
+	}
+	return nil
+}
+
+func (l *deltaLog) close() error {
+	return l.file.Close()
+}
+
+// ReadDeltaLog reads the header and all complete records of a delta log
+// A truncated trailing record, left by a write in progress, is ignored
+func ReadDeltaLog(filePath string, r resources.ResourceType) (util.CacheHeader, []DeltaRecord, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return util.CacheHeader{}, nil, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	header, err := util.ReadCacheHeader(reader)
+	if err != nil {
+		return header, nil, errors.Wrapf(err, "error reading header of %s", filePath)
+	}
+	if err := CheckCacheHeader(header, r); err != nil {
+		return header, nil, err
+	}
+	records := []DeltaRecord{}
+	dec := gob.NewDecoder(reader)
+	for {
+		record := DeltaRecord{}
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Debugf("Stopping read of %s after %d records: %s", filePath, len(records), err)
+			break
+		}
+		records = append(records, record)
+	}
+	return header, records, nil
+}
+
+// LoadResourcesWithDeltaLog loads the snapshot at snapshotPath and replays
+// the delta log at logPath on top of it
+// The log is only replayed when both files come from the same server run,
+// or when the log starts from an empty store and is newer than the snapshot.
+// Otherwise the snapshot is returned as is.
+func LoadResourcesWithDeltaLog(snapshotPath string, logPath string,
+	r resources.ResourceType) (map[string]resources.K8sResource, util.CacheHeader, error) {
+	// A compaction between the read of the snapshot and the read of the log
+	// can leave an older snapshot with a newer log, retry to get a
+	// consistent pair
+	for attempt := 0; attempt < 3; attempt++ {
+		data, header, err := LoadResourcesFromFile(snapshotPath, r)
+		snapshotMissing := err != nil && os.IsNotExist(errors.Cause(err))
+		if err != nil && !snapshotMissing {
+			return nil, header, err
+		}
+		logHeader, records, logErr := ReadDeltaLog(logPath, r)
+		if logErr != nil {
+			if !os.IsNotExist(errors.Cause(logErr)) {
+				log.Warnf("Ignoring delta log %s: %s", logPath, logErr)
+			}
+			return data, header, err
+		}
+
+		var baseRevision uint64
+		switch {
+		case !snapshotMissing && header.Epoch == logHeader.Epoch && header.Revision >= logHeader.Revision:
+			baseRevision = header.Revision
+		case !snapshotMissing && header.Epoch > logHeader.Epoch:
+			// Epochs are start times: the log of a previous run is left until
+			// the first compaction
+			log.Debugf("%s is older than %s, ignoring it", logPath, snapshotPath)
+			return data, header, nil
+		case !snapshotMissing && logHeader.Revision == 0 && len(records) == 0:
+			log.Debugf("%s has no change, ignoring it", logPath)
+			return data, header, nil
+		case logHeader.Revision == 0:
+			// The log holds every change since the store started
+			data = map[string]resources.K8sResource{}
+			header = logHeader
+		case snapshotMissing:
+			return nil, header, err
+		case header.Epoch == logHeader.Epoch:
+			log.Debugf("%s is older than %s, retrying", snapshotPath, logPath)
+			continue
+		default:
+			log.Debugf("%s and %s were written by different server runs, ignoring the log", snapshotPath, logPath)
+			return data, header, nil
+		}
+		header.Revision = ApplyDeltas(data, records, baseRevision)
+		header.ItemCount = len(data)
+		return data, header, nil
+	}
+	log.Warnf("Couldn't get a consistent snapshot and delta log for %s, ignoring the log", r)
+	return LoadResourcesFromFile(snapshotPath, r)
+}
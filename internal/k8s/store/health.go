@@ -0,0 +1,144 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+)
+
+// SyncState is the state of the watch or poll filling a store
+type SyncState string
+
+const (
+	// SyncStateListing is the initial state, until the first list succeeds
+	SyncStateListing SyncState = "listing"
+	// SyncStateSynced is the state of polled resources after a successful poll
+	SyncStateSynced SyncState = "synced"
+	// SyncStateWatching is the state of watched resources once listed
+	SyncStateWatching SyncState = "watching"
+	// SyncStateForbidden is the state of resources whose watch was stopped
+	// because the user isn't allowed to list them
+	SyncStateForbidden SyncState = "forbidden"
+	// SyncStateErroring is the state of resources failing to list or watch,
+	// the watch retries in the background
+	SyncStateErroring SyncState = "erroring"
+)
+
+// Health is the sync state of a store
+type Health struct {
+	ResourceType  resources.ResourceType
+	State         SyncState
+	Since         time.Time
+	LastSynced    time.Time
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// Ready returns true while the store is synced, or when its watch was
+// deliberately stopped
+// A store erroring after a sync isn't ready: its data stops following the
+// cluster.
+func (h Health) Ready() bool {
+	switch h.State {
+	case SyncStateSynced, SyncStateWatching, SyncStateForbidden:
+		return true
+	}
+	return false
+}
+
+// SetSyncState records a new state of the store's watch or poll
+// The first sync dumps the state, replacing the snapshot and the delta log
+// left by the previous run.
+func (k *Store) SetSyncState(state SyncState) {
+	k.healthMutex.Lock()
+	firstSync := k.health.LastSynced.IsZero() && (state == SyncStateSynced || state == SyncStateWatching)
+	k.setSyncStateLocked(state)
+	k.healthMutex.Unlock()
+	if firstSync {
+		if err := k.dumpFullState(true); err != nil {
+			log.Warnf("Error dumping %s once synced: %s", k.resourceType, err)
+		}
+	}
+}
+
+func (k *Store) setSyncStateLocked(state SyncState) {
+	now := time.Now()
+	if state != k.health.State {
+		k.health.State = state
+		k.health.Since = now
+	}
+	if state == SyncStateSynced || state == SyncStateWatching {
+		k.health.LastSynced = now
+	}
+}
+
+// RecordSyncError records an error of the store's watch or poll along with
+// the resulting state
+func (k *Store) RecordSyncError(state SyncState, err error) {
+	k.healthMutex.Lock()
+	defer k.healthMutex.Unlock()
+	k.setSyncStateLocked(state)
+	k.health.LastError = err.Error()
+	k.health.LastErrorTime = time.Now()
+}
+
+// GetHealth returns the sync state of the store
+func (k *Store) GetHealth() Health {
+	k.healthMutex.Lock()
+	defer k.healthMutex.Unlock()
+	health := k.health
+	health.ResourceType = k.resourceType
+	return health
+}
+
+// HealthReport is the health of the stores of a server
+type HealthReport struct {
+	Ready     bool
+	Resources []Health
+}
+
+func GetHealthFromStores(stores []*Store) []Health {
+	healths := make([]Health, 0, len(stores))
+	for _, s := range stores {
+		healths = append(healths, s.GetHealth())
+	}
+	return healths
+}
+
+// AllReady returns true if all healths are ready
+func AllReady(healths []Health) bool {
+	for _, h := range healths {
+		if !h.Ready() {
+			return false
+		}
+	}
+	return true
+}
+
+func formatAge(now time.Time, t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return now.Sub(t).Truncate(time.Second).String()
+}
+
+func GetHealthOutput(healths []Health) string {
+	b := new(strings.Builder)
+	w := tabwriter.NewWriter(b, 0, 0, 1, ' ', tabwriter.StripEscape)
+	fmt.Fprintln(w, "Resource\tState\tSince\tLast Synced\tLast Error")
+	now := time.Now()
+	for _, h := range healths {
+		lastError := "None"
+		if h.LastError != "" {
+			lastError = fmt.Sprintf("%s ago: %s", formatAge(now, h.LastErrorTime), h.LastError)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", h.ResourceType, h.State,
+			formatAge(now, h.Since), formatAge(now, h.LastSynced), lastError)
+	}
+	w.Flush()
+	return b.String()
+}
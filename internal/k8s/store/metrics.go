@@ -0,0 +1,25 @@
+package store
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	dumpDurationGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubectl_fzf_store_dump_duration_seconds",
+		Help: "Duration of the last full dump of a resource",
+	}, []string{"resource"})
+	dumpSizeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubectl_fzf_store_dump_size_bytes",
+		Help: "Size of the last full dump of a resource",
+	}, []string{"resource"})
+	lastDumpGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubectl_fzf_store_last_dump_timestamp_seconds",
+		Help: "Unix time of the last successful full dump of a resource",
+	}, []string{"resource"})
+	dumpErrorsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubectl_fzf_store_dump_errors_total",
+		Help: "Failed full dumps of a resource",
+	}, []string{"resource"})
+)
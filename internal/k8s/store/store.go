@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,46 +20,75 @@ import (
 )
 
 // Store stores the current state of k8s resources
+// Informer handlers, pollers, the dump ticker and http handlers all access
+// the store concurrently: every read of the state holds mutex for reading and
+// every change goes through mutate.
+// Each change gets a revision and is appended to a delta log next to the
+// snapshot file. The ticker periodically compacts the log into a new snapshot.
+// The log starts with the snapshot dumped once the store is first synced:
+// until then, readers keep loading the files of the previous run.
 type Store struct {
-	data         map[string]resources.K8sResource
 	resourceCtor func(obj interface{}, config resources.CtorConfig) resources.K8sResource
 	ctorConfig   resources.CtorConfig
 	resourceType resources.ResourceType
-	currentFile  *os.File
 	storeConfig  *StoreConfig
-	firstWrite   bool
+	// epoch identifies this store instance, revisions restart at 0 with
+	// each new epoch
+	epoch int64
+
+	// dumpMutex serializes writes of the dump file
+	dumpMutex sync.Mutex
 
-	dataMutex sync.Mutex
+	// healthMutex guards health, updated by the watch or poll of the store
+	healthMutex sync.Mutex
+	health      Health
 
+	// mutex guards all fields below
+	mutex        sync.RWMutex
+	data         map[string]resources.K8sResource
+	revision     uint64
+	deltas       []DeltaRecord
+	deltaLog     *deltaLog
+	subscribers  map[*Subscription]struct{}
 	dumpRequired bool
 	lastFullDump time.Time
 }
 
 // NewStore creates a new store
+// The periodic full dump stops when ctx is cancelled
 func NewStore(ctx context.Context, storeConfig *StoreConfig,
 	ctorConfig resources.CtorConfig, resourceType resources.ResourceType) *Store {
 	k := Store{}
 	k.data = make(map[string]resources.K8sResource, 0)
+	k.subscribers = map[*Subscription]struct{}{}
 	k.resourceCtor = resources.ResourceTypeToCtor(resourceType)
 	k.resourceType = resourceType
-	k.currentFile = nil
 	k.storeConfig = storeConfig
-	k.firstWrite = true
 	k.ctorConfig = ctorConfig
+	k.epoch = time.Now().UnixNano()
 	k.lastFullDump = time.Time{}
-	go k.fullDumpTicker()
+	k.health = Health{State: SyncStateListing, Since: time.Now()}
+	go k.fullDumpTicker(ctx)
 
 	return &k
 }
 
-func (k *Store) fullDumpTicker() {
+func (k *Store) fullDumpTicker(ctx context.Context) {
 	timeBetweenFullDump := k.storeConfig.GetTimeBetweenFullDump()
 	log.Debugf("Starting ticker loop for %s: will do full dump every %s", k.resourceType, timeBetweenFullDump)
 	t := time.NewTicker(timeBetweenFullDump)
+	defer t.Stop()
 	for {
-		<-t.C
-		err := k.DumpFullState()
-		util.FatalIf(err)
+		select {
+		case <-ctx.Done():
+			log.Debugf("Stopping ticker loop for %s", k.resourceType)
+			k.closeDeltaLog()
+			k.closeSubscribers()
+			return
+		case <-t.C:
+			err := k.DumpFullState()
+			util.FatalIf(err)
+		}
 	}
 }
 
@@ -76,20 +107,93 @@ func resourceKey(obj interface{}) string {
 	default:
 		log.Warnf("Unknown type %v", obj)
 	}
+	return Key(namespace, name)
+}
+
+// Key returns the store key of a resource, namespace is empty for cluster
+// scoped resources
+func Key(namespace string, name string) string {
 	return fmt.Sprintf("%s_%s", namespace, name)
 }
 
-// AddResourceList clears current state add the objects to the store.
-// It will trigger a full dump
-// This is used for polled resources, no need for mutex
+// mutate is the only path changing the store's state.
+// fn runs with the write lock held and returns the changes to apply to data.
+// Each change gets the next revision, is retained for GetDeltasSince, is
+// appended to the delta log and sent to subscribers.
+func (k *Store) mutate(fn func() []DeltaRecord) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	changes := fn()
+	if len(changes) == 0 {
+		return
+	}
+	for i := range changes {
+		k.revision++
+		changes[i].Revision = k.revision
+	}
+	ApplyDeltas(k.data, changes, k.revision-uint64(len(changes)))
+	k.retainDeltas(changes)
+	k.appendToDeltaLog(changes)
+	k.notifySubscribersLocked(changes)
+	k.dumpRequired = true
+}
+
+// retainDeltas keeps the last changes in memory to serve them over http
+func (k *Store) retainDeltas(changes []DeltaRecord) {
+	retention := k.storeConfig.GetDeltaRetention()
+	k.deltas = append(k.deltas, changes...)
+	// Only reallocate once twice the retention is reached to avoid copying
+	// on every change
+	if len(k.deltas) > 2*retention {
+		k.deltas = append([]DeltaRecord(nil), k.deltas[len(k.deltas)-retention:]...)
+	}
+}
+
+func (k *Store) appendToDeltaLog(changes []DeltaRecord) {
+	if k.deltaLog == nil {
+		return
+	}
+	err := k.deltaLog.append(changes)
+	if err == nil {
+		return
+	}
+	// Readers would miss changes from an incomplete log, drop it until the
+	// next compaction
+	log.Warnf("Disabling delta log of %s until next full dump: %s", k.resourceType, err)
+	k.closeDeltaLogLocked()
+	logPath := k.storeConfig.GetResourceLogPath(k.resourceType)
+	if err := os.Remove(logPath); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Error removing %s: %s", logPath, err)
+	}
+}
+
+// AddResourceList replaces the current state with the objects
+// Only the differences with the current state are recorded
+// This is used for polled resources
 func (k *Store) AddResourceList(lstRuntime []runtime.Object) {
-	k.data = make(map[string]resources.K8sResource, 0)
+	data := make(map[string]resources.K8sResource, len(lstRuntime))
 	for _, runtimeObject := range lstRuntime {
 		key := resourceKey(runtimeObject)
 		resource := k.resourceCtor(runtimeObject, k.ctorConfig)
-		k.data[key] = resource
+		data[key] = resource
 	}
-	k.dumpRequired = true
+	k.mutate(func() []DeltaRecord {
+		changes := []DeltaRecord{}
+		for key := range k.data {
+			if _, ok := data[key]; !ok {
+				changes = append(changes, DeltaRecord{Op: DeltaDelete, Key: key})
+			}
+		}
+		for key, resource := range data {
+			previous, ok := k.data[key]
+			if !ok {
+				changes = append(changes, DeltaRecord{Op: DeltaAdd, Key: key, Resource: resource})
+			} else if resource.HasChanged(previous) {
+				changes = append(changes, DeltaRecord{Op: DeltaUpdate, Key: key, Resource: resource})
+			}
+		}
+		return changes
+	})
 }
 
 // AddResource adds a new k8s object to the store
@@ -97,10 +201,9 @@ func (k *Store) AddResource(obj interface{}) {
 	key := resourceKey(obj)
 	newObj := k.resourceCtor(obj, k.ctorConfig)
 	log.Tracef("%s added: %s", k.resourceType, key)
-	k.dataMutex.Lock()
-	k.data[key] = newObj
-	k.dataMutex.Unlock()
-	k.dumpRequired = true
+	k.mutate(func() []DeltaRecord {
+		return []DeltaRecord{{Op: DeltaAdd, Key: key, Resource: newObj}}
+	})
 }
 
 // DeleteResource removes an existing k8s object to the store
@@ -117,37 +220,120 @@ func (k *Store) DeleteResource(obj interface{}) {
 		return
 	}
 	log.Tracef("%s deleted: %s", k.resourceType, key)
-	k.dataMutex.Lock()
-	delete(k.data, key)
-	k.dataMutex.Unlock()
-	k.dumpRequired = true
+	k.mutate(func() []DeltaRecord {
+		if _, ok := k.data[key]; !ok {
+			return nil
+		}
+		return []DeltaRecord{{Op: DeltaDelete, Key: key}}
+	})
 }
 
 // UpdateResource update an existing k8s object
 func (k *Store) UpdateResource(oldObj, newObj interface{}) {
 	key := resourceKey(newObj)
 	k8sObj := k.resourceCtor(newObj, k.ctorConfig)
-	k.dataMutex.Lock()
-	if k8sObj.HasChanged(k.data[key]) {
+	k.mutate(func() []DeltaRecord {
+		previous, ok := k.data[key]
+		if ok && !k8sObj.HasChanged(previous) {
+			return nil
+		}
 		log.Tracef("%s changed: %s", k.resourceType, key)
-		k.data[key] = k8sObj
-		k.dataMutex.Unlock()
-		k.dumpRequired = true
-	} else {
-		k.dataMutex.Unlock()
+		return []DeltaRecord{{Op: DeltaUpdate, Key: key, Resource: k8sObj}}
+	})
+}
+
+// GetState returns a copy of the current data and its revision
+func (k *Store) GetState() (map[string]resources.K8sResource, uint64) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	data := make(map[string]resources.K8sResource, len(k.data))
+	for key, r := range k.data {
+		data[key] = r
 	}
+	return data, k.revision
+}
+
+// GetNamespaces returns the namespaces having resources in the store
+func (k *Store) GetNamespaces() []string {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	namespaces := map[string]bool{}
+	for _, r := range k.data {
+		namespaces[r.GetNamespace()] = true
+	}
+	res := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		res = append(res, namespace)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// KeyNamespace returns the namespace part of a store key
+func KeyNamespace(key string) string {
+	namespace, _, _ := strings.Cut(key, "_")
+	return namespace
+}
+
+// KeyName returns the name part of a store key
+func KeyName(key string) string {
+	_, name, _ := strings.Cut(key, "_")
+	return name
+}
+
+// GetEpoch returns the epoch of the store, revisions from different epochs
+// are not comparable
+func (k *Store) GetEpoch() int64 {
+	return k.epoch
+}
+
+// GetRevision returns the revision of the last change
+func (k *Store) GetRevision() uint64 {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.revision
+}
+
+// GetResourceType returns the type of resources held by the store
+func (k *Store) GetResourceType() resources.ResourceType {
+	return k.resourceType
+}
+
+// GetDeltasSince returns the changes after revision along with the current
+// revision.
+// ok is false when the epoch doesn't match or the changes are no longer
+// retained, the caller needs to reload the full state.
+func (k *Store) GetDeltasSince(epoch int64, revision uint64) (deltas []DeltaRecord, currentRevision uint64, ok bool) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.deltasSinceLocked(epoch, revision)
+}
+
+// deltasSinceLocked implements GetDeltasSince, the caller needs to hold mutex
+func (k *Store) deltasSinceLocked(epoch int64, revision uint64) (deltas []DeltaRecord, currentRevision uint64, ok bool) {
+	if epoch != k.epoch || revision > k.revision {
+		return nil, k.revision, false
+	}
+	if revision == k.revision {
+		return []DeltaRecord{}, k.revision, true
+	}
+	if len(k.deltas) == 0 || k.deltas[0].Revision > revision+1 {
+		return nil, k.revision, false
+	}
+	start := sort.Search(len(k.deltas), func(i int) bool {
+		return k.deltas[i].Revision > revision
+	})
+	deltas = make([]DeltaRecord, len(k.deltas)-start)
+	copy(deltas, k.deltas[start:])
+	return deltas, k.revision, true
 }
 
 func (k *Store) GetStats() *Stats {
 	itemPerNamespaces := make(map[string]int, 0)
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
 	for _, r := range k.data {
-		namespace := r.GetNamespace()
-		_, ok := itemPerNamespaces[namespace]
-		if !ok {
-			itemPerNamespaces[namespace] = 1
-		} else {
-			itemPerNamespaces[namespace]++
-		}
+		itemPerNamespaces[r.GetNamespace()]++
 	}
 	return &Stats{
 		ResourceType:     k.resourceType,
@@ -156,24 +342,118 @@ func (k *Store) GetStats() *Stats {
 	}
 }
 
-// DumpFullState writes the full state to the cache file
-func (k *Store) DumpFullState() error {
-	if !k.dumpRequired {
+// snapshotForDump returns a copy of the data and its revision if a dump is
+// due, or forced, and marks the state as dumped. Resources are never modified
+// once stored so a shallow copy is enough to encode them without holding the
+// lock.
+func (k *Store) snapshotForDump(force bool) (map[string]resources.K8sResource, uint64) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if !k.dumpRequired && !force {
 		log.Tracef("No change of %s detected, skipping dump", k.resourceType)
-		return nil
+		return nil, 0
 	}
 	now := time.Now()
 	delta := now.Sub(k.lastFullDump)
-	if delta < k.storeConfig.GetTimeBetweenFullDump() {
+	if delta < k.storeConfig.GetTimeBetweenFullDump() && !force {
 		log.Infof("Last full dump for %s happened %s ago, ignoring it", k.resourceType, delta)
-		return nil
+		return nil, 0
 	}
 	k.dumpRequired = false
 	k.lastFullDump = now
-	log.Infof("Doing full dump of %d %s", len(k.data), k.resourceType)
+	data := make(map[string]resources.K8sResource, len(k.data))
+	for key, r := range k.data {
+		data[key] = r
+	}
+	return data, k.revision
+}
+
+// DumpFullState compacts the delta log: it writes the full state to the
+// cache file and restarts the log from the dumped revision
+func (k *Store) DumpFullState() error {
+	return k.dumpFullState(false)
+}
+
+// dumpFullState implements DumpFullState, force dumps the state even without
+// change or before the time between full dumps
+func (k *Store) dumpFullState(force bool) error {
+	k.dumpMutex.Lock()
+	defer k.dumpMutex.Unlock()
+	data, revision := k.snapshotForDump(force)
+	if data == nil {
+		return nil
+	}
+	log.Infof("Doing full dump of %d %s", len(data), k.resourceType)
 	destFile := k.storeConfig.GetResourceStorePath(k.resourceType)
-	k.dataMutex.Lock()
-	err := util.EncodeToFile(k.data, destFile)
-	k.dataMutex.Unlock()
-	return err
+	header := k.CacheHeader(len(data), revision)
+	start := time.Now()
+	err := util.EncodeToFile(data, header, destFile)
+	if err != nil {
+		dumpErrorsCounter.WithLabelValues(k.resourceType.String()).Inc()
+		k.mutex.Lock()
+		k.dumpRequired = true
+		k.mutex.Unlock()
+		return err
+	}
+	dumpDurationGauge.WithLabelValues(k.resourceType.String()).Set(time.Since(start).Seconds())
+	lastDumpGauge.WithLabelValues(k.resourceType.String()).SetToCurrentTime()
+	if finfo, err := os.Stat(destFile); err == nil {
+		dumpSizeGauge.WithLabelValues(k.resourceType.String()).Set(float64(finfo.Size()))
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	// Changes made while the snapshot was written start the new log
+	first := sort.Search(len(k.deltas), func(i int) bool {
+		return k.deltas[i].Revision > revision
+	})
+	if k.revision > revision && (first == len(k.deltas) || k.deltas[first].Revision != revision+1) {
+		// Some of these changes are no longer retained, keep the current
+		// log which still has them and compact on next tick
+		k.dumpRequired = true
+		return nil
+	}
+	k.resetDeltaLogLocked(revision, k.deltas[first:])
+	return nil
+}
+
+// CacheHeader returns the header of a file holding itemCount resources of the
+// store at revision
+func (k *Store) CacheHeader(itemCount int, revision uint64) util.CacheHeader {
+	header := NewCacheHeader(k.storeConfig.GetContext(), k.resourceType, itemCount)
+	header.Epoch = k.epoch
+	header.Revision = revision
+	return header
+}
+
+// resetDeltaLogLocked replaces the delta log with a log starting at revision
+// The caller needs to hold mutex
+func (k *Store) resetDeltaLogLocked(revision uint64, records []DeltaRecord) {
+	k.closeDeltaLogLocked()
+	logPath := k.storeConfig.GetResourceLogPath(k.resourceType)
+	l, err := createDeltaLog(logPath, k.CacheHeader(0, revision), records)
+	if err != nil {
+		log.Warnf("Delta log of %s disabled until next full dump: %s", k.resourceType, err)
+		// Don't leave a log from a previous run for readers to replay
+		if err := os.Remove(logPath); err != nil && !os.IsNotExist(err) {
+			log.Warnf("Error removing %s: %s", logPath, err)
+		}
+		return
+	}
+	k.deltaLog = l
+}
+
+func (k *Store) closeDeltaLog() {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.closeDeltaLogLocked()
+}
+
+func (k *Store) closeDeltaLogLocked() {
+	if k.deltaLog == nil {
+		return
+	}
+	if err := k.deltaLog.close(); err != nil {
+		log.Warnf("Error closing delta log of %s: %s", k.resourceType, err)
+	}
+	k.deltaLog = nil
 }
@@ -11,15 +11,23 @@ import (
 type StoreConfig struct {
 	clusterconfig.ClusterConfig
 	timeBetweenFullDump time.Duration
+	deltaRetention      int
 }
 
 func NewStoreConfig(storeConfigCli *StoreConfigCli) *StoreConfig {
 	s := StoreConfig{}
 	s.ClusterConfig = clusterconfig.NewClusterConfig(storeConfigCli.ClusterConfigCli)
 	s.timeBetweenFullDump = storeConfigCli.TimeBetweenFullDump
+	s.deltaRetention = storeConfigCli.DeltaRetention
 	return &s
 }
 
 func (s *StoreConfig) GetTimeBetweenFullDump() time.Duration {
 	return s.timeBetweenFullDump
 }
+
+// GetDeltaRetention returns the number of changes kept in memory to be served
+// as deltas
+func (s *StoreConfig) GetDeltaRetention() int {
+	return s.deltaRetention
+}
@@ -11,16 +11,19 @@ import (
 type StoreConfigCli struct {
 	*clusterconfig.ClusterConfigCli
 	TimeBetweenFullDump time.Duration
+	DeltaRetention      int
 }
 
 func SetStoreConfigCli(fs *flag.FlagSet) {
 	clusterconfig.SetClusterConfigCli(fs)
-	fs.Duration("time-between-full-dump", 10*time.Second, "Buffer changes and only do full dump every x secondes")
+	fs.Duration("time-between-full-dump", 2*time.Minute, "Compact the delta log into a full dump at most every x seconds")
+	fs.Int("delta-retention", 10000, "Number of changes per resource kept in memory to serve deltas")
 }
 
 func NewStoreConfigCli(store *config.Store) StoreConfigCli {
 	return StoreConfigCli{
 		ClusterConfigCli:    clusterconfig.NewClusterConfigCli(store),
-		TimeBetweenFullDump: store.GetDuration("time-between-full-dump", 10*time.Second),
+		TimeBetweenFullDump: store.GetDuration("time-between-full-dump", 2*time.Minute),
+		DeltaRetention:      store.GetInt("delta-retention", 10000),
 	}
 }
@@ -35,14 +35,40 @@ func TestDumpAPIResources(t *testing.T) {
 	}
 
 	apiResourcesFilePath := path.Join(tempDir, "apiresources")
-	err = util.EncodeToFile(resource, apiResourcesFilePath)
+	err = EncodeResourcesToFile(resource, "test", resources.ResourceTypeApiResource, apiResourcesFilePath)
 	if err != nil {
-		t.Fatalf("EncodeToFile() error = %v", err)
+		t.Fatalf("EncodeResourcesToFile() error = %v", err)
 	}
 
-	loadResource := map[string]resources.K8sResource{}
-	err = util.LoadGobFromFile(&loadResource, apiResourcesFilePath)
+	loadResource, header, err := LoadResourcesFromFile(apiResourcesFilePath, resources.ResourceTypeApiResource)
 	if err != nil {
-		t.Fatalf("LoadGobFromFile() error = %v", err)
+		t.Fatalf("LoadResourcesFromFile() error = %v", err)
+	}
+	if len(loadResource) != 1 || header.ItemCount != 1 || header.Cluster != "test" {
+		t.Fatalf("unexpected load result %v, header %+v", loadResource, header)
+	}
+}
+
+func TestLoadResourcesWithOtherSchema(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := path.Join(tempDir, "pods")
+	data := map[string]resources.K8sResource{"ns_pod": &resources.Pod{}}
+
+	header := NewCacheHeader("test", resources.ResourceTypePod, len(data))
+	header.Schema = "0000000000000000"
+	if err := util.EncodeToFile(data, header, filePath); err != nil {
+		t.Fatalf("EncodeToFile() error = %v", err)
+	}
+	_, _, err := LoadResourcesFromFile(filePath, resources.ResourceTypePod)
+	if _, ok := err.(util.IncompatibleCacheError); !ok {
+		t.Fatalf("expected IncompatibleCacheError, got %v", err)
+	}
+
+	if err := EncodeResourcesToFile(data, "test", resources.ResourceTypePod, filePath); err != nil {
+		t.Fatalf("EncodeResourcesToFile() error = %v", err)
+	}
+	_, _, err = LoadResourcesFromFile(filePath, resources.ResourceTypeDeployment)
+	if _, ok := err.(util.IncompatibleCacheError); !ok {
+		t.Fatalf("expected IncompatibleCacheError for wrong resource type, got %v", err)
 	}
 }
@@ -0,0 +1,398 @@
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestMain(m *testing.M) {
+	log.SetLevel(log.DebugLevel)
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestDumpPodFullState(t *testing.T) {
+	tempDir, k := GetTestPodStore(t)
+	defer util.RemoveTempDir(tempDir)
+
+	err := k.DumpFullState()
+	if err != nil {
+		t.Fatalf("DumpFullState() error = %v", err)
+	}
+	podFilePath := path.Join(tempDir, "test", "pods")
+	if _, statErr := os.Stat(podFilePath); statErr != nil {
+		t.Fatalf("expected pod dump file to exist: %v", statErr)
+	}
+
+	pods, _, err := store.LoadResourcesFromFile(podFilePath, resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("LoadResourcesFromFile() error = %v", err)
+	}
+
+	if len(pods) != 4 {
+		t.Fatalf("expected 4 pods, got %d", len(pods))
+	}
+	for _, key := range []string{"ns1_Test1", "ns2_Test2", "ns2_Test3", "aaa_Test4"} {
+		if _, ok := pods[key]; !ok {
+			t.Fatalf("expected pod key %q in dump", key)
+		}
+	}
+}
+
+func TestTickerPodDumpFullState(t *testing.T) {
+	tempDir, s := GetTestPodStore(t)
+	defer util.RemoveTempDir(tempDir)
+
+	// Land between the first ticker dump (500ms) and the next tick (1s)
+	time.Sleep(750 * time.Millisecond)
+	podFilePath := path.Join(tempDir, "test", "pods")
+	if _, err := os.Stat(podFilePath); err != nil {
+		t.Fatalf("expected pod dump file to exist: %v", err)
+	}
+	pods, _, err := store.LoadResourcesFromFile(podFilePath, resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("LoadResourcesFromFile() error = %v", err)
+	}
+	if len(pods) != 4 {
+		t.Fatalf("expected 4 pods, got %d", len(pods))
+	}
+
+	pod := PodResource("Test1", "ns1", map[string]string{"app": "app1"})
+	s.AddResource(&pod)
+	fileInfoBefore, err := os.Stat(podFilePath)
+	if err != nil {
+		t.Fatalf("os.Stat() before error = %v", err)
+	}
+
+	// Wait less than TimeBetweenFullDump to confirm the ticker does not trigger.
+	time.Sleep(100 * time.Millisecond)
+	fileInfoShortWait, err := os.Stat(podFilePath)
+	if err != nil {
+		t.Fatalf("os.Stat() short wait error = %v", err)
+	}
+	if fileInfoShortWait.ModTime().After(fileInfoBefore.ModTime()) {
+		t.Fatalf("expected file modification time to remain unchanged before full dump interval")
+	}
+
+	// Wait long enough for the ticker to perform another full dump, ticks
+	// landing right before the end of the interval are skipped
+	time.Sleep(1100 * time.Millisecond)
+	fileInfoAfter, err := os.Stat(podFilePath)
+	if err != nil {
+		t.Fatalf("os.Stat() after error = %v", err)
+	}
+	if !fileInfoAfter.ModTime().After(fileInfoBefore.ModTime()) {
+		t.Fatalf("expected file modification time to increase after full dump interval")
+	}
+}
+
+func TestConcurrentStoreAccess(t *testing.T) {
+	tempDir, s := GetTestPodStore(t)
+	defer util.RemoveTempDir(tempDir)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				name := fmt.Sprintf("Pod-%d-%d", worker, j%20)
+				pod := PodResource(name, "concurrent", map[string]string{"app": name})
+				switch j % 3 {
+				case 0:
+					s.AddResource(&pod)
+				case 1:
+					updated := PodResource(name, "concurrent", map[string]string{"app": "updated"})
+					s.UpdateResource(&pod, &updated)
+				case 2:
+					s.DeleteResource(&pod)
+				}
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			pods := []runtime.Object{}
+			for k := 0; k < 5; k++ {
+				pod := PodResource(fmt.Sprintf("Listed-%d", k), "listed", nil)
+				pods = append(pods, &pod)
+			}
+			s.AddResourceList(pods)
+		}
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 200; j++ {
+			stats := s.GetStats()
+			if stats.ResourceType != resources.ResourceTypePod {
+				t.Errorf("unexpected resource type %s", stats.ResourceType)
+				return
+			}
+		}
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 20; j++ {
+			if err := s.DumpFullState(); err != nil {
+				t.Errorf("DumpFullState() error = %v", err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	// Force a final dump and check it matches the in-memory state
+	time.Sleep(600 * time.Millisecond)
+	s.AddResourceList([]runtime.Object{})
+	pod := PodResource("Last", "final", nil)
+	s.AddResource(&pod)
+	time.Sleep(600 * time.Millisecond)
+	if err := s.DumpFullState(); err != nil {
+		t.Fatalf("DumpFullState() error = %v", err)
+	}
+	pods, _, err := store.LoadResourcesFromFile(path.Join(tempDir, "test", "pods"), resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("LoadResourcesFromFile() error = %v", err)
+	}
+	if len(pods) != 1 {
+		t.Fatalf("expected 1 pod after final dump, got %d", len(pods))
+	}
+	if _, ok := pods["final_Last"]; !ok {
+		t.Fatalf("expected pod key final_Last in dump, got %v", pods)
+	}
+	replayed, _, err := store.LoadResourcesWithDeltaLog(path.Join(tempDir, "test", "pods"),
+		path.Join(tempDir, "test", "pods.log"), resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("LoadResourcesWithDeltaLog() error = %v", err)
+	}
+	if len(replayed) != 1 {
+		t.Fatalf("expected 1 pod after replay, got %v", replayed)
+	}
+	stats := s.GetStats()
+	if stats.ItemPerNamespace["final"] != 1 || len(stats.ItemPerNamespace) != 1 {
+		t.Fatalf("unexpected stats %v", stats.ItemPerNamespace)
+	}
+}
+
+func loadWithDeltaLog(t *testing.T, tempDir string) (map[string]resources.K8sResource, util.CacheHeader) {
+	t.Helper()
+	data, header, err := store.LoadResourcesWithDeltaLog(path.Join(tempDir, "test", "pods"),
+		path.Join(tempDir, "test", "pods.log"), resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("LoadResourcesWithDeltaLog() error = %v", err)
+	}
+	return data, header
+}
+
+func TestDeltaLogReplay(t *testing.T) {
+	tempDir, s := GetTestPodStore(t)
+	defer util.RemoveTempDir(tempDir)
+
+	// The first sync dumps the state and starts the log
+	pods, header := loadWithDeltaLog(t, tempDir)
+	if len(pods) != 4 || header.Revision != 4 || header.Epoch != s.GetEpoch() {
+		t.Fatalf("expected 4 pods at revision 4, got %d pods, header %+v", len(pods), header)
+	}
+	logHeader, records, err := store.ReadDeltaLog(path.Join(tempDir, "test", "pods.log"), resources.ResourceTypePod)
+	if err != nil {
+		t.Fatalf("ReadDeltaLog() error = %v", err)
+	}
+	if logHeader.Revision != 4 || len(records) != 0 {
+		t.Fatalf("expected compacted log at revision 4, got header %+v and %d records", logHeader, len(records))
+	}
+
+	pod := PodResource("Test5", "ns1", nil)
+	s.AddResource(&pod)
+	deleted := PodResource("Test1", "ns1", nil)
+	s.DeleteResource(&deleted)
+	oldPod := PodResource("Test2", "ns2", map[string]string{"app": "app2"})
+	updated := PodResource("Test2", "ns2", map[string]string{"app": "app2"})
+	updated.Spec.NodeName = "node1"
+	s.UpdateResource(&oldPod, &updated)
+
+	pods, header = loadWithDeltaLog(t, tempDir)
+	if header.Revision != 7 {
+		t.Fatalf("expected revision 7, got %d", header.Revision)
+	}
+	if _, ok := pods["ns1_Test1"]; ok {
+		t.Fatalf("expected ns1_Test1 to be deleted")
+	}
+	if _, ok := pods["ns1_Test5"]; !ok {
+		t.Fatalf("expected ns1_Test5 to be added")
+	}
+	if pods["ns2_Test2"].(*resources.Pod).NodeName != "node1" {
+		t.Fatalf("expected ns2_Test2 to be updated, got %v", pods["ns2_Test2"])
+	}
+
+	// A record being written is ignored
+	logFile, err := os.OpenFile(path.Join(tempDir, "test", "pods.log"), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	_, err = logFile.Write([]byte{0x20, 0xff, 0x81})
+	logFile.Close()
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	pods, header = loadWithDeltaLog(t, tempDir)
+	if len(pods) != 4 || header.Revision != 7 {
+		t.Fatalf("expected 4 pods at revision 7 with truncated record, got %d pods, header %+v", len(pods), header)
+	}
+}
+
+func TestRestartBeforeSync(t *testing.T) {
+	tempDir, s := GetTestPodStore(t)
+	defer util.RemoveTempDir(tempDir)
+	pod := PodResource("Test5", "ns1", nil)
+	s.AddResource(&pod)
+
+	// Until its first sync, a new store leaves the files of the previous run
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storeConfig := store.NewStoreConfig(&store.StoreConfigCli{
+		ClusterConfigCli:    &clusterconfig.ClusterConfigCli{ClusterName: "test", CacheDir: tempDir},
+		TimeBetweenFullDump: time.Hour,
+	})
+	restarted := store.NewStore(ctx, storeConfig, resources.CtorConfig{}, resources.ResourceTypePod)
+	listed := PodResource("Test1", "ns1", map[string]string{"app": "app1"})
+	restarted.AddResource(&listed)
+	pods, header := loadWithDeltaLog(t, tempDir)
+	if len(pods) != 5 || header.Epoch != s.GetEpoch() {
+		t.Fatalf("expected the 5 pods of the previous run, got %d pods, header %+v", len(pods), header)
+	}
+
+	restarted.SetSyncState(store.SyncStateWatching)
+	pods, header = loadWithDeltaLog(t, tempDir)
+	if len(pods) != 1 || header.Epoch != restarted.GetEpoch() {
+		t.Fatalf("expected the pod of the synced store, got %d pods, header %+v", len(pods), header)
+	}
+	added := PodResource("Test6", "ns1", nil)
+	restarted.AddResource(&added)
+	if pods, _ = loadWithDeltaLog(t, tempDir); len(pods) != 2 {
+		t.Fatalf("expected 2 pods after replaying the log, got %d", len(pods))
+	}
+}
+
+func TestGetDeltasSince(t *testing.T) {
+	tempDir, s := GetTestPodStore(t)
+	defer util.RemoveTempDir(tempDir)
+
+	deltas, revision, ok := s.GetDeltasSince(s.GetEpoch(), 2)
+	if !ok || revision != 4 || len(deltas) != 2 {
+		t.Fatalf("expected 2 deltas up to revision 4, got %v %d %v", deltas, revision, ok)
+	}
+	if deltas[0].Revision != 3 || deltas[0].Op != store.DeltaAdd || deltas[0].Key != "ns2_Test3" {
+		t.Fatalf("unexpected first delta %+v", deltas[0])
+	}
+
+	deltas, _, ok = s.GetDeltasSince(s.GetEpoch(), 4)
+	if !ok || len(deltas) != 0 {
+		t.Fatalf("expected no delta at current revision, got %v %v", deltas, ok)
+	}
+	if _, _, ok = s.GetDeltasSince(s.GetEpoch()+1, 2); ok {
+		t.Fatalf("expected deltas from another epoch to be unavailable")
+	}
+
+	// Go past the retention of 100 changes
+	for i := 0; i < 250; i++ {
+		pod := PodResource(fmt.Sprintf("Pod%d", i), "retention", nil)
+		s.AddResource(&pod)
+	}
+	if _, _, ok = s.GetDeltasSince(s.GetEpoch(), 2); ok {
+		t.Fatalf("expected deltas past retention to be unavailable")
+	}
+	deltas, revision, ok = s.GetDeltasSince(s.GetEpoch(), 200)
+	if !ok || revision != 254 || len(deltas) != 54 {
+		t.Fatalf("expected 54 deltas up to revision 254, got %d %d %v", len(deltas), revision, ok)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	tempDir, s := GetTestPodStore(t)
+	defer util.RemoveTempDir(tempDir)
+
+	sub, backlog, ok := s.Subscribe(s.GetEpoch(), 3, 2)
+	if !ok || len(backlog) != 1 || backlog[0].Revision != 4 {
+		t.Fatalf("expected the backlog of revision 4, got %v %v", backlog, ok)
+	}
+	pod := PodResource("Sub1", "sub", nil)
+	s.AddResource(&pod)
+	record := <-sub.C
+	if record.Revision != 5 || record.Key != "sub_Sub1" || record.Op != store.DeltaAdd {
+		t.Fatalf("unexpected record %+v", record)
+	}
+
+	// A full buffer drops the subscriber without blocking the store
+	for i := 0; i < 3; i++ {
+		pod := PodResource(fmt.Sprintf("Slow%d", i), "sub", nil)
+		s.AddResource(&pod)
+	}
+	received := 0
+	for range sub.C {
+		received++
+	}
+	if received != 2 || !sub.Overflowed() {
+		t.Fatalf("expected 2 buffered records and an overflow, got %d %v", received, sub.Overflowed())
+	}
+	// The subscriber resumes from its last record
+	sub, backlog, ok = s.Subscribe(s.GetEpoch(), 7, 2)
+	if !ok || len(backlog) != 1 || backlog[0].Key != "sub_Slow2" {
+		t.Fatalf("expected to resume with sub_Slow2, got %v %v", backlog, ok)
+	}
+	sub.Close()
+	if _, ok := <-sub.C; ok || sub.Overflowed() {
+		t.Fatalf("expected a closed subscription without overflow")
+	}
+
+	if _, _, ok = s.Subscribe(s.GetEpoch()+1, 0, 2); ok {
+		t.Fatalf("expected subscription from another epoch to fail")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	tempDir, s := GetTestPodStore(t)
+	defer util.RemoveTempDir(tempDir)
+	if !s.GetHealth().Ready() {
+		t.Fatalf("expected synced store to be ready")
+	}
+	s.RecordSyncError(store.SyncStateErroring, fmt.Errorf("connection refused"))
+	health := s.GetHealth()
+	if health.State != store.SyncStateErroring || health.LastError != "connection refused" || health.Ready() {
+		t.Fatalf("expected erroring store to not be ready with its last error, got %+v", health)
+	}
+	s.SetSyncState(store.SyncStateWatching)
+	if health = s.GetHealth(); !health.Ready() || health.LastError != "connection refused" {
+		t.Fatalf("expected store to be ready again once watching, got %+v", health)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storeConfig := store.NewStoreConfig(&store.StoreConfigCli{
+		ClusterConfigCli:    &clusterconfig.ClusterConfigCli{ClusterName: "test", CacheDir: tempDir},
+		TimeBetweenFullDump: time.Minute})
+	listing := store.NewStore(ctx, storeConfig, resources.CtorConfig{}, resources.ResourceTypeSecret)
+	healths := store.GetHealthFromStores([]*store.Store{s, listing})
+	if healths[1].State != store.SyncStateListing || store.AllReady(healths) {
+		t.Fatalf("expected a listing store to not be ready, got %+v", healths[1])
+	}
+	listing.RecordSyncError(store.SyncStateForbidden, fmt.Errorf("secrets is forbidden"))
+	if !store.AllReady(store.GetHealthFromStores([]*store.Store{s, listing})) {
+		t.Fatalf("expected a forbidden store to be ready")
+	}
+}
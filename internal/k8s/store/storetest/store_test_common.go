@@ -3,27 +3,18 @@ package storetest
 import (
 	"context"
 	"io/ioutil"
-	"os"
-	"path"
 	"testing"
 	"time"
 
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
-	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
-	"github.com/codeactual/kubectl-fzf/v4/internal/util"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func TestMain(m *testing.M) {
-	log.SetLevel(log.DebugLevel)
-	code := m.Run()
-	os.Exit(code)
-}
-
-func podResource(name string, ns string, labels map[string]string) corev1.Pod {
+// PodResource returns a pod created now with the given labels
+func PodResource(name string, ns string, labels map[string]string) corev1.Pod {
 	meta := corev1.Pod{
 		TypeMeta: metav1.TypeMeta{Kind: "Pod"},
 		ObjectMeta: metav1.ObjectMeta{
@@ -46,99 +37,26 @@ func GetTestPodStore(t *testing.T) (string, *store.Store) {
 	storeConfigCli := &store.StoreConfigCli{
 		ClusterConfigCli: &clusterconfig.ClusterConfigCli{
 			ClusterName: "test", CacheDir: tempDir},
-		TimeBetweenFullDump: 500 * time.Millisecond}
+		TimeBetweenFullDump: 500 * time.Millisecond,
+		DeltaRetention:      100}
 	storeConfig := store.NewStoreConfig(storeConfigCli)
 	err = storeConfig.CreateDestDir()
 	if err != nil {
 		t.Fatalf("CreateDestDir() error = %v", err)
 	}
 	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	t.Cleanup(cancel)
 	ctorConfig := resources.CtorConfig{}
 	k8sStore := store.NewStore(ctx, storeConfig, ctorConfig, resources.ResourceTypePod)
 	pods := []corev1.Pod{
-		podResource("Test1", "ns1", map[string]string{"app": "app1"}),
-		podResource("Test2", "ns2", map[string]string{"app": "app2"}),
-		podResource("Test3", "ns2", map[string]string{"app": "app2"}),
-		podResource("Test4", "aaa", map[string]string{"app": "app3"}),
+		PodResource("Test1", "ns1", map[string]string{"app": "app1"}),
+		PodResource("Test2", "ns2", map[string]string{"app": "app2"}),
+		PodResource("Test3", "ns2", map[string]string{"app": "app2"}),
+		PodResource("Test4", "aaa", map[string]string{"app": "app3"}),
 	}
 	for _, pod := range pods {
 		k8sStore.AddResource(&pod)
 	}
+	k8sStore.SetSyncState(store.SyncStateWatching)
 	return tempDir, k8sStore
 }
-
-func TestDumpPodFullState(t *testing.T) {
-	tempDir, k := GetTestPodStore(t)
-	defer util.RemoveTempDir(tempDir)
-
-	err := k.DumpFullState()
-	if err != nil {
-		t.Fatalf("DumpFullState() error = %v", err)
-	}
-	podFilePath := path.Join(tempDir, "test", "pods")
-	if _, statErr := os.Stat(podFilePath); statErr != nil {
-		t.Fatalf("expected pod dump file to exist: %v", statErr)
-	}
-
-	pods := map[string]resources.K8sResource{}
-	err = util.LoadGobFromFile(&pods, podFilePath)
-	if err != nil {
-		t.Fatalf("LoadGobFromFile() error = %v", err)
-	}
-
-	if len(pods) != 4 {
-		t.Fatalf("expected 4 pods, got %d", len(pods))
-	}
-	for _, key := range []string{"ns1_Test1", "ns2_Test2", "ns2_Test3", "aaa_Test4"} {
-		if _, ok := pods[key]; !ok {
-			t.Fatalf("expected pod key %q in dump", key)
-		}
-	}
-}
-
-func TestTickerPodDumpFullState(t *testing.T) {
-	tempDir, s := GetTestPodStore(t)
-	defer util.RemoveTempDir(tempDir)
-
-	time.Sleep(1000 * time.Millisecond)
-	podFilePath := path.Join(tempDir, "test", "pods")
-	if _, err := os.Stat(podFilePath); err != nil {
-		t.Fatalf("expected pod dump file to exist: %v", err)
-	}
-	pods := map[string]resources.K8sResource{}
-	err := util.LoadGobFromFile(&pods, podFilePath)
-	if err != nil {
-		t.Fatalf("LoadGobFromFile() error = %v", err)
-	}
-	if len(pods) != 4 {
-		t.Fatalf("expected 4 pods, got %d", len(pods))
-	}
-
-	pod := podResource("Test1", "ns1", map[string]string{"app": "app1"})
-	s.AddResource(&pod)
-	fileInfoBefore, err := os.Stat(podFilePath)
-	if err != nil {
-		t.Fatalf("os.Stat() before error = %v", err)
-	}
-
-	// Wait less than TimeBetweenFullDump to confirm the ticker does not trigger.
-	time.Sleep(100 * time.Millisecond)
-	fileInfoShortWait, err := os.Stat(podFilePath)
-	if err != nil {
-		t.Fatalf("os.Stat() short wait error = %v", err)
-	}
-	if fileInfoShortWait.ModTime().After(fileInfoBefore.ModTime()) {
-		t.Fatalf("expected file modification time to remain unchanged before full dump interval")
-	}
-
-	// Wait long enough for the ticker to perform another full dump.
-	time.Sleep(600 * time.Millisecond)
-	fileInfoAfter, err := os.Stat(podFilePath)
-	if err != nil {
-		t.Fatalf("os.Stat() after error = %v", err)
-	}
-	if !fileInfoAfter.ModTime().After(fileInfoBefore.ModTime()) {
-		t.Fatalf("expected file modification time to increase after full dump interval")
-	}
-}
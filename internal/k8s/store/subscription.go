@@ -0,0 +1,96 @@
+package store
+
+import (
+	"sync/atomic"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+)
+
+// Subscription receives the changes of a store as they happen
+// Changes are sent without blocking the writers of the store: a subscriber
+// whose buffer is full is dropped and C is closed with Overflowed returning
+// true. The subscriber can then resume with Subscribe from the revision of
+// the last change it received.
+type Subscription struct {
+	C <-chan DeltaRecord
+
+	c          chan DeltaRecord
+	store      *Store
+	overflowed atomic.Bool
+}
+
+// Subscribe returns a subscription to the changes after revision of epoch
+// along with the retained changes the subscriber missed.
+// ok is false, and no subscription is created, when these changes are not
+// available anymore: the subscriber needs to reload the full state, e.g. with
+// GetState, and subscribe from its revision.
+func (k *Store) Subscribe(epoch int64, revision uint64, bufferSize int) (sub *Subscription, backlog []DeltaRecord, ok bool) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	backlog, _, ok = k.deltasSinceLocked(epoch, revision)
+	if !ok {
+		return nil, nil, false
+	}
+	c := make(chan DeltaRecord, bufferSize)
+	sub = &Subscription{C: c, c: c, store: k}
+	k.subscribers[sub] = struct{}{}
+	return sub, backlog, true
+}
+
+// SubscribeCurrent returns a subscription to the changes after the current
+// revision
+func (k *Store) SubscribeCurrent(bufferSize int) (sub *Subscription, revision uint64) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	c := make(chan DeltaRecord, bufferSize)
+	sub = &Subscription{C: c, c: c, store: k}
+	k.subscribers[sub] = struct{}{}
+	return sub, k.revision
+}
+
+// Overflowed returns true if C was closed because the subscriber was too slow
+func (s *Subscription) Overflowed() bool {
+	return s.overflowed.Load()
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.store.mutex.Lock()
+	defer s.store.mutex.Unlock()
+	s.store.removeSubscriberLocked(s)
+}
+
+func (k *Store) removeSubscriberLocked(s *Subscription) {
+	if _, ok := k.subscribers[s]; !ok {
+		return
+	}
+	delete(k.subscribers, s)
+	close(s.c)
+}
+
+// closeSubscribers closes the subscriptions of a stopped store
+func (k *Store) closeSubscribers() {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	for s := range k.subscribers {
+		k.removeSubscriberLocked(s)
+	}
+}
+
+// notifySubscribersLocked sends changes to subscribers, dropping the ones
+// without room left. The caller needs to hold mutex for writing.
+func (k *Store) notifySubscribersLocked(changes []DeltaRecord) {
+	for s := range k.subscribers {
+		for _, change := range changes {
+			select {
+			case s.c <- change:
+				continue
+			default:
+			}
+			log.Debugf("Dropping slow subscriber of %s at revision %d", k.resourceType, change.Revision)
+			s.overflowed.Store(true)
+			k.removeSubscriberLocked(s)
+			break
+		}
+	}
+}
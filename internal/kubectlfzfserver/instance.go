@@ -0,0 +1,80 @@
+package kubectlfzfserver
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/httpserver"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	"github.com/pkg/errors"
+)
+
+// instanceLock is held by the running server: a single server runs per cache
+// root, completions check it before starting one
+type instanceLock struct {
+	lockFile    *os.File
+	pidPath     string
+	releaseOnce sync.Once
+}
+
+func acquireInstanceLock(storeConfig *store.StoreConfig) (*instanceLock, error) {
+	lockPath := storeConfig.GetServerLockPath()
+	lockFile, err := util.TryLock(lockPath)
+	if err == util.ErrLocked {
+		pid, _ := os.ReadFile(storeConfig.GetServerPidPath())
+		return nil, errors.Errorf("another kubectl-fzf-server is running with pid %s, lock %s", pid, lockPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pidPath := storeConfig.GetServerPidPath()
+	err = util.WriteBytesAtomic(pidPath, []byte(strconv.Itoa(os.Getpid())), 0o600)
+	if err != nil {
+		lockFile.Close()
+		return nil, errors.Wrap(err, "error writing pidfile")
+	}
+	l := &instanceLock{lockFile: lockFile, pidPath: pidPath}
+	// Fatal errors exit without running deferred functions
+	log.RegisterExitHandler(l.release)
+	return l, nil
+}
+
+// release removes the pidfile and releases the lock, it's called on exit
+// and on fatal errors
+func (l *instanceLock) release() {
+	l.releaseOnce.Do(func() {
+		if err := os.Remove(l.pidPath); err != nil {
+			log.Warnf("Error removing pidfile %s: %s", l.pidPath, err)
+		}
+		l.lockFile.Close()
+	})
+}
+
+// idleTracker tells when the server was last used, either by a completion
+// reading its files or by a request of its http server
+type idleTracker struct {
+	start        time.Time
+	lastUsedPath string
+	httpServer   *httpserver.FzfHttpServer
+}
+
+func (t *idleTracker) lastActivity() time.Time {
+	last := t.start
+	if finfo, err := os.Stat(t.lastUsedPath); err == nil && finfo.ModTime().After(last) {
+		last = finfo.ModTime()
+	}
+	if t.httpServer != nil && t.httpServer.LastHit().After(last) {
+		last = t.httpServer.LastHit()
+	}
+	return last
+}
+
+// isIdle returns true if the server was not used for timeout
+func (t *idleTracker) isIdle(timeout time.Duration) bool {
+	return timeout > 0 && time.Since(t.lastActivity()) >= timeout
+}
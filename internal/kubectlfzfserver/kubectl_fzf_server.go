@@ -7,11 +7,9 @@ import (
 	"syscall"
 	"time"
 
-	"net/http"
-	_ "net/http/pprof"
-
 	"github.com/codeactual/kubectl-fzf/v4/internal/httpserver"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/apiready"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resourcewatcher"
 	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
@@ -62,6 +60,20 @@ func handleSignals(cancel context.CancelFunc) {
 	}
 }
 
+// touchHeartbeat tells completions reading the files of the server that it's
+// running and that they are up to date
+func touchHeartbeat(storeConfig *store.StoreConfig) {
+	heartbeatPath := storeConfig.GetHeartbeatPath()
+	now := time.Now()
+	err := os.Chtimes(heartbeatPath, now, now)
+	if os.IsNotExist(err) {
+		err = os.WriteFile(heartbeatPath, nil, 0o600)
+	}
+	if err != nil {
+		log.Warnf("Error touching heartbeat file %s: %s", heartbeatPath, err)
+	}
+}
+
 func StartKubectlFzfServer(cfg *configstore.Store) {
 	ctx, cancel := context.WithCancel(context.Background())
 	go handleSignals(cancel)
@@ -76,6 +88,12 @@ func StartKubectlFzfServer(cfg *configstore.Store) {
 	if err != nil {
 		log.Fatalf("error creating destination dir: %s", err)
 	}
+	lock, err := acquireInstanceLock(storeConfig)
+	if err != nil {
+		log.Fatalf("Couldn't start server: %s", err)
+	}
+	defer lock.release()
+	serverCli := NewKubectlFzfServerCli(cfg)
 
 	// Ride out the boot-time RBAC-bootstrap race before any cluster reads: at
 	// system boot the apiserver rejects the kubernetes-admin identity with
@@ -100,18 +118,25 @@ func StartKubectlFzfServer(cfg *configstore.Store) {
 	resourceWatcherCli := resourcewatcher.NewResourceWatcherCli(cfg)
 	watcher, stores, err := startWatchOnCluster(ctx, resourceWatcherCli, storeConfig)
 	util.FatalIf(err)
-	ticker := time.NewTicker(time.Second * 5)
+	touchHeartbeat(storeConfig)
+	ticker := time.NewTicker(clusterconfig.HeartbeatInterval)
 
 	httpServerConfCli := httpserver.NewHttpServerConfigCli(cfg)
-	_, err = httpserver.StartHttpServer(ctx, &httpServerConfCli, storeConfig, stores)
+	fzfHttpServer, err := httpserver.StartHttpServer(ctx, &httpServerConfCli, storeConfig, stores)
 	if err != nil {
 		log.Fatalf("Error starting http server: %s", err)
 	}
 
-	go func() {
-		log.Println(http.ListenAndServe("localhost:6060", nil))
-	}()
+	err = httpserver.StartPprofServer(ctx, httpServerConfCli.HttpProfAddress)
+	if err != nil {
+		log.Fatalf("Error starting pprof server: %s", err)
+	}
 
+	idle := &idleTracker{
+		start:        time.Now(),
+		lastUsedPath: storeConfig.GetLastUsedPath(),
+		httpServer:   fzfHttpServer,
+	}
 	currentContext := storeConfig.GetContext()
 	for {
 		select {
@@ -119,21 +144,32 @@ func StartKubectlFzfServer(cfg *configstore.Store) {
 			log.Info("Context done, exiting")
 			return
 		case <-ticker.C:
+			if idle.isIdle(serverCli.IdleTimeout) {
+				log.Infof("No completion since %s, exiting", idle.lastActivity().Format(time.RFC3339))
+				cancel()
+				continue
+			}
 			err = storeConfig.LoadClusterConfig()
 			util.FatalIf(err)
 			newContext := storeConfig.GetContext()
 			log.Debugf("Checking config %s %s ", currentContext, newContext)
 			if newContext != currentContext {
 				log.Infof("Detected context change %s != %s", newContext, currentContext)
+				contextSwitchesCounter.Inc()
 				watcher.Stop()
 				err = storeConfig.CreateDestDir()
 				if err != nil {
 					log.Fatalf("error creating destination dir: %s", err)
 				}
-				watcher, _, err = startWatchOnCluster(ctx, resourceWatcherCli, storeConfig)
+				watcher, stores, err = startWatchOnCluster(ctx, resourceWatcherCli, storeConfig)
 				util.FatalIf(err)
+				if fzfHttpServer != nil {
+					err = fzfHttpServer.SetStores(stores)
+					util.FatalIf(err)
+				}
 				currentContext = newContext
 			}
+			touchHeartbeat(storeConfig)
 		}
 	}
 }
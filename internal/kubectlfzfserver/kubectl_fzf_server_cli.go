@@ -0,0 +1,24 @@
+package kubectlfzfserver
+
+import (
+	"flag"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/util/config"
+)
+
+type KubectlFzfServerCli struct {
+	// IdleTimeout is the duration without completion after which the server
+	// exits, disabled when 0
+	IdleTimeout time.Duration
+}
+
+func SetKubectlFzfServerCli(fs *flag.FlagSet) {
+	fs.Duration("idle-timeout", 0, "Exit after this duration without completion, disabled when 0. Used by servers started by kubectl-fzf-completion.")
+}
+
+func NewKubectlFzfServerCli(store *config.Store) KubectlFzfServerCli {
+	return KubectlFzfServerCli{
+		IdleTimeout: store.GetDuration("idle-timeout", 0),
+	}
+}
@@ -0,0 +1,11 @@
+package kubectlfzfserver
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var contextSwitchesCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "kubectl_fzf_context_switches_total",
+	Help: "Changes of the watched kubernetes context",
+})
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 )
 
@@ -109,14 +110,37 @@ func Error(args ...interface{}) {
 	logln(ErrorLevel, "[ERROR]", args...)
 }
 
+var (
+	exitHandlersMutex sync.Mutex
+	exitHandlers      []func()
+)
+
+// RegisterExitHandler adds a function called by Fatal and Fatalf before
+// exiting, deferred functions don't run on os.Exit
+func RegisterExitHandler(handler func()) {
+	exitHandlersMutex.Lock()
+	defer exitHandlersMutex.Unlock()
+	exitHandlers = append(exitHandlers, handler)
+}
+
+func exit() {
+	exitHandlersMutex.Lock()
+	handlers := exitHandlers
+	exitHandlersMutex.Unlock()
+	for _, handler := range handlers {
+		handler()
+	}
+	os.Exit(1)
+}
+
 func Fatalf(format string, args ...interface{}) {
 	logf(FatalLevel, "[FATAL]", format, args...)
-	os.Exit(1)
+	exit()
 }
 
 func Fatal(args ...interface{}) {
 	logln(FatalLevel, "[FATAL]", args...)
-	os.Exit(1)
+	exit()
 }
 
 func Println(args ...interface{}) {
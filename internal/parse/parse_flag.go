@@ -24,49 +24,58 @@ func (f FlagCompletion) String() string {
 	return flagStr[f]
 }
 
+// valueFlags are the kubectl flags followed by a separate value, with the
+// completion of that value. FlagNone leaves the resource completion as is.
+var valueFlags = map[string]FlagCompletion{
+	"-l":                FlagLabel,
+	"--selector":        FlagLabel,
+	"--field-selector":  FlagFieldSelector,
+	"-n":                FlagNamespace,
+	"--namespace":       FlagNamespace,
+	"-f":                FlagUnmanaged,
+	"--filename":        FlagUnmanaged,
+	"-o":                FlagUnmanaged,
+	"--output":          FlagUnmanaged,
+	"-c":                FlagNone,
+	"--container":       FlagNone,
+	"-L":                FlagNone,
+	"--label-columns":   FlagNone,
+	"--sort-by":         FlagNone,
+	"--template":        FlagNone,
+	"--context":         FlagNone,
+	"--cluster":         FlagNone,
+	"--user":            FlagNone,
+	"--kubeconfig":      FlagNone,
+	"-s":                FlagNone,
+	"--server":          FlagNone,
+	"--token":           FlagNone,
+	"--as":              FlagNone,
+	"--as-group":        FlagNone,
+	"--request-timeout": FlagNone,
+}
+
 func parsePreviousFlag(s string) FlagCompletion {
 	log.Debugf("Parsing previous flag '%s'", s)
-	switch s {
-	case "-l":
-		return FlagLabel
-	case "--selector":
-		return FlagLabel
-	case "--field-selector":
-		return FlagFieldSelector
-	case "-n":
-		fallthrough
-	case "--namespace":
-		return FlagNamespace
-
-	case "--filename":
-		fallthrough
-	case "-f":
-		return FlagUnmanaged
-	case "--output":
-		fallthrough
-	case "-o":
-		return FlagUnmanaged
+	if flagCompletion, ok := valueFlags[s]; ok {
+		return flagCompletion
 	}
 	return FlagNone
 }
 
 func parseLastFlag(s string) FlagCompletion {
 	log.Debugf("Parsing last flag '%s'", s)
-	switch s {
-	case "-l":
-		fallthrough
-	case "-l=":
-		fallthrough
-	case "--selector=":
-		return FlagLabel
-	case "-n":
-		fallthrough
-	case "-n=":
-		fallthrough
-	case "--namespace=":
-		return FlagNamespace
-	case "--field-selector=":
-		return FlagFieldSelector
+	// Long flags are only complete with their '=', e.g. --selector=
+	name := strings.TrimSuffix(s, "=")
+	if name == s && strings.HasPrefix(s, "--") {
+		return FlagUnmanaged
+	}
+	flagCompletion, ok := valueFlags[name]
+	if !ok {
+		return FlagUnmanaged
+	}
+	switch flagCompletion {
+	case FlagLabel, FlagNamespace, FlagFieldSelector:
+		return flagCompletion
 	}
 	return FlagUnmanaged
 }
@@ -93,3 +102,10 @@ func CheckFlagManaged(args []string) FlagCompletion {
 	}
 	return FlagNone
 }
+
+// FlagTakesValue returns true when arg is a flag whose value is the next
+// argument, e.g. -n in -n kube-system
+func FlagTakesValue(arg string) bool {
+	_, ok := valueFlags[arg]
+	return ok
+}
@@ -8,6 +8,8 @@ func TestUnmanagedArgs(t *testing.T) {
 		{"-i"},
 		{"--field-selector"},
 		{"--selector"},
+		{"-o"},
+		{"-c"},
 	}
 	for _, args := range cmdArgs {
 		r := CheckFlagManaged(args)
@@ -36,6 +38,10 @@ func TestManagedArgs(t *testing.T) {
 		{[]string{"-n="}, FlagNamespace},
 		{[]string{"-n", " "}, FlagNamespace},
 		{[]string{"--namespace", ""}, FlagNamespace},
+		{[]string{"-l"}, FlagLabel},
+		{[]string{"-l="}, FlagLabel},
+		{[]string{"-o", ""}, FlagUnmanaged},
+		{[]string{"--context", ""}, FlagNone},
 	}
 	for _, args := range cmdArgs {
 		r := CheckFlagManaged(args.flag)
@@ -44,3 +50,16 @@ func TestManagedArgs(t *testing.T) {
 		}
 	}
 }
+
+func TestFlagTakesValue(t *testing.T) {
+	for _, arg := range []string{"-n", "--namespace", "-l", "--field-selector", "-o", "-c", "--context"} {
+		if !FlagTakesValue(arg) {
+			t.Errorf("FlagTakesValue(%q) = false, want true", arg)
+		}
+	}
+	for _, arg := range []string{"-A", "--all-namespaces", "-it", "--selector=app=web", "pods"} {
+		if FlagTakesValue(arg) {
+			t.Errorf("FlagTakesValue(%q) = true, want false", arg)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+)
+
+// Mode is what the preview shows of the highlighted line
+type Mode string
+
+const (
+	// ModeColumns shows the columns of the line, from the completion data
+	ModeColumns Mode = "columns"
+	// ModeYaml shows the resource fetched from the cluster
+	ModeYaml Mode = "yaml"
+	// ModeLogs shows the last log lines of the containers of a pod
+	ModeLogs Mode = "logs"
+	// ModeEvents shows the recent events of the resource
+	ModeEvents Mode = "events"
+)
+
+// Modes are the preview modes, in the order of their key bindings
+var Modes = []Mode{ModeColumns, ModeYaml, ModeLogs, ModeEvents}
+
+type UnknownModeError string
+
+func (u UnknownModeError) Error() string {
+	return fmt.Sprintf("unknown preview mode %q", string(u))
+}
+
+func ParseMode(s string) (Mode, error) {
+	if s == "" {
+		return ModeColumns, nil
+	}
+	for _, mode := range Modes {
+		if string(mode) == s {
+			return mode, nil
+		}
+	}
+	return "", UnknownModeError(s)
+}
+
+// Previewer renders the preview modes of a completion
+// The live modes query the cluster of the completion with the timeout of
+// PreviewCli. Their output is cached in sessionDir, which lives as long as the
+// fzf session, so moving back to a line doesn't query the cluster again.
+type Previewer struct {
+	fetcher    *fetcher.Fetcher
+	columns    *resources.Columns
+	previewCli PreviewCli
+	sessionDir string
+	width      int
+
+	clientset     kubernetes.Interface
+	dynamicClient dynamic.Interface
+}
+
+// NewPreviewer creates a previewer of the lines with the selected columns, f
+// can be nil when the cluster configuration couldn't be loaded and sessionDir
+// empty to disable the cache
+func NewPreviewer(f *fetcher.Fetcher, columns *resources.Columns, previewCli PreviewCli, sessionDir string, width int) *Previewer {
+	return &Previewer{
+		fetcher:    f,
+		columns:    columns,
+		previewCli: previewCli,
+		sessionDir: sessionDir,
+		width:      width,
+	}
+}
+
+// Render renders the preview of a completion line
+// Errors are part of the preview: fzf shows whatever the command prints.
+func (p *Previewer) Render(ctx context.Context, mode Mode, header string, resourceType resources.ResourceType, line string) string {
+	if mode == ModeColumns {
+		return RenderLine(ctx, p.fetcher, p.columns, header, resourceType, line, p.width)
+	}
+	if resourceType == resources.ResourceTypeUnknown || resourceType == resources.ResourceTypeApiResource {
+		return fmt.Sprintf("No %s preview for this completion\n", mode)
+	}
+	if mode == ModeLogs && resourceType != resources.ResourceTypePod {
+		return "Logs are only available for pods\n"
+	}
+	columns := strings.Fields(header)
+	values := splitLine(columns, line)
+	nameIdx := columnIndex(columns, "name")
+	if nameIdx < 0 || nameIdx >= len(values) {
+		return "No resource name on this line\n"
+	}
+	name := values[nameIdx]
+	namespace := p.getNamespace(resourceType, columns, values)
+
+	cachePath := p.getCachePath(mode, resourceType, namespace, name)
+	if cachePath != "" {
+		if b, err := os.ReadFile(cachePath); err == nil {
+			return string(b)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.previewCli.Timeout)
+	defer cancel()
+	var res string
+	var err error
+	switch mode {
+	case ModeYaml:
+		res, err = p.renderYaml(ctx, resourceType, namespace, name)
+	case ModeLogs:
+		res, err = p.renderLogs(ctx, namespace, name)
+	case ModeEvents:
+		res, err = p.renderEvents(ctx, resourceType, namespace, name)
+	default:
+		err = UnknownModeError(mode)
+	}
+	if err != nil {
+		// Errors are not cached, the next preview of the line retries
+		return fmt.Sprintf("Error getting %s of %s %s: %s\n", mode, resourceType, name, err)
+	}
+	if cachePath != "" {
+		if err := os.WriteFile(cachePath, []byte(res), 0600); err != nil {
+			log.Debugf("Couldn't cache preview in %s: %s", cachePath, err)
+		}
+	}
+	return res
+}
+
+// getNamespace returns the namespace of the line, the namespace of the
+// current context when namespaced resources are completed without it
+func (p *Previewer) getNamespace(resourceType resources.ResourceType, columns []string, values []string) string {
+	if !resourceType.IsNamespaced() {
+		return ""
+	}
+	if namespaceIdx := columnIndex(columns, "namespace"); namespaceIdx >= 0 && namespaceIdx < len(values) {
+		return values[namespaceIdx]
+	}
+	if p.fetcher != nil {
+		if namespace, err := p.fetcher.GetNamespace(); err == nil && namespace != "" {
+			return namespace
+		}
+	}
+	return "default"
+}
+
+func (p *Previewer) getCachePath(mode Mode, resourceType resources.ResourceType, namespace string, name string) string {
+	if p.sessionDir == "" {
+		return ""
+	}
+	return path.Join(p.sessionDir, fmt.Sprintf("%s_%s_%s", mode, resourceType, store.Key(namespace, name)))
+}
+
+// getClients creates the clients of the cluster on first use
+func (p *Previewer) getClients() (kubernetes.Interface, dynamic.Interface, error) {
+	if p.clientset != nil && p.dynamicClient != nil {
+		return p.clientset, p.dynamicClient, nil
+	}
+	if p.fetcher == nil {
+		return nil, nil, errors.New("cluster configuration couldn't be loaded")
+	}
+	restConfig, err := p.fetcher.GetClientConfig()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error getting client config")
+	}
+	restConfig.Timeout = p.previewCli.Timeout
+	p.clientset, err = kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.dynamicClient, err = dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.clientset, p.dynamicClient, nil
+}
+
+func (p *Previewer) renderYaml(ctx context.Context, resourceType resources.ResourceType, namespace string, name string) (string, error) {
+	_, dynamicClient, err := p.getClients()
+	if err != nil {
+		return "", err
+	}
+	namespaceableClient := dynamicClient.Resource(resourceType.GroupVersionResource())
+	var resourceClient dynamic.ResourceInterface = namespaceableClient
+	if namespace != "" {
+		resourceClient = namespaceableClient.Namespace(namespace)
+	}
+	obj, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+	if resourceType == resources.ResourceTypeSecret {
+		redactSecret(obj.Object)
+	}
+	b, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// lastAppliedAnnotation holds the manifest applied by kubectl, values
+// included
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// redactSecret hides the values of a secret, the preview is shown on screen
+func redactSecret(obj map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := obj[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k := range values {
+			values[k] = "<redacted>"
+		}
+	}
+	annotations, _, _ := unstructured.NestedMap(obj, "metadata", "annotations")
+	if _, ok := annotations[lastAppliedAnnotation]; ok {
+		annotations[lastAppliedAnnotation] = "<redacted>"
+		_ = unstructured.SetNestedMap(obj, annotations, "metadata", "annotations")
+	}
+}
+
+func (p *Previewer) renderLogs(ctx context.Context, namespace string, name string) (string, error) {
+	clientset, _, err := p.getClients()
+	if err != nil {
+		return "", err
+	}
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	b := new(strings.Builder)
+	for _, container := range pod.Spec.Containers {
+		tailLines := p.previewCli.LogLines
+		logOptions := &corev1.PodLogOptions{Container: container.Name, TailLines: &tailLines}
+		logs, err := clientset.CoreV1().Pods(namespace).GetLogs(name, logOptions).DoRaw(ctx)
+		if len(pod.Spec.Containers) > 1 {
+			fmt.Fprintf(b, "==> %s <==\n", container.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(b, "Error getting logs: %s\n", err)
+			continue
+		}
+		b.Write(logs)
+		if len(logs) > 0 && logs[len(logs)-1] != '\n' {
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), nil
+}
+
+func eventTime(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+func (p *Previewer) renderEvents(ctx context.Context, resourceType resources.ResourceType, namespace string, name string) (string, error) {
+	clientset, _, err := p.getClients()
+	if err != nil {
+		return "", err
+	}
+	fieldSelector := fields.AndSelectors(
+		fields.OneTermEqualSelector("involvedObject.name", name),
+		fields.OneTermEqualSelector("involvedObject.kind", resourceType.Kind()),
+	)
+	eventList, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: fieldSelector.String()})
+	if err != nil {
+		return "", err
+	}
+	events := make([]corev1.Event, 0, len(eventList.Items))
+	for _, e := range eventList.Items {
+		if e.InvolvedObject.Name == name && e.InvolvedObject.Kind == resourceType.Kind() {
+			events = append(events, e)
+		}
+	}
+	if len(events) == 0 {
+		return "No events found\n", nil
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	if limit := p.previewCli.EventLimit; limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	b := new(strings.Builder)
+	w := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LAST SEEN\tTYPE\tREASON\tMESSAGE")
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", util.TimeToAge(eventTime(e)), e.Type, e.Reason, strings.TrimSpace(e.Message))
+	}
+	w.Flush()
+	return b.String(), nil
+}
@@ -0,0 +1,116 @@
+package preview
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	corefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestPreviewer(t *testing.T, objects ...runtime.Object) *Previewer {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	p := NewPreviewer(nil, nil, PreviewCli{Timeout: time.Second, LogLines: 10, EventLimit: 1}, t.TempDir(), DefaultWidth)
+	p.clientset = corefake.NewSimpleClientset(objects...)
+	p.dynamicClient = dynamicfake.NewSimpleDynamicClient(scheme, objects...)
+	return p
+}
+
+func TestLivePreview(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "ns1",
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "nginx"}, {Name: "exporter"}}},
+	}
+	now := time.Now()
+	events := []runtime.Object{
+		&corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "e1", Namespace: "ns1"}, Reason: "Scheduled", Type: "Normal",
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "web-0"}, LastTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+		&corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "e2", Namespace: "ns1"}, Reason: "BackOff", Type: "Warning",
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "web-0"}, LastTimestamp: metav1.NewTime(now)},
+		&corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "e3", Namespace: "ns1"}, Reason: "ScalingReplicaSet", Type: "Normal",
+			InvolvedObject: corev1.ObjectReference{Kind: "Deployment", Name: "web-0"}, LastTimestamp: metav1.NewTime(now)},
+	}
+	p := newTestPreviewer(t, append(events, pod)...)
+	ctx := context.Background()
+	header := "Namespace Name Age"
+	line := "ns1  web-0  10m"
+
+	res := p.Render(ctx, ModeYaml, header, resources.ResourceTypePod, line)
+	if !strings.Contains(res, "name: web-0") || strings.Contains(res, "managedFields") {
+		t.Fatalf("unexpected yaml preview %s", res)
+	}
+
+	res = p.Render(ctx, ModeLogs, header, resources.ResourceTypePod, line)
+	if !strings.Contains(res, "==> nginx <==\nfake logs\n==> exporter <==\nfake logs\n") {
+		t.Fatalf("unexpected logs preview %s", res)
+	}
+
+	res = p.Render(ctx, ModeEvents, header, resources.ResourceTypePod, line)
+	if !strings.Contains(res, "BackOff") || strings.Contains(res, "Scheduled") || strings.Contains(res, "ScalingReplicaSet") {
+		t.Fatalf("expected the last event of the pod, got %s", res)
+	}
+
+	// Previews are served from the session cache
+	if err := p.clientset.CoreV1().Pods("ns1").Delete(ctx, "web-0", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if cached := p.Render(ctx, ModeLogs, header, resources.ResourceTypePod, line); !strings.Contains(cached, "fake logs") {
+		t.Fatalf("expected cached logs, got %s", cached)
+	}
+	res = p.Render(ctx, ModeLogs, header, resources.ResourceTypePod, "ns1  web-1  10m")
+	if !strings.HasPrefix(res, "Error getting logs of pods web-1") {
+		t.Fatalf("expected an error, got %s", res)
+	}
+	entries, err := os.ReadDir(p.sessionDir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 cached previews, got %d", len(entries))
+	}
+
+	res = p.Render(ctx, ModeLogs, "Name Age", resources.ResourceTypeNode, "node1  10m")
+	if res != "Logs are only available for pods\n" {
+		t.Fatalf("unexpected logs preview of a node %s", res)
+	}
+}
+
+func TestSecretYamlPreview(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns1", Annotations: map[string]string{
+			lastAppliedAnnotation: `{"apiVersion":"v1","kind":"Secret","stringData":{"password":"hunter2"}}`,
+			"team":                "payment",
+		}},
+		Data: map[string][]byte{"password": []byte("hunter2")},
+	}
+	p := newTestPreviewer(t, secret)
+
+	res := p.Render(context.Background(), ModeYaml, "Namespace Name Age", resources.ResourceTypeSecret, "ns1  db  10m")
+	if !strings.Contains(res, "password: <redacted>") || !strings.Contains(res, "team: payment") {
+		t.Fatalf("unexpected secret preview %s", res)
+	}
+	if strings.Contains(res, "hunter2") || strings.Contains(res, "aHVudGVyMg") {
+		t.Fatalf("secret values leaked in preview %s", res)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	if mode, err := ParseMode(""); err != nil || mode != ModeColumns {
+		t.Fatalf("ParseMode() = %s, %v", mode, err)
+	}
+	if _, err := ParseMode("describe"); err == nil {
+		t.Fatalf("expected an error on unknown mode")
+	}
+}
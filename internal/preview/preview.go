@@ -0,0 +1,126 @@
+// Package preview renders the fzf preview of the highlighted completion line
+package preview
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+)
+
+// DefaultWidth is used when fzf doesn't give the width of the preview window
+const DefaultWidth = 80
+
+// minValueWidth keeps values readable in narrow preview windows
+const minValueWidth = 20
+
+// RenderLine renders the columns of a completion line as key/value pairs
+// The values come from the resource when it's found, as the completion line
+// can have truncated values, columns being the ones selected for the
+// completion.
+func RenderLine(ctx context.Context, f *fetcher.Fetcher, selectedColumns *resources.Columns, header string,
+	resourceType resources.ResourceType, line string, width int) string {
+	columns := strings.Fields(header)
+	values := splitLine(columns, line)
+	if resource := lookupResource(ctx, f, resourceType, columns, values); resource != nil {
+		values = strings.Split(selectedColumns.FullStrings(resourceType, resource)[0], "\t")
+	}
+	return RenderColumns(columns, values, width)
+}
+
+// splitLine splits a completion line aligned with spaces
+// Columns never hold spaces but the last one can: extra fields are part of
+// the last column.
+func splitLine(columns []string, line string) []string {
+	fields := strings.Fields(line)
+	if len(columns) == 0 || len(fields) <= len(columns) {
+		return fields
+	}
+	last := len(columns) - 1
+	return append(fields[:last], strings.Join(fields[last:], " "))
+}
+
+func columnIndex(columns []string, name string) int {
+	for i, column := range columns {
+		if strings.EqualFold(column, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookupResource returns the resource of the line, nil when it can't be found
+func lookupResource(ctx context.Context, f *fetcher.Fetcher, resourceType resources.ResourceType,
+	columns []string, values []string) resources.K8sResource {
+	if f == nil || resourceType == resources.ResourceTypeUnknown {
+		return nil
+	}
+	nameIdx := columnIndex(columns, "name")
+	if nameIdx < 0 || nameIdx >= len(values) {
+		return nil
+	}
+	namespace := ""
+	if namespaceIdx := columnIndex(columns, "namespace"); namespaceIdx >= 0 && namespaceIdx < len(values) {
+		namespace = values[namespaceIdx]
+	}
+	resourceMap, err := f.GetResources(ctx, resourceType)
+	if err != nil {
+		log.Debugf("Couldn't get %s for preview: %s", resourceType, err)
+		return nil
+	}
+	return resourceMap[store.Key(namespace, values[nameIdx])]
+}
+
+// RenderColumns renders aligned key/value pairs, values are wrapped to fit
+// in width
+func RenderColumns(columns []string, values []string, width int) string {
+	keyWidth := 0
+	for _, column := range columns {
+		if len(column) > keyWidth {
+			keyWidth = len(column)
+		}
+	}
+	valueWidth := width - keyWidth - 2
+	if valueWidth < minValueWidth {
+		valueWidth = minValueWidth
+	}
+	b := new(strings.Builder)
+	for i, column := range columns {
+		value := "None"
+		if i < len(values) {
+			value = values[i]
+		}
+		for j, valueLine := range wrap(value, valueWidth) {
+			key := ""
+			if j == 0 {
+				key = column + ":"
+			}
+			fmt.Fprintf(b, "%-*s %s\n", keyWidth+1, key, valueLine)
+		}
+	}
+	return b.String()
+}
+
+// wrap splits s in lines of at most width runes, breaking after the last comma
+// or space of the line when there's one
+func wrap(s string, width int) []string {
+	runes := []rune(s)
+	lines := []string{}
+	for len(runes) > width {
+		cut := width
+		for i := width; i > 0; i-- {
+			if runes[i-1] == ',' || runes[i-1] == ' ' {
+				cut = i
+				break
+			}
+		}
+		lines = append(lines, strings.TrimRight(string(runes[:cut]), " "))
+		runes = runes[cut:]
+	}
+	return append(lines, string(runes))
+}
@@ -0,0 +1,27 @@
+package preview
+
+import (
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/util/config"
+)
+
+// PreviewCli configures the live preview modes
+// Like the completion settings, they're read from the configuration file and
+// environment.
+type PreviewCli struct {
+	// Timeout bounds each request to the cluster
+	Timeout time.Duration
+	// LogLines is the number of log lines shown per container
+	LogLines int64
+	// EventLimit is the number of most recent events shown
+	EventLimit int
+}
+
+func NewPreviewCli(store *config.Store) PreviewCli {
+	return PreviewCli{
+		Timeout:    store.GetDuration("preview-timeout", 5*time.Second),
+		LogLines:   int64(store.GetInt("preview-log-lines", 50)),
+		EventLimit: store.GetInt("preview-event-limit", 20),
+	}
+}
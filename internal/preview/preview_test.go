@@ -0,0 +1,67 @@
+package preview
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeactual/kubectl-fzf/v4/internal/fetcher"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/clusterconfig"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/resources"
+	"github.com/codeactual/kubectl-fzf/v4/internal/k8s/store"
+	"github.com/codeactual/kubectl-fzf/v4/internal/util"
+)
+
+func TestRenderColumns(t *testing.T) {
+	columns := []string{"Namespace", "Name", "Containers", "Labels"}
+	values := splitLine(columns, "  default   web-0   nginx,sidecar-with-a-long-name,exporter   app=web,quote='x' 100%  ")
+	res := RenderColumns(columns, values, 40)
+	expected := `Namespace:  default
+Name:       web-0
+Containers: nginx,
+            sidecar-with-a-long-name,
+            exporter
+Labels:     app=web,quote='x' 100%
+`
+	if res != expected {
+		t.Fatalf("RenderColumns() = %q, want %q", res, expected)
+	}
+}
+
+func TestRenderLineWithFullValues(t *testing.T) {
+	cacheDir := t.TempDir()
+	containers := make([]string, 0, 40)
+	for i := 0; i < 40; i++ {
+		containers = append(containers, strings.Repeat("c", 10))
+	}
+	pod := &resources.Pod{
+		ResourceMeta: resources.ResourceMeta{Name: "web-0", Namespace: "default", CreationTime: time.Now()},
+		Containers:   containers,
+	}
+	data := map[string]resources.K8sResource{store.Key("default", "web-0"): pod}
+	header := store.NewCacheHeader("test", resources.ResourceTypePod, len(data))
+	if err := os.MkdirAll(path.Join(cacheDir, "test"), 0o700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := util.EncodeToFile(data, header, path.Join(cacheDir, "test", "pods")); err != nil {
+		t.Fatalf("EncodeToFile() error = %v", err)
+	}
+	f := fetcher.NewFetcher(&fetcher.FetcherCli{
+		FetcherCachePath: t.TempDir(),
+		ClusterConfigCli: &clusterconfig.ClusterConfigCli{ClusterName: "test", CacheDir: cacheDir},
+	})
+
+	line := pod.ToStrings()[0]
+	if strings.Contains(line, strings.Join(containers, ",")) {
+		t.Fatalf("expected truncated containers in the completion line")
+	}
+	columnHeader := strings.ReplaceAll(resources.ResourceToHeader(resources.ResourceTypePod), "\t", " ")
+	res := RenderLine(context.Background(), f, nil, columnHeader, resources.ResourceTypePod,
+		strings.ReplaceAll(line, "\t", "  "), 1000)
+	if !strings.Contains(res, " "+strings.Join(containers, ",")+"\n") {
+		t.Fatalf("expected full containers in preview, got %s", res)
+	}
+}
@@ -73,6 +73,8 @@ func processResultWithNamespace(cmdUse string, cmdArgs []string, fzfResult strin
 	// 0 -> name, 1 -> age
 	// Otherwise:
 	// 0 -> namespace, 1 -> value
+	// Colour codes of the status column would end up in the fields
+	fzfResult = util.StripColors(fzfResult)
 	resultFields := strings.Fields(fzfResult)
 	if len(resultFields) < 2 {
 		return "", fmt.Errorf("fzf result should have at least 3 elements, got %v", resultFields)
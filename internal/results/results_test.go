@@ -69,6 +69,9 @@ func TestResult(t *testing.T) {
 		{"kube-system coredns-64897985d-nrblm", "get", []string{"pods", "c"}, "default", "coredns-64897985d-nrblm -n kube-system"},
 		{"apiservices.apiregistration.k8s.io None apiregistration.k8s.io/v1", "get", []string{" "}, "default", "apiservices.apiregistration.k8s.io"},
 		{"kfzf kubectl-fzf-788969b7cb-vf85b", "exec", []string{"--", " "}, "default", "kubectl-fzf-788969b7cb-vf85b"},
+		// Colored status column
+		{"\x1b[32mminikube\x1b[0m control-plane Ready", "get", []string{"nodes", " "}, "default", "minikube"},
+		{"kfzf kubectl-fzf-788969b7cb-vf85b 10.0.0.1 \x1b[31mCrashLoopBackOff\x1b[0m", "exec", []string{"-ti", ""}, "default", "kubectl-fzf-788969b7cb-vf85b -n kfzf"},
 	}
 	for _, testData := range testDatas {
 		res, err := processResultWithNamespace(testData.cmdUse, testData.cmdArgs, testData.fzfResult, testData.currentNamespace)
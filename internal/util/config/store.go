@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -52,9 +53,19 @@ func (s *Store) mergeConfigData(data []byte) error {
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
 	}
+	return s.mergeConfigValues("", raw)
+}
+
+// mergeConfigValues stores the values of raw, the keys of nested objects are
+// joined with a dot, e.g. verbs.delete.fzf-auto-select
+func (s *Store) mergeConfigValues(prefix string, raw map[string]any) error {
 	for key, value := range raw {
-		lowerKey := strings.ToLower(key)
+		lowerKey := prefix + strings.ToLower(key)
 		switch typed := value.(type) {
+		case map[string]any:
+			if err := s.mergeConfigValues(lowerKey+".", typed); err != nil {
+				return err
+			}
 		case string:
 			s.values[lowerKey] = typed
 			delete(s.stringSlices, lowerKey)
@@ -83,13 +94,13 @@ func (s *Store) mergeConfigData(data []byte) error {
 						slice = append(slice, "false")
 					}
 				default:
-					return fmt.Errorf("unsupported slice value for key %s", key)
+					return fmt.Errorf("unsupported slice value for key %s", lowerKey)
 				}
 			}
 			s.stringSlices[lowerKey] = slice
 			delete(s.values, lowerKey)
 		default:
-			return fmt.Errorf("unsupported value type for key %s", key)
+			return fmt.Errorf("unsupported value type for key %s", lowerKey)
 		}
 	}
 	return nil
@@ -172,6 +183,18 @@ func (s *Store) GetBool(key string, defaultValue bool) bool {
 	}
 }
 
+func (s *Store) GetInt(key string, defaultValue int) int {
+	value, ok := s.values[strings.ToLower(key)]
+	if !ok || value == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return i
+}
+
 func (s *Store) GetDuration(key string, defaultValue time.Duration) time.Duration {
 	value, ok := s.values[strings.ToLower(key)]
 	if !ok || value == "" {
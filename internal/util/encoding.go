@@ -3,58 +3,154 @@ package util
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/binary"
 	"encoding/gob"
+	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
+	"time"
 
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
 	"github.com/pkg/errors"
 )
 
-func EncodeToFile(data interface{}, filePath string) error {
-	log.Debugf("Writing encoded data in %s", filePath)
+// CacheMagic starts every cache file
+const CacheMagic = "KFZF"
+
+// CacheFormatVersion is bumped when the layout of the cache file changes
+const CacheFormatVersion uint16 = 1
+
+// maxCacheHeaderLen bounds the header length read from a file, a corrupted
+// length would otherwise allocate up to 4GiB
+const maxCacheHeaderLen = 64 * 1024
+
+// CacheHeader describes the payload of a cache file.
+// It is written uncompressed in front of the gzipped gob payload:
+// magic, format version (uint16), header length (uint32), gob header, payload.
+type CacheHeader struct {
+	ResourceType string
+	Cluster      string
+	// Schema is a fingerprint of the payload types, a mismatch means the
+	// reader's structs differ from the writer's
+	Schema    string
+	WrittenAt time.Time
+	ItemCount int
+	// Epoch identifies the writer's lifetime, revisions are only comparable
+	// within the same epoch
+	Epoch int64
+	// Revision is the last change included in the payload
+	Revision uint64
+}
+
+// IncompatibleCacheError is returned when a cache file was written by a
+// binary using a different format or schema
+type IncompatibleCacheError struct {
+	Reason string
+}
 
+func (e IncompatibleCacheError) Error() string {
+	return fmt.Sprintf("cache written by incompatible version, waiting for server to rewrite: %s", e.Reason)
+}
+
+// WriteCacheHeader writes the magic, format version and header
+func WriteCacheHeader(w io.Writer, header CacheHeader) error {
+	var headerBuf bytes.Buffer
+	if err := gob.NewEncoder(&headerBuf).Encode(header); err != nil {
+		return errors.Wrap(err, "error encoding cache header")
+	}
+	prefix := make([]byte, len(CacheMagic)+2+4)
+	copy(prefix, CacheMagic)
+	binary.BigEndian.PutUint16(prefix[len(CacheMagic):], CacheFormatVersion)
+	binary.BigEndian.PutUint32(prefix[len(CacheMagic)+2:], uint32(headerBuf.Len()))
+	if _, err := w.Write(prefix); err != nil {
+		return errors.Wrap(err, "error writing cache header")
+	}
+	_, err := w.Write(headerBuf.Bytes())
+	return errors.Wrap(err, "error writing cache header")
+}
+
+// ReadCacheHeader reads the header of a cache file, leaving r at the start of
+// the payload
+func ReadCacheHeader(r io.Reader) (CacheHeader, error) {
+	var header CacheHeader
+	prefix := make([]byte, len(CacheMagic)+2+4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return header, IncompatibleCacheError{"missing header"}
+	}
+	if string(prefix[:len(CacheMagic)]) != CacheMagic {
+		return header, IncompatibleCacheError{"missing header"}
+	}
+	version := binary.BigEndian.Uint16(prefix[len(CacheMagic):])
+	if version != CacheFormatVersion {
+		return header, IncompatibleCacheError{fmt.Sprintf("format version %d, expected %d", version, CacheFormatVersion)}
+	}
+	headerLen := binary.BigEndian.Uint32(prefix[len(CacheMagic)+2:])
+	if headerLen > maxCacheHeaderLen {
+		return header, fmt.Errorf("cache header of %d bytes exceeds %d bytes", headerLen, maxCacheHeaderLen)
+	}
+	headerBytes := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, headerBytes); err != nil {
+		return header, errors.Wrap(err, "error reading cache header")
+	}
+	if err := gob.NewDecoder(bytes.NewReader(headerBytes)).Decode(&header); err != nil {
+		return header, errors.Wrap(err, "error decoding cache header")
+	}
+	return header, nil
+}
+
+// Encode writes the header followed by the gzipped gob of data
+func Encode(w io.Writer, data interface{}, header CacheHeader) error {
 	var gobBuf bytes.Buffer
 	enc := gob.NewEncoder(&gobBuf)
 	err := enc.Encode(data)
 	if err != nil {
 		return errors.Wrap(err, "error encoding gob data")
 	}
-
-	writer, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
-	if err != nil {
-		return errors.Wrap(err, "error creating target file")
+	if err := WriteCacheHeader(w, header); err != nil {
+		return err
 	}
-	defer writer.Close()
-
-	archiver := gzip.NewWriter(writer)
-	archiver.Name = filePath
-	defer archiver.Close()
+	archiver := gzip.NewWriter(w)
+	if _, err := io.Copy(archiver, &gobBuf); err != nil {
+		return errors.Wrap(err, "error compressing gob data")
+	}
+	return errors.Wrap(archiver.Close(), "error closing gzip writer")
+}
 
-	_, err = io.Copy(archiver, &gobBuf)
-	return err
+// EncodeToFile atomically writes the header followed by the gzipped gob of data
+func EncodeToFile(data interface{}, header CacheHeader, filePath string) error {
+	log.Debugf("Writing encoded data in %s", filePath)
+	return WriteFileAtomic(filePath, 0o600, func(writer io.Writer) error {
+		return Encode(writer, data, header)
+	})
 }
 
-func LoadGobFromFile(e interface{}, filePath string) error {
+// LoadGobFromFile decodes a file written by EncodeToFile in e and returns its header
+// Callers should check the returned header matches what they expect
+func LoadGobFromFile(e interface{}, filePath string) (CacheHeader, error) {
 	log.Debugf("Loading file %s", filePath)
 	b, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return errors.Wrap(err, "error reading file")
+		return CacheHeader{}, errors.Wrap(err, "error reading file")
 	}
 	return DecodeGob(e, b)
 }
 
-func DecodeGob(e interface{}, b []byte) error {
-	bbuffer := bytes.NewBuffer(b)
+// DecodeGob decodes the content of a file written by EncodeToFile in e and
+// returns its header
+func DecodeGob(e interface{}, b []byte) (CacheHeader, error) {
+	bbuffer := bytes.NewReader(b)
+	header, err := ReadCacheHeader(bbuffer)
+	if err != nil {
+		return header, err
+	}
 	zr, err := gzip.NewReader(bbuffer)
 	if err != nil {
-		return errors.Wrap(err, "error creating new gzip reader")
+		return header, errors.Wrap(err, "error creating new gzip reader")
 	}
 	dec := gob.NewDecoder(zr)
 	err = dec.Decode(e)
 	if err := zr.Close(); err != nil {
-		return errors.Wrap(err, "error closing gzip reader")
+		return header, errors.Wrap(err, "error closing gzip reader")
 	}
-	return err
+	return header, err
 }
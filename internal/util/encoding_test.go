@@ -1,8 +1,14 @@
 package util
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -15,17 +21,105 @@ func TestEncoding(t *testing.T) {
 	}
 	defer os.Remove(f.Name())
 
-	err = EncodeToFile(data, f.Name())
+	header := CacheHeader{ResourceType: "strings", Cluster: "test", ItemCount: 1}
+	err = EncodeToFile(data, header, f.Name())
 	if err != nil {
 		t.Fatalf("EncodeToFile() error = %v", err)
 	}
 
 	var res string
-	err = LoadGobFromFile(&res, f.Name())
+	loadedHeader, err := LoadGobFromFile(&res, f.Name())
 	if err != nil {
 		t.Fatalf("LoadGobFromFile() error = %v", err)
 	}
 	if res != "test" {
 		t.Fatalf("expected \"test\", got %q", res)
 	}
+	if loadedHeader.ResourceType != "strings" || loadedHeader.Cluster != "test" || loadedHeader.ItemCount != 1 {
+		t.Fatalf("unexpected header %+v", loadedHeader)
+	}
+}
+
+func TestLoadLegacyFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "legacy")
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(zw).Encode("test"); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	zw.Close()
+	if err := os.WriteFile(filePath, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	var res string
+	_, err := LoadGobFromFile(&res, filePath)
+	if _, ok := err.(IncompatibleCacheError); !ok {
+		t.Fatalf("expected IncompatibleCacheError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "cache written by incompatible version") {
+		t.Fatalf("unexpected error message %q", err)
+	}
+}
+
+func TestReadCorruptedHeaderLength(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(CacheMagic)
+	buf.Write([]byte{0, byte(CacheFormatVersion), 0xff, 0xff, 0xff, 0xff})
+	_, err := ReadCacheHeader(&buf)
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("expected an error on a corrupted header length, got %v", err)
+	}
+}
+
+func TestEncodingConcurrentReads(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data")
+	if err := EncodeToFile(map[string]string{}, CacheHeader{}, filePath); err != nil {
+		t.Fatalf("EncodeToFile() error = %v", err)
+	}
+
+	done := make(chan struct{})
+	writeErr := make(chan error, 1)
+	go func() {
+		defer close(done)
+		for i := 0; i < 40; i++ {
+			data := make(map[string]string, i*50)
+			for j := 0; j < i*50; j++ {
+				data[fmt.Sprintf("key-%d", j)] = strings.Repeat("v", j%100)
+			}
+			if err := EncodeToFile(data, CacheHeader{ItemCount: len(data)}, filePath); err != nil {
+				writeErr <- err
+				return
+			}
+		}
+	}()
+
+	reads := 0
+	for {
+		select {
+		case <-done:
+			select {
+			case err := <-writeErr:
+				t.Fatalf("EncodeToFile() error = %v", err)
+			default:
+			}
+			if reads == 0 {
+				t.Fatalf("expected at least one read during dumps")
+			}
+			entries, err := os.ReadDir(filepath.Dir(filePath))
+			if err != nil {
+				t.Fatalf("ReadDir() error = %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("expected temporary files to be cleaned up, got %d entries", len(entries))
+			}
+			return
+		default:
+		}
+		var res map[string]string
+		if _, err := LoadGobFromFile(&res, filePath); err != nil {
+			t.Fatalf("LoadGobFromFile() during dump error = %v", err)
+		}
+		reads++
+	}
 }
@@ -0,0 +1,211 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	"github.com/pkg/errors"
+)
+
+const unixScheme = "unix://"
+
+// HttpEndpoint is the address of a kubectl-fzf-server http server
+// It is written either host:port, http://host:port, https://host:port or
+// unix:///path/to/socket
+type HttpEndpoint struct {
+	// Network is tcp or unix
+	Network string
+	// Address is host:port for tcp and the socket path for unix
+	Address string
+	// TLS is true for https endpoints
+	TLS bool
+}
+
+// HttpClientConfig holds the credentials used to connect to an HttpEndpoint
+type HttpClientConfig struct {
+	// CAFile is the CA bundle verifying the server certificate, system
+	// roots are used when empty
+	CAFile string
+	// CertFile and KeyFile are the optional client certificate
+	CertFile string
+	KeyFile  string
+	// TokenFile holds the bearer token sent with each request
+	TokenFile string
+}
+
+// ParseHttpEndpoint parses an endpoint given by --listen-address or --http-endpoint
+func ParseHttpEndpoint(endpoint string) (HttpEndpoint, error) {
+	switch {
+	case strings.HasPrefix(endpoint, unixScheme):
+		socketPath := strings.TrimPrefix(endpoint, unixScheme)
+		if !strings.HasPrefix(socketPath, "/") {
+			return HttpEndpoint{}, fmt.Errorf("unix socket path of %s must be absolute", endpoint)
+		}
+		return HttpEndpoint{Network: "unix", Address: socketPath}, nil
+	case strings.HasPrefix(endpoint, "http://"):
+		address := strings.TrimSuffix(strings.TrimPrefix(endpoint, "http://"), "/")
+		return HttpEndpoint{Network: "tcp", Address: withDefaultPort(address, "80")}, nil
+	case strings.HasPrefix(endpoint, "https://"):
+		address := strings.TrimSuffix(strings.TrimPrefix(endpoint, "https://"), "/")
+		return HttpEndpoint{Network: "tcp", Address: withDefaultPort(address, "443"), TLS: true}, nil
+	case strings.Contains(endpoint, "://"):
+		return HttpEndpoint{}, fmt.Errorf("unsupported scheme in endpoint %s", endpoint)
+	}
+	return HttpEndpoint{Network: "tcp", Address: endpoint}, nil
+}
+
+// withDefaultPort adds port to address when it has none, e.g. for
+// https://host
+func withDefaultPort(address string, port string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	return net.JoinHostPort(host, port)
+}
+
+func (e HttpEndpoint) String() string {
+	if e.Network == "unix" {
+		return unixScheme + e.Address
+	}
+	if e.TLS {
+		return "https://" + e.Address
+	}
+	return e.Address
+}
+
+// URL returns the url of path on the endpoint
+func (e HttpEndpoint) URL(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if e.Network == "unix" {
+		// The host is ignored by the dialer of Client
+		return fmt.Sprintf("http://unix/%s", path)
+	}
+	if e.TLS {
+		return fmt.Sprintf("https://%s/%s", e.Address, path)
+	}
+	return fmt.Sprintf("http://%s/%s", e.Address, path)
+}
+
+func (e HttpEndpoint) dialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, e.Network, e.Address)
+}
+
+// Client returns an http client connecting to the endpoint with the
+// credentials of c
+func (e HttpEndpoint) Client(c HttpClientConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = e.dialContext
+	if e.TLS {
+		tlsConfig, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	var roundTripper http.RoundTripper = transport
+	if c.TokenFile != "" {
+		token, err := ReadTokenFile(c.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		roundTripper = &bearerRoundTripper{token: token, next: transport}
+	}
+	return &http.Client{Transport: roundTripper}, nil
+}
+
+func (c HttpClientConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pool, err := LoadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// LoadCertPool loads the PEM certificates of caFile
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading CA file")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificate found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// ReadTokenFile returns the bearer token stored in tokenFile
+func ReadTokenFile(tokenFile string) (string, error) {
+	b, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading token file")
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", tokenFile)
+	}
+	return token, nil
+}
+
+type bearerRoundTripper struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (b *bearerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return b.next.RoundTrip(req)
+}
+
+// IsLoopback returns true if the endpoint is only reachable from the local
+// machine: a unix socket or a loopback address
+func (e HttpEndpoint) IsLoopback() bool {
+	if e.Network == "unix" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(e.Address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// IsReachable returns true if a connection to the endpoint can be opened
+func (e HttpEndpoint) IsReachable() bool {
+	if e.Address == "" {
+		return false
+	}
+	conn, err := net.DialTimeout(e.Network, e.Address, time.Second)
+	if err != nil {
+		log.Infof("Couldn't connect to %s: %s", e, err)
+		return false
+	}
+	conn.Close()
+	return true
+}
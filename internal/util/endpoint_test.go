@@ -0,0 +1,36 @@
+package util
+
+import "testing"
+
+func TestParseHttpEndpoint(t *testing.T) {
+	cases := map[string]HttpEndpoint{
+		"localhost:8080":                  {Network: "tcp", Address: "localhost:8080"},
+		"http://localhost:8080/":          {Network: "tcp", Address: "localhost:8080"},
+		"https://fzf.example.com:8443":    {Network: "tcp", Address: "fzf.example.com:8443", TLS: true},
+		"unix:///run/user/1000/kfzf.sock": {Network: "unix", Address: "/run/user/1000/kfzf.sock"},
+		"https://fzf.example.com/":        {Network: "tcp", Address: "fzf.example.com:443", TLS: true},
+		"http://fzf.example.com":          {Network: "tcp", Address: "fzf.example.com:80"},
+		"https://[::1]":                   {Network: "tcp", Address: "[::1]:443", TLS: true},
+		"http://[::1]:8080":               {Network: "tcp", Address: "[::1]:8080"},
+	}
+	for input, want := range cases {
+		got, err := ParseHttpEndpoint(input)
+		if err != nil {
+			t.Fatalf("ParseHttpEndpoint(%q) error = %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("ParseHttpEndpoint(%q) = %+v, want %+v", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"unix://relative.sock", "ftp://localhost"} {
+		if _, err := ParseHttpEndpoint(input); err == nil {
+			t.Fatalf("expected error parsing %q", input)
+		}
+	}
+
+	e := HttpEndpoint{Network: "unix", Address: "/tmp/kfzf.sock"}
+	if got := e.URL("/k8s/resources/pods"); got != "http://unix/k8s/resources/pods" {
+		t.Fatalf("URL() = %s", got)
+	}
+}
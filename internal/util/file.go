@@ -1,9 +1,12 @@
 package util
 
 import (
+	"io"
 	"os"
+	"path/filepath"
 
 	log "github.com/codeactual/kubectl-fzf/v4/internal/logger"
+	"github.com/pkg/errors"
 )
 
 func RemoveTempDir(tempDir string) {
@@ -15,3 +18,65 @@ func FileExists(filePath string) bool {
 	_, err := os.Stat(filePath)
 	return err == nil
 }
+
+// WriteFileAtomic writes the content produced by write to a temporary file in
+// the destination directory, syncs it and renames it over filePath.
+// Readers either see the previous content or the new one, never a partial
+// file, even if the process crashes mid-write.
+func WriteFileAtomic(filePath string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	dir, base := filepath.Split(filePath)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "error creating temporary file")
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err = write(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return errors.Wrap(err, "error setting temporary file permissions")
+	}
+	if err = tmp.Sync(); err != nil {
+		return errors.Wrap(err, "error syncing temporary file")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "error closing temporary file")
+	}
+	if err = os.Rename(tmpPath, filePath); err != nil {
+		return errors.Wrap(err, "error renaming temporary file")
+	}
+	syncDir(dir)
+	return nil
+}
+
+// WriteBytesAtomic is the atomic equivalent of os.WriteFile
+func WriteBytesAtomic(filePath string, b []byte, perm os.FileMode) error {
+	return WriteFileAtomic(filePath, perm, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// syncDir persists the rename in the directory entry. Failures are only
+// logged as the new content is already visible to readers.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		log.Debugf("Couldn't open dir %s for sync: %s", dir, err)
+		return
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		log.Debugf("Couldn't sync dir %s: %s", dir, err)
+	}
+}
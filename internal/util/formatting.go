@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"text/tabwriter"
 
@@ -18,3 +19,44 @@ func FormatCompletion(lines []string) string {
 	w.Flush()
 	return b.String()
 }
+
+// Color is the ANSI code of a text colour
+type Color string
+
+const (
+	ColorNone   Color = ""
+	ColorRed    Color = "31"
+	ColorGreen  Color = "32"
+	ColorYellow Color = "33"
+)
+
+var ansiRegexp = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// ColorizeField colours the field-th whitespace separated field of line
+// Lines are coloured once aligned, the colour codes would otherwise count in
+// the width of the columns.
+func ColorizeField(line string, field int, color Color) string {
+	if color == ColorNone || field < 0 {
+		return line
+	}
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		isSpace := i == len(line) || line[i] == ' ' || line[i] == '\t'
+		switch {
+		case !isSpace && start < 0:
+			start = i
+		case isSpace && start >= 0:
+			if field == 0 {
+				return fmt.Sprintf("%s\x1b[%sm%s\x1b[0m%s", line[:start], color, line[start:i], line[i:])
+			}
+			field--
+			start = -1
+		}
+	}
+	return line
+}
+
+// StripColors removes the ANSI colour codes of s
+func StripColors(s string) string {
+	return ansiRegexp.ReplaceAllString(s, "")
+}
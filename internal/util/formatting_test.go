@@ -12,3 +12,20 @@ c2      c22
 		t.Errorf("FormatCompletion() = %q, want %q", res, expected)
 	}
 }
+
+func TestColorizeField(t *testing.T) {
+	line := "ns1  web   CrashLoopBackOff  1d"
+	res := ColorizeField(line, 2, ColorRed)
+	if res != "ns1  web   \x1b[31mCrashLoopBackOff\x1b[0m  1d" {
+		t.Errorf("ColorizeField() = %q", res)
+	}
+	if StripColors(res) != line {
+		t.Errorf("StripColors() = %q, want %q", StripColors(res), line)
+	}
+	if res := ColorizeField("ns1 web", 1, ColorYellow); res != "ns1 \x1b[33mweb\x1b[0m" {
+		t.Errorf("ColorizeField() = %q", res)
+	}
+	if res := ColorizeField(line, 4, ColorRed); res != line {
+		t.Errorf("expected missing field to be left as is, got %q", res)
+	}
+}
@@ -8,15 +8,15 @@ import (
 	"github.com/pkg/errors"
 )
 
-func GetFromHttpServer(url string) (http.Header, []byte, error) {
-	resp, err := http.Get(url)
+func GetFromHttpServer(client *http.Client, url string) (http.Header, []byte, error) {
+	resp, err := client.Get(url)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "error on get of %s", url)
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		return nil, nil, fmt.Errorf("error retrieving resource from server: %s", resp.Status)
 	}
-	defer resp.Body.Close()
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "error reading response body")
@@ -24,13 +24,18 @@ func GetFromHttpServer(url string) (http.Header, []byte, error) {
 	return resp.Header, b, nil
 }
 
-func HeadFromHttpServer(url string) (http.Header, error) {
-	resp, err := http.Head(url)
+// DoHttpRequest sends req and returns the response along with its body
+// Unlike GetFromHttpServer, any status is returned without error for the
+// caller to handle
+func DoHttpRequest(client *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, errors.Wrapf(err, "error on get of %s", url)
+		return nil, nil, errors.Wrapf(err, "error on %s of %s", req.Method, req.URL)
 	}
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("error retrieving resource from server: %s", resp.Status)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, errors.Wrap(err, "error reading response body")
 	}
-	return resp.Header, nil
+	return resp, b, nil
 }
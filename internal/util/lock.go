@@ -0,0 +1,50 @@
+package util
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// ErrLocked is returned by TryLock when another process holds the lock
+var ErrLocked = errors.New("lock held by another process")
+
+// TryLock takes an exclusive advisory lock on filePath without blocking
+// The lock is held until the returned file is closed or the process exits.
+func TryLock(filePath string) (*os.File, error) {
+	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening lock file %s", filePath)
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, ErrLocked
+		}
+		return nil, errors.Wrapf(err, "error locking %s", filePath)
+	}
+	return f, nil
+}
+
+// IsPidAlive returns true if the process whose pid is written in pidPath is
+// running
+// Unlike probing the lock, it doesn't take it: a process starting at the
+// same time still gets it.
+func IsPidAlive(pidPath string) bool {
+	b, err := os.ReadFile(pidPath)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	// Signal 0 only checks the existence of the process, EPERM means it runs
+	// as another user
+	err = syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
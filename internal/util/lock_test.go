@@ -0,0 +1,45 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestTryLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "lock")
+	f, err := TryLock(lockPath)
+	if err != nil {
+		t.Fatalf("TryLock() error = %v", err)
+	}
+	// flock locks are per open file: a second open in the same process conflicts
+	if _, err := TryLock(lockPath); err != ErrLocked {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	f.Close()
+	f, err = TryLock(lockPath)
+	if err != nil {
+		t.Fatalf("expected lock to be released, got %v", err)
+	}
+	f.Close()
+}
+
+func TestIsPidAlive(t *testing.T) {
+	pidPath := filepath.Join(t.TempDir(), "pid")
+	if IsPidAlive(pidPath) {
+		t.Fatalf("expected no process without pidfile")
+	}
+	if err := os.WriteFile(pidPath, []byte(strconv.Itoa(os.Getpid())), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if !IsPidAlive(pidPath) {
+		t.Fatalf("expected the test process to be alive")
+	}
+	if err := os.WriteFile(pidPath, []byte("not a pid"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if IsPidAlive(pidPath) {
+		t.Fatalf("expected an invalid pidfile to be ignored")
+	}
+}
@@ -157,7 +157,7 @@ func StringSliceToSet(lst []string) map[string]bool {
 }
 
 // JoinStringMap generates a list of map element separated by string excluding keys in excluded maps
-func JoinStringMap(m map[string]string, exclude map[string]string, sep string) []string {
+func JoinStringMap(m map[string]string, exclude map[string]bool, sep string) []string {
 	res := make([]string, 0)
 	for k, v := range m {
 		if _, ok := exclude[k]; ok {
@@ -212,3 +212,8 @@ func IsStringMatching(s string, regexps []*regexp.Regexp) bool {
 	}
 	return false
 }
+
+// ShellQuote quotes s as a single argument of a POSIX shell command
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -25,3 +25,16 @@ func TestSanitizeControlCharacters(t *testing.T) {
 		}
 	}
 }
+
+func TestShellQuote(t *testing.T) {
+	cases := map[string]string{
+		"plain":           "'plain'",
+		"it's 100%":       `'it'\''s 100%'`,
+		"$(rm -rf /) `x`": "'$(rm -rf /) `x`'",
+	}
+	for input, want := range cases {
+		if got := ShellQuote(input); got != want {
+			t.Fatalf("ShellQuote(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
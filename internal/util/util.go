@@ -2,7 +2,6 @@ package util
 
 import (
 	"fmt"
-	"net"
 	"runtime/debug"
 	"time"
 
@@ -14,19 +13,6 @@ type stackTracer interface {
 	StackTrace() errors.StackTrace
 }
 
-func IsAddressReachable(address string) bool {
-	if address == "" {
-		return false
-	}
-	conn, err := net.DialTimeout("tcp", address, time.Second)
-	if err != nil {
-		log.Infof("Couldn't connect to %s: %s", address, err)
-		return false
-	}
-	conn.Close()
-	return true
-}
-
 // FatalIf exits if the error is not nil
 func FatalIf(err error) {
 	if err != nil {
@@ -32,6 +32,11 @@ __kubectl_fzf_get_completions()
         echo "fallback"
         return
     fi
+    if [[ $exitCode == 7 ]]; then
+        # Stale data refused for this verb
+        echo "error: ${completionOutput}"
+        return
+    fi
     if [[ $exitCode != 0 ]]; then
         # Error on completion
         echo "error when calling kubectl-fzf-completion: $requestComp. Output: $completionOutput"